@@ -0,0 +1,95 @@
+package main
+
+import (
+	"context"
+	"database/sql"
+	"log"
+	"net/http"
+	"time"
+
+	"github.com/dennisdijkstra/go/internal/auth"
+	"github.com/dennisdijkstra/go/internal/database"
+	"github.com/google/uuid"
+)
+
+const accessTokenLifetime = time.Hour
+
+// deletedUserValidAfter is far enough in the future that every token of a
+// user who no longer exists is rejected.
+var deletedUserValidAfter = time.Date(9999, 1, 1, 0, 0, 0, 0, time.UTC)
+
+// tokenStateSource loads the revocation state of a user's access tokens for
+// auth.RevocationCache.
+type tokenStateSource struct {
+	db *database.Queries
+}
+
+func (s tokenStateSource) LoadTokenState(ctx context.Context, userID uuid.UUID) (auth.TokenState, error) {
+	validAfter, err := s.db.GetUserTokensValidAfter(ctx, userID)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return auth.TokenState{ValidAfter: deletedUserValidAfter}, nil
+		}
+		return auth.TokenState{}, err
+	}
+
+	revokedIDs, err := s.db.GetRevokedAccessTokenIDs(ctx, userID)
+	if err != nil {
+		return auth.TokenState{}, err
+	}
+
+	state := auth.TokenState{
+		ValidAfter: validAfter,
+		RevokedIDs: make(map[uuid.UUID]struct{}, len(revokedIDs)),
+	}
+	for _, id := range revokedIDs {
+		state.RevokedIDs[id] = struct{}{}
+	}
+
+	return state, nil
+}
+
+// handlerLogout revokes the access token the request was made with. Pair it
+// with /api/revoke to also end the session behind the refresh token.
+func (cfg *apiConfig) handlerLogout(w http.ResponseWriter, r *http.Request) {
+	claims, code, msg, ok := cfg.requireAccessClaims(r)
+	if !ok {
+		respondWithError(w, code, msg)
+		return
+	}
+
+	// A legacy token has no jti to revoke it by, so logging out with one
+	// revokes every access token the user holds.
+	var err error
+	if claims.TokenID == uuid.Nil {
+		err = invalidateAccessTokens(r.Context(), cfg.db, claims.UserID)
+	} else {
+		err = cfg.db.CreateRevokedAccessToken(r.Context(), database.CreateRevokedAccessTokenParams{
+			Jti:       claims.TokenID,
+			UserID:    claims.UserID,
+			ExpiresAt: claims.ExpiresAt,
+		})
+	}
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Something went wrong while revoking the access token")
+		return
+	}
+	cfg.tokenRevocations.Invalidate(claims.UserID)
+
+	// Revoked tokens only need to be remembered until they would have
+	// expired anyway.
+	if err := cfg.db.DeleteExpiredRevokedAccessTokens(r.Context()); err != nil {
+		log.Printf("Error deleting expired revoked access tokens: %s", err)
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// invalidateAccessTokens rejects every access token the user was issued
+// before now. Call tokenRevocations.Invalidate once the change is committed.
+func invalidateAccessTokens(ctx context.Context, q *database.Queries, userID uuid.UUID) error {
+	return q.UpdateUserTokensValidAfter(ctx, database.UpdateUserTokensValidAfterParams{
+		ID:               userID,
+		TokensValidAfter: auth.ValidAfterCutoff(time.Now().UTC()),
+	})
+}
@@ -0,0 +1,287 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"database/sql"
+	"errors"
+	"io"
+	"log"
+	"net/http"
+	"time"
+
+	"github.com/dennisdijkstra/go/internal/blob"
+	"github.com/dennisdijkstra/go/internal/database"
+	"github.com/google/uuid"
+)
+
+const (
+	// pendingAttachmentTTL is how long an upload may wait to be attached to
+	// a chirp before it is deleted.
+	pendingAttachmentTTL      = 24 * time.Hour
+	attachmentCleanupInterval = time.Hour
+)
+
+// attachmentLimits caps single files and the uploads a user has not
+// attached to a chirp yet.
+type attachmentLimits struct {
+	MaxBytes        int64
+	MaxPerChirp     int
+	MaxPending      int64
+	MaxPendingBytes int64
+}
+
+var (
+	standardAttachmentLimits  = attachmentLimits{MaxBytes: 2 << 20, MaxPerChirp: 1, MaxPending: 4, MaxPendingBytes: 8 << 20}
+	chirpyRedAttachmentLimits = attachmentLimits{MaxBytes: 10 << 20, MaxPerChirp: 4, MaxPending: 16, MaxPendingBytes: 64 << 20}
+)
+
+var attachmentExtensions = map[string]string{
+	"image/png":  ".png",
+	"image/jpeg": ".jpg",
+	"image/gif":  ".gif",
+	"image/webp": ".webp",
+}
+
+type Attachment struct {
+	ID          uuid.UUID `json:"id"`
+	URL         string    `json:"url"`
+	ContentType string    `json:"content_type"`
+	SizeBytes   int64     `json:"size_bytes"`
+}
+
+func attachmentLimitsFor(user database.User) attachmentLimits {
+	if user.IsChirpyRed {
+		return chirpyRedAttachmentLimits
+	}
+	return standardAttachmentLimits
+}
+
+// handlerUploadAttachment stores an image sent as the "file" field of a
+// multipart form. The returned ID can be passed in attachment_ids when
+// creating a chirp. Uploads that are never attached count against the
+// user's pending quota until pruneStaleAttachments removes them.
+func (cfg *apiConfig) handlerUploadAttachment(w http.ResponseWriter, r *http.Request) {
+	userID, code, msg, ok := cfg.requireJWTUserID(r)
+	if !ok {
+		respondWithError(w, code, msg)
+		return
+	}
+
+	user, err := cfg.db.GetUserByID(r.Context(), userID)
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Something went wrong while fetching the user")
+		return
+	}
+	limits := attachmentLimitsFor(user)
+
+	// Leave room for the multipart framing around the file itself.
+	r.Body = http.MaxBytesReader(w, r.Body, limits.MaxBytes+1<<20)
+	file, _, err := r.FormFile("file")
+	if err != nil {
+		var maxBytesErr *http.MaxBytesError
+		if errors.As(err, &maxBytesErr) {
+			respondWithError(w, http.StatusRequestEntityTooLarge, "File is too large")
+			return
+		}
+		respondWithError(w, http.StatusBadRequest, "A file is required")
+		return
+	}
+	defer file.Close()
+
+	data, err := io.ReadAll(io.LimitReader(file, limits.MaxBytes+1))
+	if err != nil {
+		respondWithError(w, http.StatusBadRequest, "Something went wrong while reading the file")
+		return
+	}
+
+	if int64(len(data)) > limits.MaxBytes {
+		respondWithError(w, http.StatusRequestEntityTooLarge, "File is too large")
+		return
+	}
+
+	// Trust the bytes, not the Content-Type the client sent.
+	contentType := http.DetectContentType(data)
+	extension, ok := attachmentExtensions[contentType]
+	if !ok {
+		respondWithError(w, http.StatusUnsupportedMediaType, "Unsupported file type")
+		return
+	}
+
+	tx, err := cfg.dbConn.BeginTx(r.Context(), nil)
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Something went wrong while saving the attachment")
+		return
+	}
+	defer tx.Rollback()
+	qtx := cfg.db.WithTx(tx)
+
+	// Lock the user so concurrent uploads are checked against the quota
+	// one at a time.
+	err = qtx.LockUserUploads(r.Context(), userID)
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Something went wrong while saving the attachment")
+		return
+	}
+
+	usage, err := qtx.GetPendingAttachmentUsage(r.Context(), userID)
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Something went wrong while saving the attachment")
+		return
+	}
+
+	if usage.Count >= limits.MaxPending || usage.SizeBytes+int64(len(data)) > limits.MaxPendingBytes {
+		respondWithError(w, http.StatusTooManyRequests, "Too many unattached uploads")
+		return
+	}
+
+	attachmentID := uuid.New()
+	storageKey := attachmentID.String() + extension
+
+	err = cfg.blobStore.Put(r.Context(), storageKey, bytes.NewReader(data))
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Something went wrong while storing the file")
+		return
+	}
+
+	attachment, err := qtx.CreateAttachment(r.Context(), database.CreateAttachmentParams{
+		ID:          attachmentID,
+		UserID:      userID,
+		StorageKey:  storageKey,
+		ContentType: contentType,
+		SizeBytes:   int64(len(data)),
+	})
+	if err == nil {
+		err = tx.Commit()
+	}
+	if err != nil {
+		cfg.deleteBlobs(context.WithoutCancel(r.Context()), []string{storageKey})
+		respondWithError(w, http.StatusInternalServerError, "Something went wrong while saving the attachment")
+		return
+	}
+
+	respondWithJSON(w, http.StatusCreated, attachmentFromDB(attachment))
+}
+
+// handlerGetMedia serves the file of an attachment. Until it is attached to
+// a chirp, only the user who uploaded it can fetch it.
+func (cfg *apiConfig) handlerGetMedia(w http.ResponseWriter, r *http.Request) {
+	attachmentID, err := uuid.Parse(r.PathValue("attachmentID"))
+	if err != nil {
+		respondWithError(w, http.StatusNotFound, "Attachment not found")
+		return
+	}
+
+	attachment, err := cfg.db.GetAttachmentByID(r.Context(), attachmentID)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			respondWithError(w, http.StatusNotFound, "Attachment not found")
+			return
+		}
+		respondWithError(w, http.StatusInternalServerError, "Something went wrong while fetching the attachment")
+		return
+	}
+
+	cacheControl := "public, max-age=31536000, immutable"
+	if !attachment.ChirpID.Valid {
+		userID, _, _, ok := cfg.requireJWTUserID(r)
+		if !ok || userID != attachment.UserID {
+			respondWithError(w, http.StatusNotFound, "Attachment not found")
+			return
+		}
+		cacheControl = "private, no-store"
+	}
+
+	file, err := cfg.blobStore.Open(r.Context(), attachment.StorageKey)
+	if err != nil {
+		if errors.Is(err, blob.ErrNotFound) {
+			respondWithError(w, http.StatusNotFound, "Attachment not found")
+			return
+		}
+		respondWithError(w, http.StatusInternalServerError, "Something went wrong while reading the attachment")
+		return
+	}
+	defer file.Close()
+
+	w.Header().Set("Content-Type", attachment.ContentType)
+	w.Header().Set("X-Content-Type-Options", "nosniff")
+	w.Header().Set("Cache-Control", cacheControl)
+	w.WriteHeader(http.StatusOK)
+	io.Copy(w, file)
+}
+
+// attachMedia fills in the attachments of every chirp in groups with a
+// single query. Chirps are updated in place.
+func (cfg *apiConfig) attachMedia(ctx context.Context, groups ...[]Chirp) error {
+	var chirpIDs []uuid.UUID
+	for _, chirps := range groups {
+		for _, chirp := range chirps {
+			chirpIDs = append(chirpIDs, chirp.ID)
+		}
+	}
+
+	if len(chirpIDs) == 0 {
+		return nil
+	}
+
+	attachments, err := cfg.db.GetAttachmentsForChirps(ctx, chirpIDs)
+	if err != nil {
+		return err
+	}
+
+	byChirp := make(map[uuid.UUID][]Attachment, len(attachments))
+	for _, attachment := range attachments {
+		byChirp[attachment.ChirpID.UUID] = append(byChirp[attachment.ChirpID.UUID], attachmentFromDB(attachment))
+	}
+
+	for _, chirps := range groups {
+		for i := range chirps {
+			if attachments, ok := byChirp[chirps[i].ID]; ok {
+				chirps[i].Attachments = attachments
+			}
+		}
+	}
+
+	return nil
+}
+
+// deleteBlobs removes stored files after their rows are gone. Failures only
+// leave orphaned files behind, so they are logged rather than returned.
+func (cfg *apiConfig) deleteBlobs(ctx context.Context, keys []string) {
+	for _, key := range keys {
+		err := cfg.blobStore.Delete(ctx, key)
+		if err != nil {
+			log.Printf("Error deleting blob %s: %s", key, err)
+		}
+	}
+}
+
+// pruneStaleAttachments deletes uploads that were never attached to a chirp
+// within pendingAttachmentTTL, along with their files.
+func (cfg *apiConfig) pruneStaleAttachments(ctx context.Context) {
+	ticker := time.NewTicker(attachmentCleanupInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			storageKeys, err := cfg.db.DeleteStaleAttachments(ctx, time.Now().Add(-pendingAttachmentTTL))
+			if err != nil {
+				log.Printf("Error pruning stale attachments: %s", err)
+				continue
+			}
+			cfg.deleteBlobs(ctx, storageKeys)
+		}
+	}
+}
+
+func attachmentFromDB(attachment database.Attachment) Attachment {
+	return Attachment{
+		ID:          attachment.ID,
+		URL:         "/media/" + attachment.ID.String(),
+		ContentType: attachment.ContentType,
+		SizeBytes:   attachment.SizeBytes,
+	}
+}
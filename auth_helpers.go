@@ -8,15 +8,65 @@ import (
 )
 
 func (cfg *apiConfig) requireJWTUserID(r *http.Request) (uuid.UUID, int, string, bool) {
+	claims, code, msg, ok := cfg.requireAccessClaims(r)
+	if !ok {
+		return uuid.Nil, code, msg, false
+	}
+
+	return claims.UserID, 0, "", true
+}
+
+// requireAccessClaims validates the bearer access token and rejects it if it
+// was logged out or issued before the user's tokens were invalidated.
+func (cfg *apiConfig) requireAccessClaims(r *http.Request) (auth.AccessClaims, int, string, bool) {
 	bearerToken, err := auth.GetBearerToken(r.Header)
 	if err != nil {
-		return uuid.Nil, http.StatusUnauthorized, "Something went wrong while parsing the bearer token", false
+		return auth.AccessClaims{}, http.StatusUnauthorized, "Something went wrong while parsing the bearer token", false
 	}
 
-	userID, err := auth.ValidateJWT(bearerToken, cfg.jwtSecret)
+	claims, err := auth.ParseJWT(bearerToken, cfg.signingKeys)
 	if err != nil {
-		return uuid.Nil, http.StatusUnauthorized, "Unauthorized", false
+		return auth.AccessClaims{}, http.StatusUnauthorized, "Unauthorized", false
+	}
+
+	revoked, err := cfg.tokenRevocations.IsRevoked(r.Context(), claims)
+	if err != nil {
+		return auth.AccessClaims{}, http.StatusInternalServerError, "Something went wrong while checking the access token", false
+	}
+
+	if revoked {
+		return auth.AccessClaims{}, http.StatusUnauthorized, "Unauthorized", false
 	}
 
-	return userID, 0, "", true
+	return claims, 0, "", true
+}
+
+// optionalJWTUserID returns the caller's user ID when the request carries a
+// valid access token, for endpoints that are public but personalised.
+func (cfg *apiConfig) optionalJWTUserID(r *http.Request) uuid.NullUUID {
+	userID, _, _, ok := cfg.requireJWTUserID(r)
+	if !ok {
+		return uuid.NullUUID{}
+	}
+
+	return uuid.NullUUID{UUID: userID, Valid: true}
+}
+
+// middlewareRequireScope only lets a request through when its access token
+// grants scope.
+func (cfg *apiConfig) middlewareRequireScope(scope string, next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		claims, code, msg, ok := cfg.requireAccessClaims(r)
+		if !ok {
+			respondWithError(w, code, msg)
+			return
+		}
+
+		if !claims.HasScope(scope) {
+			respondWithError(w, http.StatusForbidden, "Forbidden")
+			return
+		}
+
+		next.ServeHTTP(w, r)
+	})
 }
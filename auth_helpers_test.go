@@ -0,0 +1,37 @@
+package main
+
+import (
+	"database/sql/driver"
+	"testing"
+	"time"
+
+	"github.com/dennisdijkstra/go/internal/auth"
+	"github.com/google/uuid"
+)
+
+// newTestAccessToken sets cfg up to accept access tokens and returns one
+// for userID with role. No token is revoked.
+func newTestAccessToken(t *testing.T, cfg *apiConfig, f *fakeDB, userID uuid.UUID, role string) string {
+	t.Helper()
+
+	key, err := auth.GenerateSigningKey(auth.AlgEdDSA)
+	if err != nil {
+		t.Fatalf("failed to generate signing key: %v", err)
+	}
+	cfg.signingKeys = auth.NewKeySet("")
+	cfg.signingKeys.SetKeys([]auth.SigningKey{key})
+	cfg.tokenRevocations = auth.NewRevocationCache(tokenStateSource{db: cfg.db}, time.Minute)
+
+	f.stub("GetUserTokensValidAfter", func([]driver.Value) (fakeResult, error) {
+		return fakeResult{rows: [][]driver.Value{{time.Unix(0, 0)}}}, nil
+	})
+	f.stub("GetRevokedAccessTokenIDs", func([]driver.Value) (fakeResult, error) {
+		return fakeResult{}, nil
+	})
+
+	token, err := auth.MakeJWT(userID, role, cfg.signingKeys, time.Hour)
+	if err != nil {
+		t.Fatalf("failed to make access token: %v", err)
+	}
+	return token
+}
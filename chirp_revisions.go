@@ -0,0 +1,58 @@
+package main
+
+import (
+	"database/sql"
+	"net/http"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+type ChirpRevision struct {
+	ID        uuid.UUID `json:"id"`
+	CreatedAt time.Time `json:"created_at"`
+	ChirpID   uuid.UUID `json:"chirp_id"`
+	Body      string    `json:"body"`
+}
+
+func (cfg *apiConfig) handlerGetChirpRevisions(w http.ResponseWriter, r *http.Request) {
+	chirpID := r.PathValue("chirpID")
+	if chirpID == "" {
+		respondWithError(w, http.StatusBadRequest, "Chirp ID is required")
+		return
+	}
+
+	chirpUUID, err := uuid.Parse(chirpID)
+	if err != nil {
+		respondWithError(w, http.StatusBadRequest, "Invalid chirp ID")
+		return
+	}
+
+	_, err = cfg.db.GetChirpByID(r.Context(), chirpUUID)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			respondWithError(w, http.StatusNotFound, "Chirp not found")
+			return
+		}
+		respondWithError(w, http.StatusInternalServerError, "Something went wrong while fetching the chirp")
+		return
+	}
+
+	revisions, err := cfg.db.GetChirpRevisions(r.Context(), chirpUUID)
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Something went wrong while fetching the chirp revisions")
+		return
+	}
+
+	response := make([]ChirpRevision, 0, len(revisions))
+	for _, revision := range revisions {
+		response = append(response, ChirpRevision{
+			ID:        revision.ID,
+			CreatedAt: revision.CreatedAt,
+			ChirpID:   revision.ChirpID,
+			Body:      revision.Body,
+		})
+	}
+
+	respondWithJSON(w, http.StatusOK, response)
+}
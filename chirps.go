@@ -1,28 +1,43 @@
 package main
 
 import (
+	"context"
 	"database/sql"
 	"encoding/json"
 	"net/http"
-	"sort"
-	"strings"
 	"time"
 
+	"github.com/dennisdijkstra/go/internal/auth"
 	"github.com/dennisdijkstra/go/internal/database"
+	"github.com/dennisdijkstra/go/internal/profanity"
 	"github.com/google/uuid"
 )
 
 type ChirpParams struct {
-	Body   string    `json:"body"`
-	UserID uuid.UUID `json:"user_id"`
+	Body          string      `json:"body"`
+	UserID        uuid.UUID   `json:"user_id"`
+	ReplyTo       *uuid.UUID  `json:"reply_to"`
+	AttachmentIDs []uuid.UUID `json:"attachment_ids"`
 }
 
 type Chirp struct {
-	ID        uuid.UUID `json:"id"`
-	CreatedAt time.Time `json:"created_at"`
-	UpdatedAt time.Time `json:"updated_at"`
-	Body      string    `json:"body"`
-	UserID    uuid.UUID `json:"user_id"`
+	ID        uuid.UUID  `json:"id"`
+	CreatedAt time.Time  `json:"created_at"`
+	UpdatedAt time.Time  `json:"updated_at"`
+	Body      string     `json:"body"`
+	UserID    uuid.UUID  `json:"user_id"`
+	ReplyTo   *uuid.UUID `json:"reply_to"`
+	Edited    bool       `json:"edited"`
+	Deleted   bool       `json:"deleted"`
+
+	Reactions     map[string]int64 `json:"reactions"`
+	ViewerReacted bool             `json:"viewer_reacted"`
+	Attachments   []Attachment     `json:"attachments"`
+}
+
+type ChirpsPage struct {
+	Chirps     []Chirp `json:"chirps"`
+	NextCursor string  `json:"next_cursor"`
 }
 
 func (cfg *apiConfig) handlerCreateChirp(w http.ResponseWriter, r *http.Request) {
@@ -41,16 +56,51 @@ func (cfg *apiConfig) handlerCreateChirp(w http.ResponseWriter, r *http.Request)
 		return
 	}
 
-	maxLength := 140
-	if len(params.Body) > maxLength {
-		respondWithError(w, http.StatusBadRequest, "Chirp is too long")
+	cleaned, code, msg, ok := cfg.validateChirpBody(r.Context(), params.Body)
+	if !ok {
+		respondWithError(w, code, msg)
 		return
 	}
 
-	cleanedBody := getCleanedBody(params.Body)
-	chirp, err := cfg.db.CreateChirp(r.Context(), database.CreateChirpParams{
-		Body:   cleanedBody,
-		UserID: userID,
+	if len(params.AttachmentIDs) > 0 {
+		user, err := cfg.db.GetUserByID(r.Context(), userID)
+		if err != nil {
+			respondWithError(w, http.StatusInternalServerError, "Something went wrong while fetching the user")
+			return
+		}
+
+		if len(params.AttachmentIDs) > attachmentLimitsFor(user).MaxPerChirp {
+			respondWithError(w, http.StatusBadRequest, "Too many attachments")
+			return
+		}
+	}
+
+	var replyTo uuid.NullUUID
+	if params.ReplyTo != nil {
+		_, err = cfg.db.GetChirpByID(r.Context(), *params.ReplyTo)
+		if err != nil {
+			if err == sql.ErrNoRows {
+				respondWithError(w, http.StatusNotFound, "Chirp to reply to not found")
+				return
+			}
+			respondWithError(w, http.StatusInternalServerError, "Something went wrong while fetching the chirp to reply to")
+			return
+		}
+		replyTo = uuid.NullUUID{UUID: *params.ReplyTo, Valid: true}
+	}
+
+	tx, err := cfg.dbConn.BeginTx(r.Context(), nil)
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Something went wrong while creating the chirp")
+		return
+	}
+	defer tx.Rollback()
+	qtx := cfg.db.WithTx(tx)
+
+	chirp, err := qtx.CreateChirp(r.Context(), database.CreateChirpParams{
+		Body:    cleaned.Body,
+		UserID:  userID,
+		ReplyTo: replyTo,
 	})
 
 	if err != nil {
@@ -58,19 +108,58 @@ func (cfg *apiConfig) handlerCreateChirp(w http.ResponseWriter, r *http.Request)
 		return
 	}
 
-	body := Chirp{
-		ID:        chirp.ID,
-		CreatedAt: chirp.CreatedAt,
-		UpdatedAt: chirp.UpdatedAt,
-		Body:      chirp.Body,
-		UserID:    chirp.UserID,
+	err = indexChirpEntities(r.Context(), qtx, chirp.ID, chirp.Body)
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Something went wrong while indexing the chirp")
+		return
+	}
+
+	if len(params.AttachmentIDs) > 0 {
+		attached, err := qtx.AttachToChirp(r.Context(), database.AttachToChirpParams{
+			ChirpID: uuid.NullUUID{UUID: chirp.ID, Valid: true},
+			Ids:     params.AttachmentIDs,
+			UserID:  userID,
+		})
+		if err != nil {
+			respondWithError(w, http.StatusInternalServerError, "Something went wrong while attaching files")
+			return
+		}
+
+		// Attachments must be the caller's own uploads and not used yet.
+		if attached != int64(len(params.AttachmentIDs)) {
+			respondWithError(w, http.StatusBadRequest, "Invalid attachment_ids")
+			return
+		}
+	}
+
+	err = enqueueWebhookEvent(r.Context(), qtx, eventChirpCreated, uuid.NullUUID{}, chirpFromDB(chirp))
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Something went wrong while queueing webhooks")
+		return
+	}
+
+	if err := tx.Commit(); err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Something went wrong while creating the chirp")
+		return
 	}
+	cfg.metrics.chirpsCreated.Inc()
 
-	respondWithJSON(w, http.StatusCreated, body)
+	cfg.flagChirp(r.Context(), chirp.ID, cleaned.Flagged)
+
+	response := []Chirp{chirpFromDB(chirp)}
+	err = cfg.attachMedia(r.Context(), response)
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Something went wrong while fetching attachments")
+		return
+	}
+
+	cfg.publishChirpEvent(eventChirpCreated, chirp.UserID, response[0])
+
+	respondWithJSON(w, http.StatusCreated, response[0])
 }
 
 func (cfg *apiConfig) handlerGetChirps(w http.ResponseWriter, r *http.Request) {
-	var authorID uuid.UUID
+	var authorID uuid.NullUUID
 	var chirps []database.Chirp
 	var err error
 
@@ -78,46 +167,42 @@ func (cfg *apiConfig) handlerGetChirps(w http.ResponseWriter, r *http.Request) {
 	sortQuery := r.URL.Query().Get("sort")
 
 	if authorIDQuery != "" {
-		authorID, err = uuid.Parse(authorIDQuery)
+		parsedID, err := uuid.Parse(authorIDQuery)
 		if err != nil {
 			respondWithError(w, http.StatusBadRequest, "Invalid author_id")
 			return
 		}
+		authorID = uuid.NullUUID{UUID: parsedID, Valid: true}
 	}
 
-	if authorID != uuid.Nil {
-		chirps, err = cfg.db.GetChirpsByAuthorID(r.Context(), authorID)
-		if err != nil {
-			respondWithError(w, http.StatusInternalServerError, "Something went wrong while fetching chirps")
-			return
-		}
-	} else {
-		chirps, err = cfg.db.GetChirps(r.Context())
-		if err != nil {
-			respondWithError(w, http.StatusInternalServerError, "Something went wrong while fetching chirps")
-			return
-		}
+	limit, cursorCreatedAt, cursorID, err := parsePageParams(r)
+	if err != nil {
+		respondWithError(w, http.StatusBadRequest, err.Error())
+		return
 	}
 
-	response := make([]Chirp, 0, len(chirps))
-	for _, chirp := range chirps {
-		response = append(response, Chirp{
-			ID:        chirp.ID,
-			CreatedAt: chirp.CreatedAt,
-			UpdatedAt: chirp.UpdatedAt,
-			Body:      chirp.Body,
-			UserID:    chirp.UserID,
+	// Fetch one extra row so we know whether another page follows.
+	if sortQuery == "desc" {
+		chirps, err = cfg.db.GetChirpsPageDesc(r.Context(), database.GetChirpsPageDescParams{
+			AuthorID:        authorID,
+			CursorCreatedAt: cursorCreatedAt,
+			CursorID:        cursorID,
+			PageSize:        limit + 1,
+		})
+	} else {
+		chirps, err = cfg.db.GetChirpsPageAsc(r.Context(), database.GetChirpsPageAscParams{
+			AuthorID:        authorID,
+			CursorCreatedAt: cursorCreatedAt,
+			CursorID:        cursorID,
+			PageSize:        limit + 1,
 		})
 	}
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Something went wrong while fetching chirps")
+		return
+	}
 
-	sort.Slice(response, func(i, j int) bool {
-		if sortQuery == "desc" {
-			return response[i].CreatedAt.After(response[j].CreatedAt)
-		}
-		return response[i].CreatedAt.Before(response[j].CreatedAt)
-	})
-
-	respondWithJSON(w, http.StatusOK, response)
+	cfg.respondWithChirpsPage(w, r, chirps, limit, cfg.optionalJWTUserID(r))
 }
 
 func (cfg *apiConfig) handlerGetChirpByID(w http.ResponseWriter, r *http.Request) {
@@ -143,81 +228,327 @@ func (cfg *apiConfig) handlerGetChirpByID(w http.ResponseWriter, r *http.Request
 		return
 	}
 
-	body := Chirp{
-		ID:        chirp.ID,
-		CreatedAt: chirp.CreatedAt,
-		UpdatedAt: chirp.UpdatedAt,
-		Body:      chirp.Body,
-		UserID:    chirp.UserID,
+	cfg.respondWithChirp(w, r, chirp, cfg.optionalJWTUserID(r))
+}
+
+func (cfg *apiConfig) handlerUpdateChirp(w http.ResponseWriter, r *http.Request) {
+	userID, code, msg, ok := cfg.requireJWTUserID(r)
+	if !ok {
+		respondWithError(w, code, msg)
+		return
+	}
+
+	chirp, code, msg, ok := cfg.requireOwnedChirp(r, userID)
+	if !ok {
+		respondWithError(w, code, msg)
+		return
+	}
+
+	decoder := json.NewDecoder(r.Body)
+	params := ChirpParams{}
+	err := decoder.Decode(&params)
+
+	if err != nil {
+		respondWithError(w, http.StatusBadRequest, "Something went wrong while decoding the request body")
+		return
+	}
+
+	cleaned, code, msg, ok := cfg.validateChirpBody(r.Context(), params.Body)
+	if !ok {
+		respondWithError(w, code, msg)
+		return
+	}
+
+	tx, err := cfg.dbConn.BeginTx(r.Context(), nil)
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Something went wrong while updating the chirp")
+		return
+	}
+	defer tx.Rollback()
+	qtx := cfg.db.WithTx(tx)
+
+	// The previous body is read under a row lock, so concurrent edits each
+	// record the body the other one replaced.
+	chirp, err = qtx.LockChirp(r.Context(), chirp.ID)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			respondWithError(w, http.StatusNotFound, "Chirp not found")
+			return
+		}
+		respondWithError(w, http.StatusInternalServerError, "Something went wrong while fetching the chirp")
+		return
+	}
+
+	viewerID := uuid.NullUUID{UUID: userID, Valid: true}
+	if cleaned.Body == chirp.Body {
+		tx.Rollback()
+		cfg.respondWithChirp(w, r, chirp, viewerID)
+		return
+	}
+
+	_, err = qtx.CreateChirpRevision(r.Context(), database.CreateChirpRevisionParams{
+		ChirpID: chirp.ID,
+		Body:    chirp.Body,
+	})
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Something went wrong while saving the chirp revision")
+		return
+	}
+
+	updated, err := qtx.UpdateChirpBody(r.Context(), database.UpdateChirpBodyParams{
+		ID:   chirp.ID,
+		Body: cleaned.Body,
+	})
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Something went wrong while updating the chirp")
+		return
 	}
 
-	respondWithJSON(w, http.StatusOK, body)
+	err = indexChirpEntities(r.Context(), qtx, updated.ID, updated.Body)
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Something went wrong while indexing the chirp")
+		return
+	}
+
+	if err := tx.Commit(); err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Something went wrong while updating the chirp")
+		return
+	}
+
+	cfg.flagChirp(r.Context(), updated.ID, cleaned.Flagged)
+
+	cfg.respondWithChirp(w, r, updated, viewerID)
 }
 
 func (cfg *apiConfig) handlerDeleteChirpByID(w http.ResponseWriter, r *http.Request) {
-	chirpID := r.PathValue("chirpID")
-	if chirpID == "" {
-		respondWithError(w, http.StatusBadRequest, "Chirp ID is required")
+	claims, code, msg, ok := cfg.requireAccessClaims(r)
+	if !ok {
+		respondWithError(w, code, msg)
 		return
 	}
 
-	userID, code, msg, ok := cfg.requireJWTUserID(r)
+	chirp, code, msg, ok := cfg.requirePathChirp(r)
 	if !ok {
 		respondWithError(w, code, msg)
 		return
 	}
 
-	chirpUUID, err := uuid.Parse(chirpID)
+	// Moderators may remove anyone's chirp; everyone else only their own.
+	if chirp.UserID != claims.UserID && !claims.HasScope(auth.ScopeModerate) {
+		respondWithError(w, http.StatusForbidden, "You are not allowed to modify this chirp")
+		return
+	}
+
+	tx, err := cfg.dbConn.BeginTx(r.Context(), nil)
 	if err != nil {
-		respondWithError(w, http.StatusBadRequest, "Invalid chirp ID")
+		respondWithError(w, http.StatusInternalServerError, "Something went wrong while deleting the chirp")
 		return
 	}
+	defer tx.Rollback()
+	qtx := cfg.db.WithTx(tx)
 
-	chirp, err := cfg.db.GetChirpByID(r.Context(), chirpUUID)
+	// The row lock conflicts with the key-share lock a new reply takes on
+	// its parent. Replies are counted only once none can be added, so one
+	// cannot be orphaned by a hard delete.
+	_, err = qtx.LockChirp(r.Context(), chirp.ID)
 	if err != nil {
 		if err == sql.ErrNoRows {
 			respondWithError(w, http.StatusNotFound, "Chirp not found")
 			return
 		}
-		respondWithError(w, http.StatusInternalServerError, "Something went wrong while fetching the chirp")
+		respondWithError(w, http.StatusInternalServerError, "Something went wrong while deleting the chirp")
 		return
 	}
 
-	if chirp.UserID != userID {
-		respondWithError(w, http.StatusForbidden, "You are not allowed to delete this chirp")
+	hasReplies, err := qtx.ChirpHasReplies(r.Context(), uuid.NullUUID{UUID: chirp.ID, Valid: true})
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Something went wrong while deleting the chirp")
 		return
 	}
 
-	err = cfg.db.DeleteChirpByID(r.Context(), chirpUUID)
+	storageKeys, err := qtx.DeleteChirpAttachments(r.Context(), uuid.NullUUID{UUID: chirp.ID, Valid: true})
 	if err != nil {
 		respondWithError(w, http.StatusInternalServerError, "Something went wrong while deleting the chirp")
 		return
 	}
 
+	if hasReplies {
+		// Replies keep pointing at the chirp, so leave a tombstone in its
+		// place and drop the content, including earlier revisions.
+		err = qtx.DeleteChirpRevisions(r.Context(), chirp.ID)
+		if err != nil {
+			respondWithError(w, http.StatusInternalServerError, "Something went wrong while deleting the chirp")
+			return
+		}
+
+		err = indexChirpEntities(r.Context(), qtx, chirp.ID, "")
+		if err != nil {
+			respondWithError(w, http.StatusInternalServerError, "Something went wrong while deleting the chirp")
+			return
+		}
+
+		err = qtx.TombstoneChirp(r.Context(), chirp.ID)
+	} else {
+		err = qtx.DeleteChirpByID(r.Context(), chirp.ID)
+	}
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Something went wrong while deleting the chirp")
+		return
+	}
+
+	err = enqueueWebhookEvent(r.Context(), qtx, eventChirpDeleted, uuid.NullUUID{}, ChirpDeletedEvent{ID: chirp.ID})
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Something went wrong while queueing webhooks")
+		return
+	}
+
+	if err := tx.Commit(); err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Something went wrong while deleting the chirp")
+		return
+	}
+
+	cfg.deleteBlobs(r.Context(), storageKeys)
+	cfg.publishChirpEvent(eventChirpDeleted, chirp.UserID, ChirpDeletedEvent{ID: chirp.ID})
+
 	w.WriteHeader(http.StatusNoContent)
 }
 
-func getCleanedBody(body string) string {
-	profanities := []string{"kerfuffle", "sharbert", "fornax"}
-	words := strings.Split(body, " ")
-	cleanedMessage := make([]string, 0, len(words))
+// requireOwnedChirp loads the chirp named by the {chirpID} path value and
+// checks that it was written by userID.
+func (cfg *apiConfig) requireOwnedChirp(r *http.Request, userID uuid.UUID) (database.Chirp, int, string, bool) {
+	chirp, code, msg, ok := cfg.requirePathChirp(r)
+	if !ok {
+		return database.Chirp{}, code, msg, false
+	}
 
-	for _, word := range words {
-		isProfane := false
+	if chirp.UserID != userID {
+		return database.Chirp{}, http.StatusForbidden, "You are not allowed to modify this chirp", false
+	}
 
-		for _, profanity := range profanities {
-			if strings.ToLower(word) == profanity {
-				isProfane = true
-				break
-			}
+	return chirp, 0, "", true
+}
+
+func (cfg *apiConfig) requirePathChirp(r *http.Request) (database.Chirp, int, string, bool) {
+	chirpID := r.PathValue("chirpID")
+	if chirpID == "" {
+		return database.Chirp{}, http.StatusBadRequest, "Chirp ID is required", false
+	}
+
+	chirpUUID, err := uuid.Parse(chirpID)
+	if err != nil {
+		return database.Chirp{}, http.StatusBadRequest, "Invalid chirp ID", false
+	}
+
+	chirp, err := cfg.db.GetChirpByID(r.Context(), chirpUUID)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return database.Chirp{}, http.StatusNotFound, "Chirp not found", false
 		}
+		return database.Chirp{}, http.StatusInternalServerError, "Something went wrong while fetching the chirp", false
+	}
+
+	return chirp, 0, "", true
+}
+
+func chirpFromDB(chirp database.Chirp) Chirp {
+	body := Chirp{
+		ID:          chirp.ID,
+		CreatedAt:   chirp.CreatedAt,
+		UpdatedAt:   chirp.UpdatedAt,
+		Body:        chirp.Body,
+		UserID:      chirp.UserID,
+		Edited:      chirp.UpdatedAt.After(chirp.CreatedAt) && !chirp.DeletedAt.Valid,
+		Deleted:     chirp.DeletedAt.Valid,
+		Reactions:   map[string]int64{},
+		Attachments: []Attachment{},
+	}
 
-		if isProfane {
-			cleanedMessage = append(cleanedMessage, "****")
-		} else {
-			cleanedMessage = append(cleanedMessage, word)
+	if chirp.ReplyTo.Valid {
+		replyTo := chirp.ReplyTo.UUID
+		body.ReplyTo = &replyTo
+	}
+
+	return body
+}
+
+type cleanedChirpBody struct {
+	Body    string
+	Flagged []string
+}
+
+// validateChirpBody enforces the length limit and runs the body through the
+// profanity filter. What happens to a match depends on cfg.profanityMode.
+func (cfg *apiConfig) validateChirpBody(ctx context.Context, body string) (cleanedChirpBody, int, string, bool) {
+	maxLength := 140
+	if len(body) > maxLength {
+		return cleanedChirpBody{}, http.StatusBadRequest, "Chirp is too long", false
+	}
+
+	result, err := cfg.profanityFilter.Scan(ctx, body)
+	if err != nil {
+		return cleanedChirpBody{}, http.StatusInternalServerError, "Something went wrong while checking the chirp", false
+	}
+
+	switch cfg.profanityMode {
+	case profanity.ModeReject:
+		if len(result.Matches) > 0 {
+			return cleanedChirpBody{}, http.StatusBadRequest, "Chirp contains profanity", false
 		}
+		return cleanedChirpBody{Body: body}, 0, "", true
+	case profanity.ModeFlag:
+		return cleanedChirpBody{Body: body, Flagged: result.Matches}, 0, "", true
+	default:
+		return cleanedChirpBody{Body: result.Masked}, 0, "", true
+	}
+}
+
+// decorateChirps loads reactions and attachments for every chirp in groups,
+// one query for each regardless of how many chirps there are.
+func (cfg *apiConfig) decorateChirps(ctx context.Context, viewerID uuid.NullUUID, groups ...[]Chirp) error {
+	err := cfg.attachReactions(ctx, viewerID, groups...)
+	if err != nil {
+		return err
+	}
+
+	return cfg.attachMedia(ctx, groups...)
+}
+
+// respondWithChirp writes a single chirp with its reactions and attachments.
+func (cfg *apiConfig) respondWithChirp(w http.ResponseWriter, r *http.Request, chirp database.Chirp, viewerID uuid.NullUUID) {
+	response := []Chirp{chirpFromDB(chirp)}
+	err := cfg.decorateChirps(r.Context(), viewerID, response)
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Something went wrong while fetching chirp details")
+		return
+	}
+
+	respondWithJSON(w, http.StatusOK, response[0])
+}
+
+// respondWithChirpsPage writes a ChirpsPage for a keyset-paginated query.
+// chirps must come from a query that fetched limit+1 rows, the extra row
+// only tells us whether there is a next page.
+func (cfg *apiConfig) respondWithChirpsPage(w http.ResponseWriter, r *http.Request, chirps []database.Chirp, limit int32, viewerID uuid.NullUUID) {
+	nextCursor := ""
+	if len(chirps) > int(limit) {
+		chirps = chirps[:limit]
+		last := chirps[len(chirps)-1]
+		nextCursor = encodeCursor(last.CreatedAt, last.ID)
+	}
+
+	response := make([]Chirp, 0, len(chirps))
+	for _, chirp := range chirps {
+		response = append(response, chirpFromDB(chirp))
 	}
 
-	return strings.Join(cleanedMessage, " ")
+	err := cfg.decorateChirps(r.Context(), viewerID, response)
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Something went wrong while fetching chirp details")
+		return
+	}
+
+	respondWithJSON(w, http.StatusOK, ChirpsPage{
+		Chirps:     response,
+		NextCursor: nextCursor,
+	})
 }
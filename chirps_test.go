@@ -0,0 +1,60 @@
+package main
+
+import (
+	"database/sql/driver"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/dennisdijkstra/go/internal/auth"
+	"github.com/dennisdijkstra/go/internal/profanity"
+	"github.com/google/uuid"
+)
+
+func TestUpdateChirpWithUnchangedBodyIncludesDetails(t *testing.T) {
+	f := newFakeDB(t)
+	userID, chirpID := uuid.New(), uuid.New()
+	now := time.Now()
+	chirpRow := []driver.Value{chirpID.String(), now, now, "Hello world", userID.String(), nil, nil, nil}
+	for _, name := range []string{"GetChirpByID", "LockChirp"} {
+		f.stub(name, func([]driver.Value) (fakeResult, error) {
+			return fakeResult{rows: [][]driver.Value{chirpRow}}, nil
+		})
+	}
+	f.stub("GetReactionSummaries", func([]driver.Value) (fakeResult, error) {
+		return fakeResult{rows: [][]driver.Value{{chirpID.String(), "like", int64(2), true}}}, nil
+	})
+	f.stub("GetAttachmentsForChirps", func([]driver.Value) (fakeResult, error) {
+		return fakeResult{rows: [][]driver.Value{{uuid.NewString(), now, userID.String(), chirpID.String(), "key", "image/png", int64(10)}}}, nil
+	})
+	cfg := &apiConfig{profanityFilter: profanity.NewWordListFilter(profanity.StaticWords{}, time.Minute)}
+	cfg.dbConn, cfg.db = f.open()
+	token := newTestAccessToken(t, cfg, f, userID, auth.RoleUser)
+
+	req := httptest.NewRequest(http.MethodPut, "/api/chirps/"+chirpID.String(), strings.NewReader(`{"body":"Hello world"}`))
+	req.SetPathValue("chirpID", chirpID.String())
+	req.Header.Set("Authorization", "Bearer "+token)
+	rec := httptest.NewRecorder()
+	cfg.handlerUpdateChirp(rec, req)
+
+	if rec.Code != http.StatusOK {
+		t.Fatalf("expected status %d, got %d: %s", http.StatusOK, rec.Code, rec.Body)
+	}
+	if n := f.called("UpdateChirpBody"); n != 0 {
+		t.Errorf("expected the unchanged chirp not to be updated, got %d", n)
+	}
+
+	var chirp Chirp
+	if err := json.Unmarshal(rec.Body.Bytes(), &chirp); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if chirp.Reactions["like"] != 2 || !chirp.ViewerReacted {
+		t.Errorf("expected the chirp's reactions, got %v", chirp.Reactions)
+	}
+	if len(chirp.Attachments) != 1 {
+		t.Errorf("expected the chirp's attachment, got %d", len(chirp.Attachments))
+	}
+}
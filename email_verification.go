@@ -0,0 +1,247 @@
+package main
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"net/url"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/dennisdijkstra/go/internal/auth"
+	"github.com/dennisdijkstra/go/internal/database"
+	"github.com/dennisdijkstra/go/internal/mail"
+	"github.com/dennisdijkstra/go/internal/throttle"
+	"github.com/google/uuid"
+)
+
+const emailVerificationLifetime = 24 * time.Hour
+
+// Verification emails are throttled per user, so nobody can loop on
+// resending, and per address, so nobody can point many accounts, or one
+// account's email changes, at someone else's inbox.
+var (
+	userVerificationEmailPolicy = throttle.Policy{
+		Threshold:   3,
+		BaseLockout: 15 * time.Minute,
+		MaxLockout:  24 * time.Hour,
+		Window:      time.Hour,
+	}
+	addressVerificationEmailPolicy = throttle.Policy{
+		Threshold:   3,
+		BaseLockout: time.Hour,
+		MaxLockout:  24 * time.Hour,
+		Window:      24 * time.Hour,
+	}
+)
+
+type VerifyEmailParams struct {
+	Token string `json:"token"`
+}
+
+func (cfg *apiConfig) handlerVerifyEmail(w http.ResponseWriter, r *http.Request) {
+	decoder := json.NewDecoder(r.Body)
+	params := VerifyEmailParams{}
+	err := decoder.Decode(&params)
+
+	if err != nil {
+		respondWithError(w, http.StatusBadRequest, "Something went wrong while decoding the request body")
+		return
+	}
+
+	tx, err := cfg.dbConn.BeginTx(r.Context(), nil)
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Something went wrong while verifying the email address")
+		return
+	}
+	defer tx.Rollback()
+	qtx := cfg.db.WithTx(tx)
+
+	token, err := qtx.UseEmailVerificationToken(r.Context(), auth.HashOneTimeToken(params.Token))
+	if err != nil {
+		if err == sql.ErrNoRows {
+			respondWithError(w, http.StatusBadRequest, "Invalid or expired verification token")
+			return
+		}
+		respondWithError(w, http.StatusInternalServerError, "Something went wrong while verifying the email address")
+		return
+	}
+
+	// The token only verifies the address it was sent to, so it is useless
+	// once the user has changed their email.
+	user, err := qtx.MarkUserEmailVerified(r.Context(), database.MarkUserEmailVerifiedParams{
+		ID:    token.UserID,
+		Email: token.Email,
+	})
+	if err != nil {
+		if err == sql.ErrNoRows {
+			respondWithError(w, http.StatusBadRequest, "Invalid or expired verification token")
+			return
+		}
+		respondWithError(w, http.StatusInternalServerError, "Something went wrong while verifying the email address")
+		return
+	}
+
+	if err := tx.Commit(); err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Something went wrong while verifying the email address")
+		return
+	}
+
+	respondWithJSON(w, http.StatusOK, userFromDB(user))
+}
+
+func (cfg *apiConfig) handlerResendEmailVerification(w http.ResponseWriter, r *http.Request) {
+	userID, code, msg, ok := cfg.requireJWTUserID(r)
+	if !ok {
+		respondWithError(w, code, msg)
+		return
+	}
+
+	user, err := cfg.db.GetUserByID(r.Context(), userID)
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Something went wrong while fetching the user")
+		return
+	}
+
+	if user.EmailVerifiedAt.Valid {
+		respondWithError(w, http.StatusConflict, "Email address is already verified")
+		return
+	}
+
+	wait, err := cfg.reserveVerificationEmail(r.Context(), user.ID, user.Email)
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Something went wrong while checking verification email limits")
+		return
+	}
+
+	if wait > 0 {
+		respondWithVerificationEmailLockout(w, wait)
+		return
+	}
+
+	err = cfg.sendEmailVerification(r.Context(), user)
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Something went wrong while sending the verification email")
+		return
+	}
+
+	w.WriteHeader(http.StatusAccepted)
+}
+
+// reserveVerificationEmail counts a verification email to address against
+// the user and the address before it is sent, and returns how long to wait
+// instead if either is locked out.
+func (cfg *apiConfig) reserveVerificationEmail(ctx context.Context, userID uuid.UUID, address string) (time.Duration, error) {
+	return cfg.reserveAttempt(ctx,
+		throttledKey{Key: "verify-user:" + userID.String(), Policy: userVerificationEmailPolicy},
+		throttledKey{Key: "verify-email:" + strings.ToLower(strings.TrimSpace(address)), Policy: addressVerificationEmailPolicy},
+	)
+}
+
+func respondWithVerificationEmailLockout(w http.ResponseWriter, wait time.Duration) {
+	w.Header().Set("Retry-After", strconv.Itoa(throttle.RetryAfter(wait)))
+	respondWithError(w, http.StatusTooManyRequests, "Too many verification emails, try again later")
+}
+
+// sendEmailVerification replaces any unused verification tokens for the
+// user with a new one and mails it to their current address.
+func (cfg *apiConfig) sendEmailVerification(ctx context.Context, user database.User) error {
+	token, err := auth.MakeOneTimeToken()
+	if err != nil {
+		return err
+	}
+
+	tx, err := cfg.dbConn.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+	qtx := cfg.db.WithTx(tx)
+
+	err = qtx.DeleteUnusedEmailVerificationTokens(ctx, user.ID)
+	if err != nil {
+		return err
+	}
+
+	err = qtx.CreateEmailVerificationToken(ctx, database.CreateEmailVerificationTokenParams{
+		TokenHash: auth.HashOneTimeToken(token),
+		UserID:    user.ID,
+		Email:     user.Email,
+		ExpiresAt: time.Now().UTC().Add(emailVerificationLifetime),
+	})
+	if err != nil {
+		return err
+	}
+
+	if err := tx.Commit(); err != nil {
+		return err
+	}
+
+	link := cfg.appURL + "/verify-email?token=" + url.QueryEscape(token)
+	return cfg.mailer.Send(ctx, mail.Message{
+		To:      user.Email,
+		Subject: "Verify your Chirpy email address",
+		Body: fmt.Sprintf(
+			"Welcome to Chirpy!\n\nConfirm your email address by opening this link within %d hours:\n\n%s\n\nIf you did not sign up, you can ignore this email.\n",
+			int(emailVerificationLifetime.Hours()), link,
+		),
+	})
+}
+
+// sendEmailVerificationAsync mails a verification token without holding up
+// the response. Users can ask for a new one if it fails.
+func (cfg *apiConfig) sendEmailVerificationAsync(user database.User) {
+	go func() {
+		ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+		defer cancel()
+
+		if err := cfg.sendEmailVerification(ctx, user); err != nil {
+			log.Printf("Error sending verification email to user %s: %s", user.ID, err)
+		}
+	}()
+}
+
+// mailerFromEnv picks the mailer named by MAILER. The log and file mailers
+// write out live tokens, so they are only allowed in development, where the
+// log mailer is the default and no mail server is needed.
+func mailerFromEnv(environment string) (mail.Mailer, error) {
+	from := os.Getenv("MAIL_FROM")
+	if from == "" {
+		from = "Chirpy <no-reply@chirpy.local>"
+	}
+
+	mailer := os.Getenv("MAILER")
+	if environment != "dev" && mailer != "smtp" {
+		return nil, errors.New("MAILER must be smtp outside development")
+	}
+
+	switch mailer {
+	case "", "log":
+		return mail.LogMailer{}, nil
+	case "file":
+		dir := os.Getenv("MAIL_DIR")
+		if dir == "" {
+			dir = "mail"
+		}
+		return mail.NewFileMailer(dir, from)
+	case "smtp":
+		addr := os.Getenv("SMTP_ADDR")
+		if addr == "" {
+			return nil, errors.New("SMTP_ADDR must be set when MAILER is smtp")
+		}
+		return mail.SMTPMailer{
+			Addr:     addr,
+			Username: os.Getenv("SMTP_USERNAME"),
+			Password: os.Getenv("SMTP_PASSWORD"),
+			From:     from,
+		}, nil
+	}
+
+	return nil, errors.New("MAILER must be one of log, file or smtp")
+}
@@ -0,0 +1,164 @@
+package main
+
+import (
+	"context"
+	"database/sql/driver"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/dennisdijkstra/go/internal/auth"
+	"github.com/dennisdijkstra/go/internal/mail"
+	"github.com/google/uuid"
+)
+
+func TestMailerFromEnv(t *testing.T) {
+	tests := []struct {
+		environment string
+		mailer      string
+		ok          bool
+	}{
+		{"dev", "", true},
+		{"dev", "log", true},
+		{"dev", "file", true},
+		{"prod", "", false},
+		{"prod", "log", false},
+		{"prod", "file", false},
+		{"prod", "smtp", true},
+		{"prod", "carrier-pigeon", false},
+	}
+
+	for _, tt := range tests {
+		t.Setenv("MAILER", tt.mailer)
+		t.Setenv("MAIL_DIR", t.TempDir())
+		t.Setenv("SMTP_ADDR", "smtp.example.com:587")
+
+		mailer, err := mailerFromEnv(tt.environment)
+		if tt.ok && err != nil {
+			t.Errorf("MAILER=%q in %s: unexpected error: %v", tt.mailer, tt.environment, err)
+		}
+		if !tt.ok && err == nil {
+			t.Errorf("MAILER=%q in %s: expected an error, got %T", tt.mailer, tt.environment, mailer)
+		}
+	}
+
+	t.Setenv("MAILER", "")
+	if mailer, _ := mailerFromEnv("dev"); mailer != (mail.LogMailer{}) {
+		t.Errorf("expected the log mailer by default in development, got %T", mailer)
+	}
+}
+
+// recordingMailer counts the messages it is asked to send.
+type recordingMailer struct {
+	mu   sync.Mutex
+	sent []mail.Message
+}
+
+func (m *recordingMailer) Send(_ context.Context, msg mail.Message) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.sent = append(m.sent, msg)
+	return nil
+}
+
+// newVerificationTestConfig sets up an unverified user whose address is
+// email, and returns an access token for them.
+func newVerificationTestConfig(t *testing.T, userID uuid.UUID, email string) (*apiConfig, *fakeDB, map[string]*fakeThrottle, string) {
+	t.Helper()
+
+	f := newFakeDB(t)
+	throttles := stubLoginThrottles(f)
+	now := time.Now()
+	f.stub("GetUserByID", func([]driver.Value) (fakeResult, error) {
+		return fakeResult{rows: [][]driver.Value{{
+			userID.String(), now, now, email, "hash", false, time.Unix(0, 0), auth.RoleUser, nil,
+		}}}, nil
+	})
+	f.stub("DeleteUnusedEmailVerificationTokens", func([]driver.Value) (fakeResult, error) {
+		return fakeResult{}, nil
+	})
+	f.stub("CreateEmailVerificationToken", func([]driver.Value) (fakeResult, error) {
+		return fakeResult{rowsAffected: 1}, nil
+	})
+
+	cfg := &apiConfig{mailer: &recordingMailer{}}
+	cfg.dbConn, cfg.db = f.open()
+	token := newTestAccessToken(t, cfg, f, userID, auth.RoleUser)
+	return cfg, f, throttles, token
+}
+
+func TestResendEmailVerificationIsThrottled(t *testing.T) {
+	cfg, _, _, token := newVerificationTestConfig(t, uuid.New(), "user@example.com")
+
+	resend := func() *httptest.ResponseRecorder {
+		req := httptest.NewRequest(http.MethodPost, "/api/users/verify/resend", nil)
+		req.Header.Set("Authorization", "Bearer "+token)
+		rec := httptest.NewRecorder()
+		cfg.handlerResendEmailVerification(rec, req)
+		return rec
+	}
+
+	for i := range userVerificationEmailPolicy.Threshold {
+		if rec := resend(); rec.Code != http.StatusAccepted {
+			t.Fatalf("request %d: expected 202, got %d", i+1, rec.Code)
+		}
+	}
+
+	rec := resend()
+	if rec.Code != http.StatusTooManyRequests {
+		t.Fatalf("expected 429, got %d", rec.Code)
+	}
+	if rec.Header().Get("Retry-After") == "" {
+		t.Error("expected a Retry-After header")
+	}
+
+	if sent := len(cfg.mailer.(*recordingMailer).sent); sent != userVerificationEmailPolicy.Threshold {
+		t.Errorf("expected %d emails, got %d", userVerificationEmailPolicy.Threshold, sent)
+	}
+}
+
+func updateUser(cfg *apiConfig, token, body string) *httptest.ResponseRecorder {
+	req := httptest.NewRequest(http.MethodPut, "/api/users", strings.NewReader(body))
+	req.Header.Set("Authorization", "Bearer "+token)
+	rec := httptest.NewRecorder()
+	cfg.handlerUpdateUser(rec, req)
+	return rec
+}
+
+func TestUpdateUserRejectsInvalidEmail(t *testing.T) {
+	cfg, f, _, token := newVerificationTestConfig(t, uuid.New(), "user@example.com")
+
+	for _, email := range []string{"", "not-an-address", "Victim <victim@example.com>"} {
+		rec := updateUser(cfg, token, `{"email":"`+email+`","password":"new password"}`)
+		if rec.Code != http.StatusBadRequest {
+			t.Errorf("email %q: expected 400, got %d", email, rec.Code)
+		}
+	}
+
+	if n := f.called("UpdateUser"); n != 0 {
+		t.Errorf("expected no update, got %d", n)
+	}
+}
+
+func TestUpdateUserThrottlesVerificationEmailsToAddress(t *testing.T) {
+	cfg, f, throttles, token := newVerificationTestConfig(t, uuid.New(), "user@example.com")
+
+	// Other accounts have already sent the address as many emails as it
+	// takes.
+	throttles["verify-email:victim@example.com"] = &fakeThrottle{
+		failures:      int64(addressVerificationEmailPolicy.Threshold),
+		lastFailureAt: time.Now(),
+		lockedUntil:   time.Now().Add(time.Hour),
+	}
+
+	rec := updateUser(cfg, token, `{"email":"Victim@example.com","password":"new password"}`)
+	if rec.Code != http.StatusTooManyRequests {
+		t.Fatalf("expected 429, got %d", rec.Code)
+	}
+	if n := f.called("UpdateUser"); n != 0 {
+		t.Errorf("expected no update, got %d", n)
+	}
+}
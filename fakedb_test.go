@@ -0,0 +1,199 @@
+package main
+
+import (
+	"context"
+	"database/sql"
+	"database/sql/driver"
+	"fmt"
+	"io"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/dennisdijkstra/go/internal/database"
+)
+
+// fakeResult is what a stubbed query returns: rows for queries that read,
+// rowsAffected for ones that only write.
+type fakeResult struct {
+	rows         [][]driver.Value
+	rowsAffected int64
+}
+
+// fakeQuery answers one sqlc query. args are the query's parameters after
+// database/sql has converted them to driver values.
+type fakeQuery func(args []driver.Value) (fakeResult, error)
+
+// fakeDB is a database/sql driver for handler tests. Each query is
+// answered by the stub registered under its sqlc name, so a test only
+// stubs what the code under test runs. Stubs run one at a time, and so do
+// transactions, which stands in for the row locks the real queries take.
+type fakeDB struct {
+	t *testing.T
+
+	txMu sync.Mutex
+
+	mu      sync.Mutex
+	queries map[string]fakeQuery
+	calls   []string
+}
+
+func newFakeDB(t *testing.T) *fakeDB {
+	return &fakeDB{t: t, queries: map[string]fakeQuery{}}
+}
+
+// stub registers the answer to the query called name.
+func (f *fakeDB) stub(name string, query fakeQuery) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.queries[name] = query
+}
+
+// open returns the connections a handler under test runs its queries on.
+func (f *fakeDB) open() (*sql.DB, *database.Queries) {
+	db := sql.OpenDB(fakeConnector{db: f})
+	f.t.Cleanup(func() { db.Close() })
+	return db, database.New(db)
+}
+
+// called reports how many times the query called name has run.
+func (f *fakeDB) called(name string) int {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	n := 0
+	for _, call := range f.calls {
+		if call == name {
+			n++
+		}
+	}
+	return n
+}
+
+func (f *fakeDB) run(query string, named []driver.NamedValue) (fakeResult, error) {
+	name := queryName(query)
+
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	f.calls = append(f.calls, name)
+	stub, ok := f.queries[name]
+	if !ok {
+		return fakeResult{}, fmt.Errorf("fakedb: no stub for query %q", name)
+	}
+
+	args := make([]driver.Value, len(named))
+	for i, arg := range named {
+		args[i] = arg.Value
+	}
+	return stub(args)
+}
+
+// queryName reads the name out of sqlc's "-- name: X :kind" header.
+func queryName(query string) string {
+	header, _, _ := strings.Cut(query, "\n")
+	fields := strings.Fields(strings.TrimPrefix(header, "-- name:"))
+	if len(fields) == 0 {
+		return ""
+	}
+	return fields[0]
+}
+
+type fakeConnector struct {
+	db *fakeDB
+}
+
+func (c fakeConnector) Connect(context.Context) (driver.Conn, error) {
+	return fakeConn(c), nil
+}
+
+func (c fakeConnector) Driver() driver.Driver {
+	return fakeDriver{}
+}
+
+type fakeDriver struct{}
+
+func (fakeDriver) Open(string) (driver.Conn, error) {
+	return nil, fmt.Errorf("fakedb: open through fakeDB.open")
+}
+
+type fakeConn struct {
+	db *fakeDB
+}
+
+func (c fakeConn) Prepare(string) (driver.Stmt, error) {
+	return nil, fmt.Errorf("fakedb: prepared statements are not supported")
+}
+
+func (c fakeConn) Close() error { return nil }
+
+func (c fakeConn) Begin() (driver.Tx, error) {
+	return c.BeginTx(context.Background(), driver.TxOptions{})
+}
+
+func (c fakeConn) BeginTx(context.Context, driver.TxOptions) (driver.Tx, error) {
+	c.db.txMu.Lock()
+	return &fakeTx{db: c.db}, nil
+}
+
+func (c fakeConn) QueryContext(_ context.Context, query string, args []driver.NamedValue) (driver.Rows, error) {
+	result, err := c.db.run(query, args)
+	if err != nil {
+		return nil, err
+	}
+	return &fakeRows{result: result}, nil
+}
+
+func (c fakeConn) ExecContext(_ context.Context, query string, args []driver.NamedValue) (driver.Result, error) {
+	result, err := c.db.run(query, args)
+	if err != nil {
+		return nil, err
+	}
+	return driver.RowsAffected(result.rowsAffected), nil
+}
+
+// fakeTx does not roll anything back; tests check what a handler wrote,
+// not what survived.
+type fakeTx struct {
+	db   *fakeDB
+	once sync.Once
+}
+
+func (tx *fakeTx) Commit() error {
+	tx.once.Do(tx.db.txMu.Unlock)
+	return nil
+}
+
+func (tx *fakeTx) Rollback() error {
+	tx.once.Do(tx.db.txMu.Unlock)
+	return nil
+}
+
+type fakeRows struct {
+	result fakeResult
+	next   int
+}
+
+// Columns names the columns by position; sqlc scans by position anyway.
+func (r *fakeRows) Columns() []string {
+	if len(r.result.rows) == 0 {
+		return nil
+	}
+
+	columns := make([]string, len(r.result.rows[0]))
+	for i := range columns {
+		columns[i] = fmt.Sprintf("column%d", i)
+	}
+	return columns
+}
+
+func (r *fakeRows) Close() error { return nil }
+
+func (r *fakeRows) Next(dest []driver.Value) error {
+	if r.next >= len(r.result.rows) {
+		return io.EOF
+	}
+	copy(dest, r.result.rows[r.next])
+	r.next++
+	return nil
+}
@@ -0,0 +1,210 @@
+package main
+
+import (
+	"database/sql"
+	"net/http"
+	"time"
+
+	"github.com/dennisdijkstra/go/internal/database"
+	"github.com/google/uuid"
+)
+
+type Follow struct {
+	UserID     uuid.UUID `json:"user_id"`
+	FollowedAt time.Time `json:"followed_at"`
+}
+
+type FollowsPage struct {
+	Users      []Follow `json:"users"`
+	NextCursor string   `json:"next_cursor"`
+}
+
+func (cfg *apiConfig) handlerFollowUser(w http.ResponseWriter, r *http.Request) {
+	userID, code, msg, ok := cfg.requireJWTUserID(r)
+	if !ok {
+		respondWithError(w, code, msg)
+		return
+	}
+
+	followee, code, msg, ok := cfg.requirePathUser(r)
+	if !ok {
+		respondWithError(w, code, msg)
+		return
+	}
+
+	if followee.ID == userID {
+		respondWithError(w, http.StatusBadRequest, "You cannot follow yourself")
+		return
+	}
+
+	err := cfg.db.CreateFollow(r.Context(), database.CreateFollowParams{
+		FollowerID: userID,
+		FolloweeID: followee.ID,
+	})
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Something went wrong while following the user")
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func (cfg *apiConfig) handlerUnfollowUser(w http.ResponseWriter, r *http.Request) {
+	userID, code, msg, ok := cfg.requireJWTUserID(r)
+	if !ok {
+		respondWithError(w, code, msg)
+		return
+	}
+
+	followeeID, err := uuid.Parse(r.PathValue("userID"))
+	if err != nil {
+		respondWithError(w, http.StatusBadRequest, "Invalid user ID")
+		return
+	}
+
+	deleted, err := cfg.db.DeleteFollow(r.Context(), database.DeleteFollowParams{
+		FollowerID: userID,
+		FolloweeID: followeeID,
+	})
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Something went wrong while unfollowing the user")
+		return
+	}
+
+	if deleted == 0 {
+		respondWithError(w, http.StatusNotFound, "You are not following this user")
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func (cfg *apiConfig) handlerGetFollowers(w http.ResponseWriter, r *http.Request) {
+	user, code, msg, ok := cfg.requirePathUser(r)
+	if !ok {
+		respondWithError(w, code, msg)
+		return
+	}
+
+	limit, cursorCreatedAt, cursorID, err := parsePageParams(r)
+	if err != nil {
+		respondWithError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	rows, err := cfg.db.GetFollowersPage(r.Context(), database.GetFollowersPageParams{
+		UserID:          user.ID,
+		CursorCreatedAt: cursorCreatedAt,
+		CursorID:        cursorID,
+		PageSize:        limit + 1,
+	})
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Something went wrong while fetching followers")
+		return
+	}
+
+	follows := make([]Follow, 0, len(rows))
+	for _, row := range rows {
+		follows = append(follows, Follow{UserID: row.UserID, FollowedAt: row.CreatedAt})
+	}
+
+	respondWithJSON(w, http.StatusOK, newFollowsPage(follows, limit))
+}
+
+func (cfg *apiConfig) handlerGetFollowing(w http.ResponseWriter, r *http.Request) {
+	user, code, msg, ok := cfg.requirePathUser(r)
+	if !ok {
+		respondWithError(w, code, msg)
+		return
+	}
+
+	limit, cursorCreatedAt, cursorID, err := parsePageParams(r)
+	if err != nil {
+		respondWithError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	rows, err := cfg.db.GetFollowingPage(r.Context(), database.GetFollowingPageParams{
+		UserID:          user.ID,
+		CursorCreatedAt: cursorCreatedAt,
+		CursorID:        cursorID,
+		PageSize:        limit + 1,
+	})
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Something went wrong while fetching followed users")
+		return
+	}
+
+	follows := make([]Follow, 0, len(rows))
+	for _, row := range rows {
+		follows = append(follows, Follow{UserID: row.UserID, FollowedAt: row.CreatedAt})
+	}
+
+	respondWithJSON(w, http.StatusOK, newFollowsPage(follows, limit))
+}
+
+// handlerGetTimeline returns chirps by the users the caller follows, newest
+// first.
+func (cfg *apiConfig) handlerGetTimeline(w http.ResponseWriter, r *http.Request) {
+	userID, code, msg, ok := cfg.requireJWTUserID(r)
+	if !ok {
+		respondWithError(w, code, msg)
+		return
+	}
+
+	limit, cursorCreatedAt, cursorID, err := parsePageParams(r)
+	if err != nil {
+		respondWithError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	chirps, err := cfg.db.GetTimelinePage(r.Context(), database.GetTimelinePageParams{
+		UserID:          userID,
+		CursorCreatedAt: cursorCreatedAt,
+		CursorID:        cursorID,
+		PageSize:        limit + 1,
+	})
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Something went wrong while fetching the timeline")
+		return
+	}
+
+	cfg.respondWithChirpsPage(w, r, chirps, limit, uuid.NullUUID{UUID: userID, Valid: true})
+}
+
+// requirePathUser loads the user named by the {userID} path value.
+func (cfg *apiConfig) requirePathUser(r *http.Request) (database.User, int, string, bool) {
+	userID := r.PathValue("userID")
+	if userID == "" {
+		return database.User{}, http.StatusBadRequest, "User ID is required", false
+	}
+
+	userUUID, err := uuid.Parse(userID)
+	if err != nil {
+		return database.User{}, http.StatusBadRequest, "Invalid user ID", false
+	}
+
+	user, err := cfg.db.GetUserByID(r.Context(), userUUID)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return database.User{}, http.StatusNotFound, "User not found", false
+		}
+		return database.User{}, http.StatusInternalServerError, "Something went wrong while fetching the user", false
+	}
+
+	return user, 0, "", true
+}
+
+func newFollowsPage(follows []Follow, limit int32) FollowsPage {
+	nextCursor := ""
+	if len(follows) > int(limit) {
+		follows = follows[:limit]
+		last := follows[len(follows)-1]
+		nextCursor = encodeCursor(last.FollowedAt, last.UserID)
+	}
+
+	return FollowsPage{
+		Users:      follows,
+		NextCursor: nextCursor,
+	}
+}
@@ -0,0 +1,150 @@
+package main
+
+import (
+	"context"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/dennisdijkstra/go/internal/database"
+	"github.com/dennisdijkstra/go/internal/extract"
+	"github.com/google/uuid"
+)
+
+const (
+	defaultTrendingWindow = 24 * time.Hour
+	maxTrendingWindow     = 30 * 24 * time.Hour
+)
+
+type TrendingHashtag struct {
+	Tag  string `json:"tag"`
+	Uses int64  `json:"uses"`
+}
+
+func (cfg *apiConfig) handlerGetHashtagChirps(w http.ResponseWriter, r *http.Request) {
+	tag := strings.ToLower(strings.TrimPrefix(r.PathValue("tag"), "#"))
+	if tag == "" {
+		respondWithError(w, http.StatusBadRequest, "Hashtag is required")
+		return
+	}
+
+	limit, cursorCreatedAt, cursorID, err := parsePageParams(r)
+	if err != nil {
+		respondWithError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	chirps, err := cfg.db.GetHashtagChirpsPage(r.Context(), database.GetHashtagChirpsPageParams{
+		Tag:             tag,
+		CursorCreatedAt: cursorCreatedAt,
+		CursorID:        cursorID,
+		PageSize:        limit + 1,
+	})
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Something went wrong while fetching chirps")
+		return
+	}
+
+	cfg.respondWithChirpsPage(w, r, chirps, limit, cfg.optionalJWTUserID(r))
+}
+
+func (cfg *apiConfig) handlerGetMentions(w http.ResponseWriter, r *http.Request) {
+	userID, code, msg, ok := cfg.requireJWTUserID(r)
+	if !ok {
+		respondWithError(w, code, msg)
+		return
+	}
+
+	limit, cursorCreatedAt, cursorID, err := parsePageParams(r)
+	if err != nil {
+		respondWithError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	chirps, err := cfg.db.GetMentionChirpsPage(r.Context(), database.GetMentionChirpsPageParams{
+		UserID:          userID,
+		CursorCreatedAt: cursorCreatedAt,
+		CursorID:        cursorID,
+		PageSize:        limit + 1,
+	})
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Something went wrong while fetching mentions")
+		return
+	}
+
+	cfg.respondWithChirpsPage(w, r, chirps, limit, uuid.NullUUID{UUID: userID, Valid: true})
+}
+
+// handlerGetTrendingHashtags ranks hashtags by how often they were used
+// within ?window= (a Go duration such as 6h, default 24h).
+func (cfg *apiConfig) handlerGetTrendingHashtags(w http.ResponseWriter, r *http.Request) {
+	window := defaultTrendingWindow
+	if windowQuery := r.URL.Query().Get("window"); windowQuery != "" {
+		parsed, err := time.ParseDuration(windowQuery)
+		if err != nil || parsed <= 0 || parsed > maxTrendingWindow {
+			respondWithError(w, http.StatusBadRequest, "Invalid window")
+			return
+		}
+		window = parsed
+	}
+
+	limit, err := parseLimit(r)
+	if err != nil {
+		respondWithError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	rows, err := cfg.db.GetTrendingHashtags(r.Context(), database.GetTrendingHashtagsParams{
+		Since:    time.Now().Add(-window),
+		PageSize: limit,
+	})
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Something went wrong while fetching trending hashtags")
+		return
+	}
+
+	response := make([]TrendingHashtag, 0, len(rows))
+	for _, row := range rows {
+		response = append(response, TrendingHashtag{Tag: row.Tag, Uses: row.Uses})
+	}
+
+	respondWithJSON(w, http.StatusOK, response)
+}
+
+// indexChirpEntities replaces the hashtags and mentions stored for a chirp
+// with the ones found in body. The rows take the chirp's created_at, so
+// editing a chirp doesn't bring its old hashtags back into trending. Run it
+// with the queries of the transaction that writes the chirp.
+func indexChirpEntities(ctx context.Context, q *database.Queries, chirpID uuid.UUID, body string) error {
+	err := q.DeleteChirpHashtags(ctx, chirpID)
+	if err != nil {
+		return err
+	}
+
+	err = q.DeleteChirpMentions(ctx, chirpID)
+	if err != nil {
+		return err
+	}
+
+	if tags := extract.Hashtags(body); len(tags) > 0 {
+		err = q.CreateChirpHashtags(ctx, database.CreateChirpHashtagsParams{
+			ChirpID: chirpID,
+			Tags:    tags,
+		})
+		if err != nil {
+			return err
+		}
+	}
+
+	if emails := extract.Mentions(body); len(emails) > 0 {
+		err = q.CreateChirpMentions(ctx, database.CreateChirpMentionsParams{
+			ChirpID: chirpID,
+			Emails:  emails,
+		})
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
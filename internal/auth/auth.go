@@ -2,6 +2,7 @@ package auth
 
 import (
 	"crypto/rand"
+	"crypto/sha256"
 	"encoding/hex"
 	"errors"
 	"net/http"
@@ -39,16 +40,54 @@ func CheckPasswordHash(password, hash string) (bool, error) {
 	return isMatch, nil
 }
 
-func MakeJWT(userID uuid.UUID, tokenSecret string, expiresIn time.Duration) (string, error) {
-	claims := &jwt.RegisteredClaims{
-		Issuer:    "chirpy-access",
-		IssuedAt:  jwt.NewNumericDate(time.Now().UTC()),
-		ExpiresAt: jwt.NewNumericDate(time.Now().UTC().Add(expiresIn)),
-		Subject:   userID.String(),
+// AccessClaims is what a validated access token says about its holder.
+type AccessClaims struct {
+	UserID uuid.UUID
+	// TokenID is the token's jti, or uuid.Nil for a legacy HS256 token.
+	TokenID   uuid.UUID
+	IssuedAt  time.Time
+	ExpiresAt time.Time
+	Role      string
+	Scopes    []string
+}
+
+// accessTokenClaims is the JWT payload. Scopes are space-separated as in
+// OAuth 2.0 (RFC 8693). The standard iat only has whole seconds, so the
+// issue time is also carried as integer microseconds in iat_us for
+// comparing against a revocation cutoff.
+type accessTokenClaims struct {
+	jwt.RegisteredClaims
+	IssuedAtMicros int64  `json:"iat_us,omitempty"`
+	Role           string `json:"role,omitempty"`
+	Scope          string `json:"scope,omitempty"`
+}
+
+// MakeJWT signs an access token with the key set's active signing key and
+// names that key in the kid header. The token carries the user's role and
+// the scopes it grants.
+func MakeJWT(userID uuid.UUID, role string, keys *KeySet, expiresIn time.Duration) (string, error) {
+	now := time.Now().UTC()
+	key, err := keys.signingKey(now)
+	if err != nil {
+		return "", err
+	}
+
+	claims := &accessTokenClaims{
+		RegisteredClaims: jwt.RegisteredClaims{
+			Issuer:    "chirpy-access",
+			IssuedAt:  jwt.NewNumericDate(now),
+			ExpiresAt: jwt.NewNumericDate(now.Add(expiresIn)),
+			Subject:   userID.String(),
+			ID:        uuid.NewString(),
+		},
+		IssuedAtMicros: now.UnixMicro(),
+		Role:           role,
+		Scope:          strings.Join(ScopesForRole(role), " "),
 	}
 
-	token := jwt.NewWithClaims(jwt.SigningMethodHS256, claims)
-	signedString, err := token.SignedString([]byte(tokenSecret))
+	token := jwt.NewWithClaims(key.signingMethod(), claims)
+	token.Header["kid"] = key.ID
+	signedString, err := token.SignedString(key.PrivateKey)
 	if err != nil {
 		return "", err
 	}
@@ -56,35 +95,79 @@ func MakeJWT(userID uuid.UUID, tokenSecret string, expiresIn time.Duration) (str
 	return signedString, nil
 }
 
-func ValidateJWT(tokenString, tokenSecret string) (uuid.UUID, error) {
-	token, err := jwt.ParseWithClaims(tokenString, &jwt.RegisteredClaims{}, func(token *jwt.Token) (any, error) {
-		return []byte(tokenSecret), nil
-	})
-
+func ValidateJWT(tokenString string, keys *KeySet) (uuid.UUID, error) {
+	claims, err := ParseJWT(tokenString, keys)
 	if err != nil {
 		return uuid.Nil, err
 	}
 
-	_, ok := token.Method.(*jwt.SigningMethodHMAC)
-	if !ok || token.Method.Alg() != jwt.SigningMethodHS256.Alg() {
-		return uuid.Nil, errors.New("invalid signing method")
+	return claims.UserID, nil
+}
+
+// ParseJWT checks the signature and expiry of an access token and returns
+// its claims. Whether the token has since been revoked is up to the caller.
+func ParseJWT(tokenString string, keys *KeySet) (AccessClaims, error) {
+	token, err := jwt.ParseWithClaims(
+		tokenString,
+		&accessTokenClaims{},
+		keys.keyFunc,
+		jwt.WithValidMethods([]string{AlgRS256, AlgEdDSA, jwt.SigningMethodHS256.Alg()}),
+		jwt.WithIssuer("chirpy-access"),
+	)
+
+	if err != nil {
+		return AccessClaims{}, err
 	}
 
-	claims, ok := token.Claims.(*jwt.RegisteredClaims)
+	claims, ok := token.Claims.(*accessTokenClaims)
 	if !ok {
-		return uuid.Nil, errors.New("invalid JWT claims: expected *jwt.RegisteredClaims")
+		return AccessClaims{}, errors.New("invalid JWT claims: expected *accessTokenClaims")
 	}
 
 	if !token.Valid {
-		return uuid.Nil, errors.New("token not valid")
+		return AccessClaims{}, errors.New("token not valid")
 	}
 
 	id, err := uuid.Parse(claims.Subject)
 	if err != nil {
-		return uuid.Nil, err
+		return AccessClaims{}, err
 	}
 
-	return id, nil
+	// Tokens signed with the legacy secret predate the jti claim. They get
+	// the nil token ID, so they can only be revoked through the user's
+	// tokens_valid_after.
+	tokenID, err := uuid.Parse(claims.ID)
+	if err != nil {
+		if claims.ID != "" || token.Method.Alg() != jwt.SigningMethodHS256.Alg() {
+			return AccessClaims{}, errors.New("token has no valid jti claim")
+		}
+		tokenID = uuid.Nil
+	}
+
+	if claims.IssuedAt == nil || claims.ExpiresAt == nil {
+		return AccessClaims{}, errors.New("token is missing iat or exp")
+	}
+
+	role := claims.Role
+	if role == "" {
+		role = RoleUser
+	}
+
+	// Tokens minted before iat_us existed fall back to the start of their
+	// iat second, which errs towards treating them as older.
+	issuedAt := claims.IssuedAt.Time
+	if claims.IssuedAtMicros != 0 {
+		issuedAt = time.UnixMicro(claims.IssuedAtMicros)
+	}
+
+	return AccessClaims{
+		UserID:    id,
+		TokenID:   tokenID,
+		IssuedAt:  issuedAt,
+		ExpiresAt: claims.ExpiresAt.Time,
+		Role:      role,
+		Scopes:    strings.Fields(claims.Scope),
+	}, nil
 }
 
 func GetBearerToken(headers http.Header) (string, error) {
@@ -117,6 +200,24 @@ func MakeRefreshToken() (string, error) {
 	return encodedStr, nil
 }
 
+// HashRefreshToken returns the digest stored in place of a refresh token.
+// Tokens carry 256 bits of randomness, so an unsalted SHA-256 is enough to
+// make a database dump useless for impersonation.
+func HashRefreshToken(token string) string {
+	sum := sha256.Sum256([]byte(token))
+	return hex.EncodeToString(sum[:])
+}
+
+// MakeOneTimeToken returns a random token for single-use links such as
+// email verification. Only HashOneTimeToken(token) should be stored.
+func MakeOneTimeToken() (string, error) {
+	return MakeRefreshToken()
+}
+
+func HashOneTimeToken(token string) string {
+	return HashRefreshToken(token)
+}
+
 func GetAPIKey(headers http.Header) (string, error) {
 	authHeader := headers.Get("Authorization")
 	if authHeader == "" {
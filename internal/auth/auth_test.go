@@ -1,18 +1,36 @@
 package auth
 
 import (
+	"context"
+	"crypto/ed25519"
+	"encoding/base64"
+	"strings"
 	"testing"
 	"time"
 
+	"github.com/golang-jwt/jwt/v5"
 	"github.com/google/uuid"
 )
 
+func newTestKeySet(t *testing.T, algorithm string) *KeySet {
+	t.Helper()
+
+	key, err := GenerateSigningKey(algorithm)
+	if err != nil {
+		t.Fatalf("failed to generate signing key: %v", err)
+	}
+
+	keys := NewKeySet("")
+	keys.SetKeys([]SigningKey{key})
+	return keys
+}
+
 func TestJWT(t *testing.T) {
 	userID := uuid.New()
-	tokenSecret := "secret"
+	keys := newTestKeySet(t, AlgRS256)
 	expiresIn := time.Hour
 
-	JWTtoken, err := MakeJWT(userID, tokenSecret, expiresIn)
+	JWTtoken, err := MakeJWT(userID, RoleUser, keys, expiresIn)
 	if err != nil {
 		t.Fatalf("failed to make JWT: %v", err)
 	}
@@ -21,7 +39,7 @@ func TestJWT(t *testing.T) {
 		t.Fatal("expected a non-empty token string")
 	}
 
-	verifiedID, err := ValidateJWT(JWTtoken, tokenSecret)
+	verifiedID, err := ValidateJWT(JWTtoken, keys)
 	if err != nil {
 		t.Fatalf("failed to validate JWT: %v", err)
 	}
@@ -30,3 +48,350 @@ func TestJWT(t *testing.T) {
 		t.Errorf("expected userID %v, got %v", userID, verifiedID)
 	}
 }
+
+func TestParseJWT(t *testing.T) {
+	userID := uuid.New()
+	keys := newTestKeySet(t, AlgEdDSA)
+	before := time.Now()
+
+	first, err := MakeJWT(userID, RoleUser, keys, time.Hour)
+	if err != nil {
+		t.Fatalf("failed to make JWT: %v", err)
+	}
+	second, err := MakeJWT(userID, RoleUser, keys, time.Hour)
+	if err != nil {
+		t.Fatalf("failed to make JWT: %v", err)
+	}
+
+	firstClaims, err := ParseJWT(first, keys)
+	if err != nil {
+		t.Fatalf("failed to parse JWT: %v", err)
+	}
+	secondClaims, err := ParseJWT(second, keys)
+	if err != nil {
+		t.Fatalf("failed to parse JWT: %v", err)
+	}
+
+	if firstClaims.TokenID == secondClaims.TokenID {
+		t.Error("expected every token to get its own jti")
+	}
+
+	// The issue time keeps microseconds so it can be compared to a cutoff.
+	if firstClaims.IssuedAt.Before(before.Truncate(time.Microsecond)) {
+		t.Errorf("expected iat %v to not be before %v", firstClaims.IssuedAt, before)
+	}
+
+	if _, err := ParseJWT(first, newTestKeySet(t, AlgEdDSA)); err == nil {
+		t.Error("expected a token signed with an unknown key to be rejected")
+	}
+}
+
+func TestKeyRotation(t *testing.T) {
+	userID := uuid.New()
+	now := time.Now()
+
+	oldKey, err := GenerateSigningKey(AlgRS256)
+	if err != nil {
+		t.Fatalf("failed to generate signing key: %v", err)
+	}
+	oldKey.ActivatesAt = now.Add(-time.Hour)
+
+	newKey, err := GenerateSigningKey(AlgEdDSA)
+	if err != nil {
+		t.Fatalf("failed to generate signing key: %v", err)
+	}
+	newKey.ActivatesAt = now.Add(time.Minute)
+
+	keys := NewKeySet("")
+	keys.SetKeys([]SigningKey{newKey, oldKey})
+
+	oldToken, err := MakeJWT(userID, RoleUser, keys, time.Hour)
+	if err != nil {
+		t.Fatalf("failed to make JWT: %v", err)
+	}
+
+	if got := len(keys.JWKS().Keys); got != 2 {
+		t.Errorf("expected the pending key to be published, got %d keys", got)
+	}
+
+	// Activate the new key and put the old one in its overlap window.
+	newKey.ActivatesAt = now.Add(-time.Second)
+	oldKey.ExpiresAt = now.Add(time.Hour)
+	keys.SetKeys([]SigningKey{oldKey, newKey})
+
+	newToken, err := MakeJWT(userID, RoleUser, keys, time.Hour)
+	if err != nil {
+		t.Fatalf("failed to make JWT: %v", err)
+	}
+
+	for _, token := range []string{oldToken, newToken} {
+		if _, err := ValidateJWT(token, keys); err != nil {
+			t.Errorf("expected token to validate during the overlap: %v", err)
+		}
+	}
+
+	oldKey.ExpiresAt = now.Add(-time.Second)
+	keys.SetKeys([]SigningKey{oldKey, newKey})
+
+	if _, err := ValidateJWT(oldToken, keys); err == nil {
+		t.Error("expected a token signed with an expired key to be rejected")
+	}
+
+	jwks := keys.JWKS()
+	if len(jwks.Keys) != 1 || jwks.Keys[0].KeyID != newKey.ID || jwks.Keys[0].KeyType != "OKP" {
+		t.Errorf("expected only the new Ed25519 key in the JWKS, got %+v", jwks.Keys)
+	}
+}
+
+func TestPrivateKeyRoundTrip(t *testing.T) {
+	for _, algorithm := range []string{AlgRS256, AlgEdDSA} {
+		key, err := GenerateSigningKey(algorithm)
+		if err != nil {
+			t.Fatalf("failed to generate %s key: %v", algorithm, err)
+		}
+
+		encoded, err := key.MarshalPrivateKey()
+		if err != nil {
+			t.Fatalf("failed to marshal %s key: %v", algorithm, err)
+		}
+
+		if _, err := ParsePrivateKey(algorithm, encoded); err != nil {
+			t.Errorf("failed to parse %s key: %v", algorithm, err)
+		}
+	}
+
+	key, _ := GenerateSigningKey(AlgEdDSA)
+	encoded, _ := key.MarshalPrivateKey()
+	if _, err := ParsePrivateKey(AlgRS256, encoded); err == nil {
+		t.Error("expected a key that does not match its algorithm to be rejected")
+	}
+}
+
+func TestSealPrivateKey(t *testing.T) {
+	kek := make([]byte, 32)
+	for i := range kek {
+		kek[i] = byte(i)
+	}
+
+	key, err := GenerateSigningKey(AlgEdDSA)
+	if err != nil {
+		t.Fatalf("failed to generate signing key: %v", err)
+	}
+
+	sealed, err := key.SealPrivateKey(kek)
+	if err != nil {
+		t.Fatalf("failed to seal private key: %v", err)
+	}
+
+	if !IsSealedPrivateKey(sealed) || strings.Contains(sealed, "PRIVATE KEY") {
+		t.Fatalf("expected an encrypted private key, got %q", sealed)
+	}
+
+	opened, err := OpenPrivateKey(kek, key.ID, AlgEdDSA, sealed)
+	if err != nil {
+		t.Fatalf("failed to open private key: %v", err)
+	}
+	if !key.PrivateKey.(ed25519.PrivateKey).Equal(opened) {
+		t.Error("expected the opened key to match the sealed one")
+	}
+
+	otherKEK := append([]byte(nil), kek...)
+	otherKEK[0] ^= 1
+	if _, err := OpenPrivateKey(otherKEK, key.ID, AlgEdDSA, sealed); err == nil {
+		t.Error("expected a key sealed under another key encryption key to be rejected")
+	}
+
+	if _, err := OpenPrivateKey(kek, "other-kid", AlgEdDSA, sealed); err == nil {
+		t.Error("expected a key sealed for another key ID to be rejected")
+	}
+
+	plain, _ := key.MarshalPrivateKey()
+	if _, err := OpenPrivateKey(kek, key.ID, AlgEdDSA, plain); err == nil {
+		t.Error("expected a plain PEM key to be rejected")
+	}
+}
+
+func TestParseKeyEncryptionKey(t *testing.T) {
+	if _, err := ParseKeyEncryptionKey(base64.StdEncoding.EncodeToString(make([]byte, 32))); err != nil {
+		t.Errorf("expected a 32-byte key to be accepted: %v", err)
+	}
+
+	for _, encoded := range []string{"", "not base64!", base64.StdEncoding.EncodeToString(make([]byte, 16))} {
+		if _, err := ParseKeyEncryptionKey(encoded); err == nil {
+			t.Errorf("expected %q to be rejected", encoded)
+		}
+	}
+}
+
+func TestLegacyHS256(t *testing.T) {
+	userID := uuid.New()
+	// The claims tokens were issued with before signing keys, without a jti.
+	claims := &jwt.RegisteredClaims{
+		Issuer:    "chirpy-access",
+		IssuedAt:  jwt.NewNumericDate(time.Now()),
+		ExpiresAt: jwt.NewNumericDate(time.Now().Add(time.Hour)),
+		Subject:   userID.String(),
+	}
+	token, err := jwt.NewWithClaims(jwt.SigningMethodHS256, claims).SignedString([]byte("secret"))
+	if err != nil {
+		t.Fatalf("failed to sign legacy token: %v", err)
+	}
+
+	parsed, err := ParseJWT(token, NewKeySet("secret"))
+	if err != nil {
+		t.Fatalf("expected legacy token to validate: %v", err)
+	}
+	if parsed.UserID != userID || parsed.TokenID != uuid.Nil || parsed.Role != RoleUser {
+		t.Errorf("unexpected claims for legacy token: %+v", parsed)
+	}
+
+	claims.ID = "not-a-uuid"
+	malformed, err := jwt.NewWithClaims(jwt.SigningMethodHS256, claims).SignedString([]byte("secret"))
+	if err != nil {
+		t.Fatalf("failed to sign legacy token: %v", err)
+	}
+	if _, err := ValidateJWT(malformed, NewKeySet("secret")); err == nil {
+		t.Error("expected a legacy token with a malformed jti to be rejected")
+	}
+
+	if _, err := ValidateJWT(token, NewKeySet("")); err == nil {
+		t.Error("expected legacy token to be rejected without the legacy secret")
+	}
+}
+
+func TestJWTRoleClaims(t *testing.T) {
+	keys := newTestKeySet(t, AlgEdDSA)
+
+	tests := []struct {
+		role     string
+		moderate bool
+		admin    bool
+	}{
+		{RoleUser, false, false},
+		{RoleModerator, true, false},
+		{RoleAdmin, true, true},
+	}
+
+	for _, tt := range tests {
+		token, err := MakeJWT(uuid.New(), tt.role, keys, time.Hour)
+		if err != nil {
+			t.Fatalf("failed to make JWT: %v", err)
+		}
+
+		claims, err := ParseJWT(token, keys)
+		if err != nil {
+			t.Fatalf("failed to parse JWT: %v", err)
+		}
+
+		if claims.Role != tt.role {
+			t.Errorf("expected role %q, got %q", tt.role, claims.Role)
+		}
+		if claims.HasScope(ScopeModerate) != tt.moderate {
+			t.Errorf("%s: expected moderate scope %v", tt.role, tt.moderate)
+		}
+		if claims.HasScope(ScopeAdmin) != tt.admin {
+			t.Errorf("%s: expected admin scope %v", tt.role, tt.admin)
+		}
+	}
+}
+
+type fakeTokenStates struct {
+	state TokenState
+	loads int
+}
+
+func (f *fakeTokenStates) LoadTokenState(ctx context.Context, userID uuid.UUID) (TokenState, error) {
+	f.loads++
+	return f.state, nil
+}
+
+func TestRevocationCache(t *testing.T) {
+	userID := uuid.New()
+	source := &fakeTokenStates{}
+	cache := NewRevocationCache(source, time.Minute)
+	ctx := context.Background()
+
+	claims := AccessClaims{UserID: userID, TokenID: uuid.New(), IssuedAt: time.Now()}
+
+	revoked, err := cache.IsRevoked(ctx, claims)
+	if err != nil || revoked {
+		t.Fatalf("expected a fresh token to be accepted, got %v, %v", revoked, err)
+	}
+
+	source.state = TokenState{RevokedIDs: map[uuid.UUID]struct{}{claims.TokenID: {}}}
+	revoked, _ = cache.IsRevoked(ctx, claims)
+	if revoked {
+		t.Error("expected the cached state to be used until invalidated")
+	}
+	if source.loads != 1 {
+		t.Errorf("expected 1 load, got %d", source.loads)
+	}
+
+	cache.Invalidate(userID)
+	revoked, _ = cache.IsRevoked(ctx, claims)
+	if !revoked {
+		t.Error("expected a revoked jti to be rejected")
+	}
+
+	source.state = TokenState{ValidAfter: claims.IssuedAt.Add(time.Microsecond)}
+	cache.Invalidate(userID)
+	revoked, _ = cache.IsRevoked(ctx, claims)
+	if !revoked {
+		t.Error("expected a token issued before the cutoff to be rejected")
+	}
+
+	claims.IssuedAt = source.state.ValidAfter
+	revoked, _ = cache.IsRevoked(ctx, claims)
+	if revoked {
+		t.Error("expected a token issued at the cutoff to be accepted")
+	}
+}
+
+func TestValidAfterCutoff(t *testing.T) {
+	now := time.Date(2024, 5, 1, 12, 0, 0, 1500, time.UTC)
+	cutoff := ValidAfterCutoff(now)
+
+	if want := time.Date(2024, 5, 1, 12, 0, 0, 2000, time.UTC); !cutoff.Equal(want) {
+		t.Fatalf("expected cutoff %v, got %v", want, cutoff)
+	}
+
+	tests := []struct {
+		name     string
+		issuedAt time.Time
+		rejected bool
+	}{
+		{name: "earlier microsecond", issuedAt: now.Add(-time.Microsecond).Truncate(time.Microsecond), rejected: true},
+		{name: "same microsecond", issuedAt: now.Truncate(time.Microsecond), rejected: true},
+		{name: "next microsecond", issuedAt: now.Truncate(time.Microsecond).Add(time.Microsecond), rejected: false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := tt.issuedAt.Before(cutoff); got != tt.rejected {
+				t.Errorf("expected rejected=%v, got %v", tt.rejected, got)
+			}
+		})
+	}
+}
+
+func TestHashRefreshToken(t *testing.T) {
+	token, err := MakeRefreshToken()
+	if err != nil {
+		t.Fatalf("failed to make refresh token: %v", err)
+	}
+
+	hash := HashRefreshToken(token)
+	if hash == token {
+		t.Fatal("expected the hash to differ from the token")
+	}
+
+	if HashRefreshToken(token) != hash {
+		t.Error("expected hashing to be deterministic")
+	}
+
+	// Matches encode(sha256(convert_to(token, 'UTF8')), 'hex') in the migration.
+	expected := "2cf24dba5fb0a30e26e83b2ac5b9e29e1b161e5c1fa7425e73043362938b9824"
+	if got := HashRefreshToken("hello"); got != expected {
+		t.Errorf("expected %s, got %s", expected, got)
+	}
+}
@@ -0,0 +1,358 @@
+package auth
+
+import (
+	"crypto"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/ed25519"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/pem"
+	"errors"
+	"math/big"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+	"github.com/google/uuid"
+)
+
+const (
+	AlgRS256 = "RS256"
+	AlgEdDSA = "EdDSA"
+)
+
+const rsaKeyBits = 2048
+
+const (
+	// keyEncryptionKeySize makes the key encryption key an AES-256 key.
+	keyEncryptionKeySize = 32
+	sealedKeyPrefix      = "v1:"
+)
+
+var ErrNoSigningKey = errors.New("no active signing key")
+
+// SigningKey is one key pair in a KeySet. A key signs new tokens from
+// ActivatesAt until a newer key activates, and verifies tokens until
+// ExpiresAt. A zero ExpiresAt means the key has not been rotated out.
+type SigningKey struct {
+	ID          string
+	Algorithm   string
+	PrivateKey  crypto.Signer
+	ActivatesAt time.Time
+	ExpiresAt   time.Time
+}
+
+func ParseAlgorithm(s string) (string, bool) {
+	switch s {
+	case "":
+		return AlgRS256, true
+	case AlgRS256, AlgEdDSA:
+		return s, true
+	}
+	return "", false
+}
+
+// GenerateSigningKey creates a key pair with a random key ID.
+func GenerateSigningKey(algorithm string) (SigningKey, error) {
+	var private crypto.Signer
+	var err error
+
+	switch algorithm {
+	case AlgRS256:
+		private, err = rsa.GenerateKey(rand.Reader, rsaKeyBits)
+	case AlgEdDSA:
+		_, private, err = ed25519.GenerateKey(rand.Reader)
+	default:
+		return SigningKey{}, errors.New("unsupported signing algorithm")
+	}
+	if err != nil {
+		return SigningKey{}, err
+	}
+
+	return SigningKey{
+		ID:         uuid.NewString(),
+		Algorithm:  algorithm,
+		PrivateKey: private,
+	}, nil
+}
+
+// MarshalPrivateKey encodes the private key as a PKCS #8 PEM block.
+func (k SigningKey) MarshalPrivateKey() (string, error) {
+	der, err := x509.MarshalPKCS8PrivateKey(k.PrivateKey)
+	if err != nil {
+		return "", err
+	}
+
+	return string(pem.EncodeToMemory(&pem.Block{Type: "PRIVATE KEY", Bytes: der})), nil
+}
+
+// ParsePrivateKey decodes a key written by MarshalPrivateKey and checks it
+// matches algorithm.
+func ParsePrivateKey(algorithm, encoded string) (crypto.Signer, error) {
+	block, _ := pem.Decode([]byte(encoded))
+	if block == nil {
+		return nil, errors.New("private key is not PEM encoded")
+	}
+
+	key, err := x509.ParsePKCS8PrivateKey(block.Bytes)
+	if err != nil {
+		return nil, err
+	}
+
+	switch key := key.(type) {
+	case *rsa.PrivateKey:
+		if algorithm == AlgRS256 {
+			return key, nil
+		}
+	case ed25519.PrivateKey:
+		if algorithm == AlgEdDSA {
+			return key, nil
+		}
+	}
+
+	return nil, errors.New("private key does not match its algorithm")
+}
+
+// ParseKeyEncryptionKey decodes the base64-encoded AES-256 key that private
+// signing keys and TOTP secrets are encrypted with at rest.
+func ParseKeyEncryptionKey(encoded string) ([]byte, error) {
+	kek, err := base64.StdEncoding.DecodeString(encoded)
+	if err != nil {
+		return nil, err
+	}
+
+	if len(kek) != keyEncryptionKeySize {
+		return nil, errors.New("key encryption key must be 32 bytes")
+	}
+
+	return kek, nil
+}
+
+// SealPrivateKey encrypts the private key with AES-256-GCM under kek for
+// storage. The key ID is authenticated with it, so a sealed key only opens
+// for the row it was written to.
+func (k SigningKey) SealPrivateKey(kek []byte) (string, error) {
+	encoded, err := k.MarshalPrivateKey()
+	if err != nil {
+		return "", err
+	}
+
+	return seal(kek, []byte(encoded), []byte(k.ID))
+}
+
+// OpenPrivateKey decrypts a key written by SealPrivateKey for the key with
+// the given ID and checks it matches algorithm.
+func OpenPrivateKey(kek []byte, id, algorithm, sealed string) (crypto.Signer, error) {
+	if !IsSealedPrivateKey(sealed) {
+		return nil, errors.New("private key is not encrypted")
+	}
+
+	encoded, err := open(kek, sealed, []byte(id))
+	if err != nil {
+		return nil, errors.New("private key could not be decrypted")
+	}
+
+	return ParsePrivateKey(algorithm, string(encoded))
+}
+
+// IsSealedPrivateKey reports whether a stored private key was written by
+// SealPrivateKey.
+func IsSealedPrivateKey(stored string) bool {
+	return strings.HasPrefix(stored, sealedKeyPrefix)
+}
+
+// seal encrypts plaintext with AES-256-GCM under kek. additionalData is
+// authenticated but not stored, so open only succeeds when given the same
+// value, which ties the ciphertext to the row it was written to.
+func seal(kek, plaintext, additionalData []byte) (string, error) {
+	gcm, err := newKeyCipher(kek)
+	if err != nil {
+		return "", err
+	}
+
+	nonce := make([]byte, gcm.NonceSize())
+	if _, err := rand.Read(nonce); err != nil {
+		return "", err
+	}
+
+	sealed := gcm.Seal(nonce, nonce, plaintext, additionalData)
+	return sealedKeyPrefix + base64.StdEncoding.EncodeToString(sealed), nil
+}
+
+// open decrypts a value written by seal with the same additionalData.
+func open(kek []byte, sealed string, additionalData []byte) ([]byte, error) {
+	data, err := base64.StdEncoding.DecodeString(strings.TrimPrefix(sealed, sealedKeyPrefix))
+	if err != nil {
+		return nil, err
+	}
+
+	gcm, err := newKeyCipher(kek)
+	if err != nil {
+		return nil, err
+	}
+
+	if len(data) < gcm.NonceSize() {
+		return nil, errors.New("encrypted value is too short")
+	}
+
+	nonce, ciphertext := data[:gcm.NonceSize()], data[gcm.NonceSize():]
+	return gcm.Open(nil, nonce, ciphertext, additionalData)
+}
+
+func newKeyCipher(kek []byte) (cipher.AEAD, error) {
+	if len(kek) != keyEncryptionKeySize {
+		return nil, errors.New("key encryption key must be 32 bytes")
+	}
+
+	block, err := aes.NewCipher(kek)
+	if err != nil {
+		return nil, err
+	}
+
+	return cipher.NewGCM(block)
+}
+
+func (k SigningKey) signingMethod() jwt.SigningMethod {
+	if k.Algorithm == AlgEdDSA {
+		return jwt.SigningMethodEdDSA
+	}
+	return jwt.SigningMethodRS256
+}
+
+func (k SigningKey) expired(now time.Time) bool {
+	return !k.ExpiresAt.IsZero() && !now.Before(k.ExpiresAt)
+}
+
+// KeySet holds the keys access tokens are signed and verified with. Keys are
+// published for verification as soon as they are in the set, even before
+// they activate, so verifiers that cache the JWKS learn a new key before
+// any token is signed with it.
+type KeySet struct {
+	// legacySecret verifies HS256 tokens issued before the switch to
+	// asymmetric keys. Nothing new is signed with it.
+	legacySecret []byte
+
+	mu   sync.RWMutex
+	keys []SigningKey
+}
+
+func NewKeySet(legacySecret string) *KeySet {
+	ks := &KeySet{}
+	if legacySecret != "" {
+		ks.legacySecret = []byte(legacySecret)
+	}
+	return ks
+}
+
+// SetKeys replaces the keys in the set.
+func (ks *KeySet) SetKeys(keys []SigningKey) {
+	sorted := append([]SigningKey(nil), keys...)
+	sort.Slice(sorted, func(i, j int) bool {
+		return sorted[i].ActivatesAt.Before(sorted[j].ActivatesAt)
+	})
+
+	ks.mu.Lock()
+	defer ks.mu.Unlock()
+	ks.keys = sorted
+}
+
+// signingKey returns the most recently activated key that has not expired.
+func (ks *KeySet) signingKey(now time.Time) (SigningKey, error) {
+	ks.mu.RLock()
+	defer ks.mu.RUnlock()
+
+	for i := len(ks.keys) - 1; i >= 0; i-- {
+		key := ks.keys[i]
+		if !key.ActivatesAt.After(now) && !key.expired(now) {
+			return key, nil
+		}
+	}
+
+	return SigningKey{}, ErrNoSigningKey
+}
+
+func (ks *KeySet) verificationKey(id string, now time.Time) (SigningKey, bool) {
+	ks.mu.RLock()
+	defer ks.mu.RUnlock()
+
+	for _, key := range ks.keys {
+		if key.ID == id && !key.expired(now) {
+			return key, true
+		}
+	}
+
+	return SigningKey{}, false
+}
+
+func (ks *KeySet) keyFunc(token *jwt.Token) (any, error) {
+	kid, _ := token.Header["kid"].(string)
+	if kid == "" {
+		if ks.legacySecret == nil || token.Method.Alg() != jwt.SigningMethodHS256.Alg() {
+			return nil, errors.New("token has no key ID")
+		}
+		return ks.legacySecret, nil
+	}
+
+	key, ok := ks.verificationKey(kid, time.Now())
+	if !ok {
+		return nil, errors.New("unknown signing key")
+	}
+
+	if token.Method.Alg() != key.Algorithm {
+		return nil, errors.New("invalid signing method")
+	}
+
+	return key.PrivateKey.Public(), nil
+}
+
+// JWK is a public key in JSON Web Key format (RFC 7517).
+type JWK struct {
+	KeyType   string `json:"kty"`
+	KeyID     string `json:"kid"`
+	Algorithm string `json:"alg"`
+	Use       string `json:"use"`
+	N         string `json:"n,omitempty"`
+	E         string `json:"e,omitempty"`
+	Curve     string `json:"crv,omitempty"`
+	X         string `json:"x,omitempty"`
+}
+
+type JWKS struct {
+	Keys []JWK `json:"keys"`
+}
+
+// JWKS returns the public half of every key that can still verify tokens.
+func (ks *KeySet) JWKS() JWKS {
+	ks.mu.RLock()
+	defer ks.mu.RUnlock()
+
+	now := time.Now()
+	jwks := JWKS{Keys: []JWK{}}
+	for _, key := range ks.keys {
+		if key.expired(now) {
+			continue
+		}
+
+		jwk := JWK{KeyID: key.ID, Algorithm: key.Algorithm, Use: "sig"}
+		switch public := key.PrivateKey.Public().(type) {
+		case *rsa.PublicKey:
+			jwk.KeyType = "RSA"
+			jwk.N = base64.RawURLEncoding.EncodeToString(public.N.Bytes())
+			jwk.E = base64.RawURLEncoding.EncodeToString(big.NewInt(int64(public.E)).Bytes())
+		case ed25519.PublicKey:
+			jwk.KeyType = "OKP"
+			jwk.Curve = "Ed25519"
+			jwk.X = base64.RawURLEncoding.EncodeToString(public)
+		default:
+			continue
+		}
+		jwks.Keys = append(jwks.Keys, jwk)
+	}
+
+	return jwks
+}
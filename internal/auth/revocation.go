@@ -0,0 +1,123 @@
+package auth
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// maxCachedTokenStates bounds the cache. When it is full, expired entries
+// are swept before a new one is added.
+const maxCachedTokenStates = 10000
+
+// TokenState is what a user's access tokens are checked against: tokens
+// issued before ValidAfter are rejected, as are tokens whose jti has been
+// revoked individually.
+type TokenState struct {
+	ValidAfter time.Time
+	RevokedIDs map[uuid.UUID]struct{}
+}
+
+// ValidAfterCutoff returns the ValidAfter that revokes every access token
+// issued up to now.
+//
+// Issue times and cutoffs are compared at whole microseconds, the
+// resolution of the iat_us claim and of a Postgres timestamp. A token is
+// rejected when its issue time is earlier than the cutoff. The cutoff is
+// the microsecond after now, so a token issued in the same microsecond as
+// the revocation is rejected too; a token issued in any later microsecond
+// is accepted.
+func ValidAfterCutoff(now time.Time) time.Time {
+	return now.Truncate(time.Microsecond).Add(time.Microsecond)
+}
+
+type TokenStateSource interface {
+	LoadTokenState(ctx context.Context, userID uuid.UUID) (TokenState, error)
+}
+
+// RevocationCache keeps each user's TokenState in memory for ttl so checking
+// an access token does not cost a database round trip on every request.
+// Changes made through this process should call Invalidate; changes made
+// elsewhere are picked up once the entry expires.
+type RevocationCache struct {
+	source TokenStateSource
+	ttl    time.Duration
+
+	mu      sync.Mutex
+	entries map[uuid.UUID]cachedTokenState
+	// generation is bumped by Invalidate so a load that raced with it does
+	// not put stale state back into the cache.
+	generation uint64
+}
+
+type cachedTokenState struct {
+	state    TokenState
+	loadedAt time.Time
+}
+
+func NewRevocationCache(source TokenStateSource, ttl time.Duration) *RevocationCache {
+	return &RevocationCache{
+		source:  source,
+		ttl:     ttl,
+		entries: map[uuid.UUID]cachedTokenState{},
+	}
+}
+
+// IsRevoked reports whether the token described by claims should no longer
+// be accepted.
+func (c *RevocationCache) IsRevoked(ctx context.Context, claims AccessClaims) (bool, error) {
+	state, err := c.load(ctx, claims.UserID)
+	if err != nil {
+		return false, err
+	}
+
+	if claims.IssuedAt.Before(state.ValidAfter) {
+		return true, nil
+	}
+
+	_, revoked := state.RevokedIDs[claims.TokenID]
+	return revoked, nil
+}
+
+func (c *RevocationCache) Invalidate(userID uuid.UUID) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	delete(c.entries, userID)
+	c.generation++
+}
+
+func (c *RevocationCache) load(ctx context.Context, userID uuid.UUID) (TokenState, error) {
+	c.mu.Lock()
+	entry, ok := c.entries[userID]
+	generation := c.generation
+	c.mu.Unlock()
+
+	if ok && time.Since(entry.loadedAt) < c.ttl {
+		return entry.state, nil
+	}
+
+	state, err := c.source.LoadTokenState(ctx, userID)
+	if err != nil {
+		return TokenState{}, err
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if c.generation != generation {
+		return state, nil
+	}
+
+	if len(c.entries) >= maxCachedTokenStates {
+		for id, e := range c.entries {
+			if time.Since(e.loadedAt) >= c.ttl {
+				delete(c.entries, id)
+			}
+		}
+	}
+	c.entries[userID] = cachedTokenState{state: state, loadedAt: time.Now()}
+
+	return state, nil
+}
@@ -0,0 +1,38 @@
+package auth
+
+import "slices"
+
+const (
+	RoleUser      = "user"
+	RoleModerator = "moderator"
+	RoleAdmin     = "admin"
+)
+
+// Scopes are what routes require. Roles map onto a fixed set of scopes so a
+// route can be opened to another role without touching its handler.
+const (
+	ScopeModerate = "moderate"
+	ScopeAdmin    = "admin"
+)
+
+func ParseRole(s string) (string, bool) {
+	switch s {
+	case RoleUser, RoleModerator, RoleAdmin:
+		return s, true
+	}
+	return "", false
+}
+
+func ScopesForRole(role string) []string {
+	switch role {
+	case RoleAdmin:
+		return []string{ScopeModerate, ScopeAdmin}
+	case RoleModerator:
+		return []string{ScopeModerate}
+	}
+	return nil
+}
+
+func (c AccessClaims) HasScope(scope string) bool {
+	return slices.Contains(c.Scopes, scope)
+}
@@ -0,0 +1,125 @@
+package auth
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// rfc6238Secret is the SHA-1 key from RFC 6238 appendix B,
+// "12345678901234567890", in base32.
+const rfc6238Secret = "GEZDGNBVGY3TQOJQGEZDGNBVGY3TQOJQ"
+
+func TestTOTPCodeRFC6238(t *testing.T) {
+	// The RFC lists 8-digit codes; these are their last 6 digits.
+	tests := []struct {
+		unix int64
+		code string
+	}{
+		{59, "287082"},
+		{1111111109, "081804"},
+		{1111111111, "050471"},
+		{1234567890, "005924"},
+		{2000000000, "279037"},
+	}
+
+	for _, tt := range tests {
+		got, err := TOTPCode(rfc6238Secret, time.Unix(tt.unix, 0))
+		if err != nil {
+			t.Fatalf("failed to compute code: %v", err)
+		}
+		if got != tt.code {
+			t.Errorf("at %d: expected %s, got %s", tt.unix, tt.code, got)
+		}
+	}
+}
+
+func TestValidateTOTP(t *testing.T) {
+	secret := rfc6238Secret
+	now := time.Unix(1111111111, 0)
+	code, _ := TOTPCode(secret, now)
+
+	step, ok := ValidateTOTP(secret, code, now)
+	if !ok || step != totpStep(now) {
+		t.Fatalf("expected the current code to validate at step %d, got %d, %v", totpStep(now), step, ok)
+	}
+
+	previous, _ := TOTPCode(secret, now.Add(-totpPeriod*time.Second))
+	if _, ok := ValidateTOTP(secret, previous, now); !ok {
+		t.Error("expected the previous period's code to be accepted")
+	}
+
+	stale, _ := TOTPCode(secret, now.Add(-3*totpPeriod*time.Second))
+	if _, ok := ValidateTOTP(secret, stale, now); ok {
+		t.Error("expected a code from three periods ago to be rejected")
+	}
+
+	if _, ok := ValidateTOTP(secret, "12345", now); ok {
+		t.Error("expected a short code to be rejected")
+	}
+}
+
+func TestSealTOTPSecret(t *testing.T) {
+	kek := make([]byte, 32)
+	userID := uuid.New()
+
+	sealed, err := SealTOTPSecret(kek, userID, rfc6238Secret)
+	if err != nil {
+		t.Fatalf("failed to seal secret: %v", err)
+	}
+	if strings.Contains(sealed, rfc6238Secret) {
+		t.Fatalf("expected an encrypted secret, got %q", sealed)
+	}
+
+	secret, err := OpenTOTPSecret(kek, userID, sealed)
+	if err != nil {
+		t.Fatalf("failed to open secret: %v", err)
+	}
+	if secret != rfc6238Secret {
+		t.Errorf("expected %s, got %s", rfc6238Secret, secret)
+	}
+
+	if _, err := OpenTOTPSecret(kek, uuid.New(), sealed); err == nil {
+		t.Error("expected a secret sealed for another user to be rejected")
+	}
+
+	if _, err := OpenTOTPSecret(kek, userID, rfc6238Secret); err == nil {
+		t.Error("expected a plain secret to be rejected")
+	}
+}
+
+func TestTOTPURI(t *testing.T) {
+	uri := TOTPURI("ABC", "Chirpy", "user@example.com")
+	if !strings.HasPrefix(uri, "otpauth://totp/Chirpy:user@example.com?") {
+		t.Errorf("unexpected URI prefix: %s", uri)
+	}
+	if !strings.Contains(uri, "secret=ABC") || !strings.Contains(uri, "issuer=Chirpy") {
+		t.Errorf("expected secret and issuer in URI: %s", uri)
+	}
+}
+
+func TestRecoveryCodes(t *testing.T) {
+	codes, err := GenerateRecoveryCodes(10)
+	if err != nil {
+		t.Fatalf("failed to generate recovery codes: %v", err)
+	}
+
+	seen := map[string]bool{}
+	for _, code := range codes {
+		if len(code) != recoveryCodeLength+1 || code[recoveryCodeLength/2] != '-' {
+			t.Errorf("unexpected recovery code format: %q", code)
+		}
+		if seen[code] {
+			t.Errorf("duplicate recovery code %q", code)
+		}
+		seen[code] = true
+	}
+
+	code := codes[0]
+	typed := strings.ToUpper(strings.ReplaceAll(code, "-", " "))
+	if HashRecoveryCode(typed) != HashRecoveryCode(code) {
+		t.Error("expected case, spaces and dashes to be ignored")
+	}
+}
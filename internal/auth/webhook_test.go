@@ -0,0 +1,115 @@
+package auth
+
+import (
+	"errors"
+	"net/http"
+	"strconv"
+	"testing"
+	"time"
+)
+
+func signedHeaders(signature string, timestamp time.Time) http.Header {
+	headers := http.Header{}
+	headers.Set(WebhookSignatureHeader, signature)
+	headers.Set(WebhookTimestampHeader, strconv.FormatInt(timestamp.Unix(), 10))
+	return headers
+}
+
+func TestVerifyWebhookSignature(t *testing.T) {
+	body := []byte(`{"event":"user.upgraded"}`)
+	now := time.Now()
+	tolerance := 5 * time.Minute
+
+	tests := []struct {
+		name    string
+		headers http.Header
+		body    []byte
+		secrets []string
+		wantErr error
+	}{
+		{
+			name:    "valid signature",
+			headers: signedHeaders(SignWebhook("secret", now, body), now),
+			body:    body,
+			secrets: []string{"secret"},
+		},
+		{
+			name:    "second secret during rotation",
+			headers: signedHeaders(SignWebhook("new", now, body), now),
+			body:    body,
+			secrets: []string{"old", "new"},
+		},
+		{
+			name:    "one of several signatures",
+			headers: signedHeaders(SignWebhook("other", now, body)+", "+SignWebhook("secret", now, body), now),
+			body:    body,
+			secrets: []string{"secret"},
+		},
+		{
+			name:    "wrong secret",
+			headers: signedHeaders(SignWebhook("wrong", now, body), now),
+			body:    body,
+			secrets: []string{"secret"},
+			wantErr: ErrInvalidWebhookSignature,
+		},
+		{
+			name:    "tampered body",
+			headers: signedHeaders(SignWebhook("secret", now, body), now),
+			body:    []byte(`{"event":"user.downgraded"}`),
+			secrets: []string{"secret"},
+			wantErr: ErrInvalidWebhookSignature,
+		},
+		{
+			name:    "timestamp outside tolerance",
+			headers: signedHeaders(SignWebhook("secret", now.Add(-10*time.Minute), body), now.Add(-10*time.Minute)),
+			body:    body,
+			secrets: []string{"secret"},
+			wantErr: ErrStaleWebhookSignature,
+		},
+		{
+			name:    "signature moved to a new timestamp",
+			headers: signedHeaders(SignWebhook("secret", now.Add(-10*time.Minute), body), now),
+			body:    body,
+			secrets: []string{"secret"},
+			wantErr: ErrInvalidWebhookSignature,
+		},
+		{
+			name:    "missing headers",
+			headers: http.Header{},
+			body:    body,
+			secrets: []string{"secret"},
+			wantErr: ErrNoWebhookSignature,
+		},
+		{
+			name:    "empty secret",
+			headers: signedHeaders(SignWebhook("", now, body), now),
+			body:    body,
+			secrets: []string{""},
+			wantErr: ErrInvalidWebhookSignature,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := VerifyWebhookSignature(tt.headers, tt.body, tt.secrets, tolerance, now)
+			if !errors.Is(err, tt.wantErr) {
+				t.Errorf("expected error %v, got %v", tt.wantErr, err)
+			}
+		})
+	}
+}
+
+func TestCheckAPIKey(t *testing.T) {
+	headers := http.Header{}
+	headers.Set("Authorization", "ApiKey secret")
+
+	if !CheckAPIKey(headers, "secret") {
+		t.Error("expected matching key to be accepted")
+	}
+	if CheckAPIKey(headers, "other") {
+		t.Error("expected different key to be rejected")
+	}
+	if CheckAPIKey(http.Header{}, "") {
+		t.Error("expected empty key to match nothing")
+	}
+}
@@ -0,0 +1,60 @@
+package blob
+
+import (
+	"context"
+	"errors"
+	"io"
+	"strings"
+	"testing"
+)
+
+func TestLocalStore(t *testing.T) {
+	ctx := context.Background()
+	store, err := NewLocalStore(t.TempDir())
+	if err != nil {
+		t.Fatalf("failed to create store: %v", err)
+	}
+
+	err = store.Put(ctx, "logo.png", strings.NewReader("image data"))
+	if err != nil {
+		t.Fatalf("failed to put blob: %v", err)
+	}
+
+	r, err := store.Open(ctx, "logo.png")
+	if err != nil {
+		t.Fatalf("failed to open blob: %v", err)
+	}
+	data, err := io.ReadAll(r)
+	r.Close()
+	if err != nil {
+		t.Fatalf("failed to read blob: %v", err)
+	}
+
+	if string(data) != "image data" {
+		t.Errorf("expected %q, got %q", "image data", data)
+	}
+
+	err = store.Delete(ctx, "logo.png")
+	if err != nil {
+		t.Fatalf("failed to delete blob: %v", err)
+	}
+
+	_, err = store.Open(ctx, "logo.png")
+	if !errors.Is(err, ErrNotFound) {
+		t.Errorf("expected ErrNotFound, got %v", err)
+	}
+}
+
+func TestLocalStoreRejectsPaths(t *testing.T) {
+	store, err := NewLocalStore(t.TempDir())
+	if err != nil {
+		t.Fatalf("failed to create store: %v", err)
+	}
+
+	for _, key := range []string{"", "..", "../escape", "nested/key", ".hidden"} {
+		err := store.Put(context.Background(), key, strings.NewReader("x"))
+		if err == nil {
+			t.Errorf("expected key %q to be rejected", key)
+		}
+	}
+}
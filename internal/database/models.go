@@ -11,28 +11,230 @@ import (
 	"github.com/google/uuid"
 )
 
+type Attachment struct {
+	ID          uuid.UUID
+	CreatedAt   time.Time
+	UserID      uuid.UUID
+	ChirpID     uuid.NullUUID
+	StorageKey  string
+	ContentType string
+	SizeBytes   int64
+}
+
 type Chirp struct {
+	ID           uuid.UUID
+	CreatedAt    time.Time
+	UpdatedAt    time.Time
+	Body         string
+	UserID       uuid.UUID
+	ReplyTo      uuid.NullUUID
+	DeletedAt    sql.NullTime
+	SearchVector interface{}
+}
+
+type ChirpFlag struct {
+	ID           uuid.UUID
+	CreatedAt    time.Time
+	ChirpID      uuid.UUID
+	MatchedWords []string
+}
+
+type ChirpHashtag struct {
+	ChirpID   uuid.UUID
+	Tag       string
+	CreatedAt time.Time
+}
+
+type ChirpMention struct {
+	ChirpID   uuid.UUID
+	UserID    uuid.UUID
+	CreatedAt time.Time
+}
+
+type ChirpReaction struct {
+	UserID    uuid.UUID
+	ChirpID   uuid.UUID
+	Reaction  string
+	CreatedAt time.Time
+}
+
+type ChirpRevision struct {
 	ID        uuid.UUID
 	CreatedAt time.Time
-	UpdatedAt time.Time
+	ChirpID   uuid.UUID
 	Body      string
+}
+
+type EmailVerificationToken struct {
+	TokenHash string
+	CreatedAt time.Time
+	UserID    uuid.UUID
+	Email     string
+	ExpiresAt time.Time
+	UsedAt    sql.NullTime
+}
+
+type Follow struct {
+	FollowerID uuid.UUID
+	FolloweeID uuid.UUID
+	CreatedAt  time.Time
+}
+
+type LoginThrottle struct {
+	Key           string
+	Failures      int32
+	LastFailureAt time.Time
+	LockedUntil   sql.NullTime
+}
+
+type MfaChallenge struct {
+	TokenHash string
+	CreatedAt time.Time
+	UserID    uuid.UUID
+	ExpiresAt time.Time
+	Attempts  int32
+	UsedAt    sql.NullTime
+}
+
+type OutboundWebhookEvent struct {
+	ID         uuid.UUID
+	CreatedAt  time.Time
+	EventType  string
+	Payload    string
+	OwnerID    uuid.NullUUID
+	ExpandedAt sql.NullTime
+}
+
+type PasswordResetToken struct {
+	TokenHash string
+	CreatedAt time.Time
 	UserID    uuid.UUID
+	ExpiresAt time.Time
+	UsedAt    sql.NullTime
+}
+
+type ProfaneWord struct {
+	Word      string
+	CreatedAt time.Time
 }
 
 type RefreshToken struct {
-	Token     string
+	TokenHash string
 	CreatedAt time.Time
 	UpdatedAt time.Time
 	UserID    uuid.UUID
 	ExpiresAt time.Time
 	RevokedAt sql.NullTime
+	FamilyID  uuid.UUID
+	RotatedAt sql.NullTime
+}
+
+type RevokedAccessToken struct {
+	Jti       uuid.UUID
+	CreatedAt time.Time
+	UserID    uuid.UUID
+	ExpiresAt time.Time
+}
+
+type Session struct {
+	ID         uuid.UUID
+	CreatedAt  time.Time
+	UpdatedAt  time.Time
+	UserID     uuid.UUID
+	UserAgent  string
+	IpAddress  string
+	LastUsedAt time.Time
+}
+
+type SigningKey struct {
+	ID          string
+	CreatedAt   time.Time
+	Algorithm   string
+	PrivateKey  string
+	ActivatesAt time.Time
+	ExpiresAt   sql.NullTime
+}
+
+type Subscription struct {
+	UserID           uuid.UUID
+	CreatedAt        time.Time
+	UpdatedAt        time.Time
+	Status           string
+	CurrentPeriodEnd sql.NullTime
+	CancelledAt      sql.NullTime
+	EndedAt          sql.NullTime
+	LastEventAt      sql.NullTime
+}
+
+type TotpRecoveryCode struct {
+	CodeHash  string
+	CreatedAt time.Time
+	UserID    uuid.UUID
+	UsedAt    sql.NullTime
 }
 
 type User struct {
-	ID             uuid.UUID
-	CreatedAt      time.Time
-	UpdatedAt      time.Time
-	Email          string
-	HashedPassword string
-	IsChirpyRed    bool
+	ID               uuid.UUID
+	CreatedAt        time.Time
+	UpdatedAt        time.Time
+	Email            string
+	HashedPassword   string
+	IsChirpyRed      bool
+	TokensValidAfter time.Time
+	Role             string
+	EmailVerifiedAt  sql.NullTime
+}
+
+type UserTotp struct {
+	UserID       uuid.UUID
+	CreatedAt    time.Time
+	Secret       string
+	ConfirmedAt  sql.NullTime
+	LastUsedStep int64
+}
+
+type WebhookDelivery struct {
+	ID            uuid.UUID
+	CreatedAt     time.Time
+	UpdatedAt     time.Time
+	EndpointID    uuid.UUID
+	EventID       uuid.UUID
+	EventType     string
+	Status        string
+	Attempts      int32
+	NextAttemptAt time.Time
+	DeliveredAt   sql.NullTime
+	LastError     sql.NullString
+}
+
+type WebhookDeliveryAttempt struct {
+	ID         uuid.UUID
+	CreatedAt  time.Time
+	DeliveryID uuid.UUID
+	StatusCode sql.NullInt32
+	Error      sql.NullString
+	DurationMs int32
+}
+
+type WebhookEndpoint struct {
+	ID         uuid.UUID
+	CreatedAt  time.Time
+	UpdatedAt  time.Time
+	UserID     uuid.UUID
+	Url        string
+	Secret     string
+	EventTypes []string
+}
+
+type WebhookEvent struct {
+	ID          uuid.UUID
+	ReceivedAt  time.Time
+	UpdatedAt   time.Time
+	EventID     string
+	EventType   string
+	Payload     string
+	Status      string
+	Attempts    int32
+	LastError   sql.NullString
+	ProcessedAt sql.NullTime
 }
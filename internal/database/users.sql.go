@@ -7,6 +7,7 @@ package database
 
 import (
 	"context"
+	"time"
 
 	"github.com/google/uuid"
 )
@@ -20,7 +21,7 @@ VALUES (
     $1,
     $2
 )
-RETURNING id, created_at, updated_at, email, hashed_password, is_chirpy_red
+RETURNING id, created_at, updated_at, email, hashed_password, is_chirpy_red, tokens_valid_after, role, email_verified_at
 `
 
 type CreateUserParams struct {
@@ -38,6 +39,9 @@ func (q *Queries) CreateUser(ctx context.Context, arg CreateUserParams) (User, e
 		&i.Email,
 		&i.HashedPassword,
 		&i.IsChirpyRed,
+		&i.TokensValidAfter,
+		&i.Role,
+		&i.EmailVerifiedAt,
 	)
 	return i, err
 }
@@ -52,7 +56,7 @@ func (q *Queries) DeleteUsers(ctx context.Context) error {
 }
 
 const getUserByEmail = `-- name: GetUserByEmail :one
-SELECT id, created_at, updated_at, email, hashed_password, is_chirpy_red FROM users
+SELECT id, created_at, updated_at, email, hashed_password, is_chirpy_red, tokens_valid_after, role, email_verified_at FROM users
 WHERE email = $1
 `
 
@@ -66,20 +70,92 @@ func (q *Queries) GetUserByEmail(ctx context.Context, email string) (User, error
 		&i.Email,
 		&i.HashedPassword,
 		&i.IsChirpyRed,
+		&i.TokensValidAfter,
+		&i.Role,
+		&i.EmailVerifiedAt,
 	)
 	return i, err
 }
 
-const getUserFromRefreshToken = `-- name: GetUserFromRefreshToken :one
-SELECT users.id, users.created_at, users.updated_at, users.email, users.hashed_password, users.is_chirpy_red FROM users
-JOIN refresh_tokens ON users.id = refresh_tokens.user_id
-WHERE refresh_tokens.token = $1
-AND revoked_at IS NULL
-AND expires_at > NOW()
+const getUserByID = `-- name: GetUserByID :one
+SELECT id, created_at, updated_at, email, hashed_password, is_chirpy_red, tokens_valid_after, role, email_verified_at FROM users
+WHERE id = $1
+`
+
+func (q *Queries) GetUserByID(ctx context.Context, id uuid.UUID) (User, error) {
+	row := q.db.QueryRowContext(ctx, getUserByID, id)
+	var i User
+	err := row.Scan(
+		&i.ID,
+		&i.CreatedAt,
+		&i.UpdatedAt,
+		&i.Email,
+		&i.HashedPassword,
+		&i.IsChirpyRed,
+		&i.TokensValidAfter,
+		&i.Role,
+		&i.EmailVerifiedAt,
+	)
+	return i, err
+}
+
+const getUserTokensValidAfter = `-- name: GetUserTokensValidAfter :one
+SELECT tokens_valid_after FROM users
+WHERE id = $1
+`
+
+func (q *Queries) GetUserTokensValidAfter(ctx context.Context, id uuid.UUID) (time.Time, error) {
+	row := q.db.QueryRowContext(ctx, getUserTokensValidAfter, id)
+	var tokens_valid_after time.Time
+	err := row.Scan(&tokens_valid_after)
+	return tokens_valid_after, err
+}
+
+const markUserEmailVerified = `-- name: MarkUserEmailVerified :one
+UPDATE users
+SET email_verified_at = NOW(), updated_at = CURRENT_TIMESTAMP
+WHERE id = $1
+AND email = $2
+RETURNING id, created_at, updated_at, email, hashed_password, is_chirpy_red, tokens_valid_after, role, email_verified_at
+`
+
+type MarkUserEmailVerifiedParams struct {
+	ID    uuid.UUID
+	Email string
+}
+
+func (q *Queries) MarkUserEmailVerified(ctx context.Context, arg MarkUserEmailVerifiedParams) (User, error) {
+	row := q.db.QueryRowContext(ctx, markUserEmailVerified, arg.ID, arg.Email)
+	var i User
+	err := row.Scan(
+		&i.ID,
+		&i.CreatedAt,
+		&i.UpdatedAt,
+		&i.Email,
+		&i.HashedPassword,
+		&i.IsChirpyRed,
+		&i.TokensValidAfter,
+		&i.Role,
+		&i.EmailVerifiedAt,
+	)
+	return i, err
+}
+
+const syncUserIsChirpyRed = `-- name: SyncUserIsChirpyRed :one
+UPDATE users
+SET is_chirpy_red = EXISTS (
+        SELECT 1 FROM subscriptions
+        WHERE subscriptions.user_id = users.id
+        AND subscriptions.status <> 'expired'
+        AND (subscriptions.current_period_end IS NULL OR subscriptions.current_period_end > NOW())
+    ),
+    updated_at = CURRENT_TIMESTAMP
+WHERE id = $1
+RETURNING id, created_at, updated_at, email, hashed_password, is_chirpy_red, tokens_valid_after, role, email_verified_at
 `
 
-func (q *Queries) GetUserFromRefreshToken(ctx context.Context, token string) (User, error) {
-	row := q.db.QueryRowContext(ctx, getUserFromRefreshToken, token)
+func (q *Queries) SyncUserIsChirpyRed(ctx context.Context, id uuid.UUID) (User, error) {
+	row := q.db.QueryRowContext(ctx, syncUserIsChirpyRed, id)
 	var i User
 	err := row.Scan(
 		&i.ID,
@@ -88,15 +164,21 @@ func (q *Queries) GetUserFromRefreshToken(ctx context.Context, token string) (Us
 		&i.Email,
 		&i.HashedPassword,
 		&i.IsChirpyRed,
+		&i.TokensValidAfter,
+		&i.Role,
+		&i.EmailVerifiedAt,
 	)
 	return i, err
 }
 
 const updateUser = `-- name: UpdateUser :one
 UPDATE users
-SET email = $2, hashed_password = $3, updated_at = CURRENT_TIMESTAMP
+SET email = $2,
+    hashed_password = $3,
+    email_verified_at = CASE WHEN email = $2 THEN email_verified_at END,
+    updated_at = CURRENT_TIMESTAMP
 WHERE id = $1
-RETURNING id, created_at, updated_at, email, hashed_password, is_chirpy_red
+RETURNING id, created_at, updated_at, email, hashed_password, is_chirpy_red, tokens_valid_after, role, email_verified_at
 `
 
 type UpdateUserParams struct {
@@ -115,24 +197,43 @@ func (q *Queries) UpdateUser(ctx context.Context, arg UpdateUserParams) (User, e
 		&i.Email,
 		&i.HashedPassword,
 		&i.IsChirpyRed,
+		&i.TokensValidAfter,
+		&i.Role,
+		&i.EmailVerifiedAt,
 	)
 	return i, err
 }
 
-const updateUserIsChirpyRed = `-- name: UpdateUserIsChirpyRed :one
+const updateUserPassword = `-- name: UpdateUserPassword :exec
+UPDATE users
+SET hashed_password = $2, updated_at = CURRENT_TIMESTAMP
+WHERE id = $1
+`
+
+type UpdateUserPasswordParams struct {
+	ID             uuid.UUID
+	HashedPassword string
+}
+
+func (q *Queries) UpdateUserPassword(ctx context.Context, arg UpdateUserPasswordParams) error {
+	_, err := q.db.ExecContext(ctx, updateUserPassword, arg.ID, arg.HashedPassword)
+	return err
+}
+
+const updateUserRole = `-- name: UpdateUserRole :one
 UPDATE users
-SET is_chirpy_red = $2, updated_at = CURRENT_TIMESTAMP
+SET role = $2, updated_at = CURRENT_TIMESTAMP
 WHERE id = $1
-RETURNING id, created_at, updated_at, email, hashed_password, is_chirpy_red
+RETURNING id, created_at, updated_at, email, hashed_password, is_chirpy_red, tokens_valid_after, role, email_verified_at
 `
 
-type UpdateUserIsChirpyRedParams struct {
-	ID          uuid.UUID
-	IsChirpyRed bool
+type UpdateUserRoleParams struct {
+	ID   uuid.UUID
+	Role string
 }
 
-func (q *Queries) UpdateUserIsChirpyRed(ctx context.Context, arg UpdateUserIsChirpyRedParams) (User, error) {
-	row := q.db.QueryRowContext(ctx, updateUserIsChirpyRed, arg.ID, arg.IsChirpyRed)
+func (q *Queries) UpdateUserRole(ctx context.Context, arg UpdateUserRoleParams) (User, error) {
+	row := q.db.QueryRowContext(ctx, updateUserRole, arg.ID, arg.Role)
 	var i User
 	err := row.Scan(
 		&i.ID,
@@ -141,6 +242,25 @@ func (q *Queries) UpdateUserIsChirpyRed(ctx context.Context, arg UpdateUserIsChi
 		&i.Email,
 		&i.HashedPassword,
 		&i.IsChirpyRed,
+		&i.TokensValidAfter,
+		&i.Role,
+		&i.EmailVerifiedAt,
 	)
 	return i, err
 }
+
+const updateUserTokensValidAfter = `-- name: UpdateUserTokensValidAfter :exec
+UPDATE users
+SET tokens_valid_after = $2, updated_at = CURRENT_TIMESTAMP
+WHERE id = $1
+`
+
+type UpdateUserTokensValidAfterParams struct {
+	ID               uuid.UUID
+	TokensValidAfter time.Time
+}
+
+func (q *Queries) UpdateUserTokensValidAfter(ctx context.Context, arg UpdateUserTokensValidAfterParams) error {
+	_, err := q.db.ExecContext(ctx, updateUserTokensValidAfter, arg.ID, arg.TokensValidAfter)
+	return err
+}
@@ -0,0 +1,148 @@
+package events
+
+import (
+	"crypto/rand"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/google/uuid"
+)
+
+// Event IDs count up from 1 within an epoch. Every broker picks a new epoch
+// when it is created, so after a restart an ID from the previous process is
+// recognized as such instead of being mistaken for one in the new sequence.
+type Event struct {
+	Epoch  string
+	ID     uint64
+	Type   string
+	UserID uuid.UUID
+	Data   []byte
+}
+
+// LastEventID formats the event's epoch and ID for an SSE id field. Clients
+// send it back as Last-Event-ID to resume.
+func (e Event) LastEventID() string {
+	return e.Epoch + "-" + strconv.FormatUint(e.ID, 10)
+}
+
+// Broker fans events out to in-process subscribers. Publish never blocks: a
+// subscriber whose buffer is full is dropped and its channel closed, and it
+// can reconnect with the last ID it saw to replay what it missed from the
+// history buffer.
+type Broker struct {
+	mu          sync.Mutex
+	epoch       string
+	nextID      uint64
+	history     []Event
+	historySize int
+	bufferSize  int
+	subscribers map[*Subscription]struct{}
+}
+
+type Subscription struct {
+	C      <-chan Event
+	ch     chan Event
+	filter func(Event) bool
+}
+
+func NewBroker(historySize, bufferSize int) *Broker {
+	return &Broker{
+		epoch:       rand.Text(),
+		nextID:      1,
+		historySize: historySize,
+		bufferSize:  bufferSize,
+		subscribers: map[*Subscription]struct{}{},
+	}
+}
+
+// Publish assigns the next event ID and delivers the event.
+func (b *Broker) Publish(eventType string, userID uuid.UUID, data []byte) Event {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	event := Event{
+		Epoch:  b.epoch,
+		ID:     b.nextID,
+		Type:   eventType,
+		UserID: userID,
+		Data:   data,
+	}
+	b.nextID++
+
+	b.history = append(b.history, event)
+	if len(b.history) > b.historySize {
+		b.history = b.history[len(b.history)-b.historySize:]
+	}
+
+	for sub := range b.subscribers {
+		if !sub.filter(event) {
+			continue
+		}
+
+		select {
+		case sub.ch <- event:
+		default:
+			b.dropLocked(sub)
+		}
+	}
+
+	return event
+}
+
+// Subscribe registers a subscriber for events matching filter. Events after
+// lastEventID that are still in the history buffer are queued first. An
+// empty lastEventID, or one from another epoch, only receives new events.
+func (b *Broker) Subscribe(lastEventID string, filter func(Event) bool) *Subscription {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	var backlog []Event
+	if lastID, ok := b.parseLastEventIDLocked(lastEventID); ok {
+		for _, event := range b.history {
+			if event.ID > lastID && filter(event) {
+				backlog = append(backlog, event)
+			}
+		}
+	}
+
+	ch := make(chan Event, b.bufferSize+len(backlog))
+	for _, event := range backlog {
+		ch <- event
+	}
+
+	sub := &Subscription{C: ch, ch: ch, filter: filter}
+	b.subscribers[sub] = struct{}{}
+
+	return sub
+}
+
+// parseLastEventIDLocked returns the ID within this broker's epoch that
+// lastEventID refers to. IDs handed out before a restart have another epoch.
+func (b *Broker) parseLastEventIDLocked(lastEventID string) (uint64, bool) {
+	epoch, id, ok := strings.Cut(lastEventID, "-")
+	if !ok || epoch != b.epoch {
+		return 0, false
+	}
+
+	lastID, err := strconv.ParseUint(id, 10, 64)
+	if err != nil || lastID >= b.nextID {
+		return 0, false
+	}
+
+	return lastID, true
+}
+
+func (b *Broker) Unsubscribe(sub *Subscription) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.dropLocked(sub)
+}
+
+func (b *Broker) dropLocked(sub *Subscription) {
+	if _, ok := b.subscribers[sub]; !ok {
+		return
+	}
+	delete(b.subscribers, sub)
+	close(sub.ch)
+}
@@ -0,0 +1,87 @@
+package events
+
+import (
+	"testing"
+
+	"github.com/google/uuid"
+)
+
+func all(Event) bool { return true }
+
+func TestBrokerPublish(t *testing.T) {
+	broker := NewBroker(10, 10)
+	author := uuid.New()
+
+	sub := broker.Subscribe("", func(e Event) bool { return e.UserID == author })
+	defer broker.Unsubscribe(sub)
+
+	broker.Publish("chirp.created", uuid.New(), nil)
+	published := broker.Publish("chirp.created", author, []byte("{}"))
+
+	event := <-sub.C
+	if event.ID != published.ID {
+		t.Errorf("expected event %d, got %d", published.ID, event.ID)
+	}
+
+	select {
+	case event := <-sub.C:
+		t.Errorf("expected no more events, got %d", event.ID)
+	default:
+	}
+}
+
+func TestBrokerResume(t *testing.T) {
+	broker := NewBroker(2, 10)
+
+	first := broker.Publish("chirp.created", uuid.New(), nil)
+	broker.Publish("chirp.created", uuid.New(), nil)
+	last := broker.Publish("chirp.deleted", uuid.New(), nil)
+
+	sub := broker.Subscribe(first.LastEventID(), all)
+	defer broker.Unsubscribe(sub)
+
+	if len(sub.C) != 2 {
+		t.Fatalf("expected 2 replayed events, got %d", len(sub.C))
+	}
+
+	<-sub.C
+	if event := <-sub.C; event.ID != last.ID {
+		t.Errorf("expected event %d, got %d", last.ID, event.ID)
+	}
+}
+
+func TestBrokerResumeFromAnotherEpoch(t *testing.T) {
+	previous := NewBroker(10, 10)
+	previous.Publish("chirp.created", uuid.New(), nil)
+	stale := previous.Publish("chirp.created", uuid.New(), nil)
+
+	// After a restart the new broker has handed out the same IDs again.
+	broker := NewBroker(10, 10)
+	for range 3 {
+		broker.Publish("chirp.created", uuid.New(), nil)
+	}
+
+	for _, lastEventID := range []string{stale.LastEventID(), "2", "not-an-id"} {
+		sub := broker.Subscribe(lastEventID, all)
+		if len(sub.C) != 0 {
+			t.Errorf("Last-Event-ID %q: expected a fresh subscription, got %d replayed events", lastEventID, len(sub.C))
+		}
+		broker.Unsubscribe(sub)
+	}
+}
+
+func TestBrokerDropsSlowSubscriber(t *testing.T) {
+	broker := NewBroker(10, 1)
+
+	sub := broker.Subscribe("", all)
+	broker.Publish("chirp.created", uuid.New(), nil)
+	broker.Publish("chirp.created", uuid.New(), nil)
+
+	<-sub.C
+	if _, ok := <-sub.C; ok {
+		t.Error("expected the subscription to be closed")
+	}
+
+	// Unsubscribing an already dropped subscriber must not panic.
+	broker.Unsubscribe(sub)
+}
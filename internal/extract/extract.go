@@ -0,0 +1,49 @@
+package extract
+
+import (
+	"regexp"
+	"strings"
+)
+
+const maxHashtagLength = 50
+
+var (
+	hashtagPattern = regexp.MustCompile(`(?:^|[^\p{L}\p{N}_&#])#([\p{L}\p{N}_]+)`)
+	mentionPattern = regexp.MustCompile(`(?:^|[^\p{L}\p{N}_@.])@([A-Za-z0-9._%+\-]+@[A-Za-z0-9.\-]+\.[A-Za-z]{2,})`)
+)
+
+// Hashtags returns the distinct, lower-cased tags in body without the
+// leading #, in order of first appearance.
+func Hashtags(body string) []string {
+	var tags []string
+	seen := map[string]bool{}
+
+	for _, match := range hashtagPattern.FindAllStringSubmatch(body, -1) {
+		tag := strings.ToLower(match[1])
+		if len([]rune(tag)) > maxHashtagLength || seen[tag] {
+			continue
+		}
+		seen[tag] = true
+		tags = append(tags, tag)
+	}
+
+	return tags
+}
+
+// Mentions returns the distinct, lower-cased email addresses mentioned in
+// body as @user@example.com.
+func Mentions(body string) []string {
+	var emails []string
+	seen := map[string]bool{}
+
+	for _, match := range mentionPattern.FindAllStringSubmatch(body, -1) {
+		email := strings.ToLower(match[1])
+		if seen[email] {
+			continue
+		}
+		seen[email] = true
+		emails = append(emails, email)
+	}
+
+	return emails
+}
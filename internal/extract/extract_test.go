@@ -0,0 +1,42 @@
+package extract
+
+import (
+	"slices"
+	"testing"
+)
+
+func TestHashtags(t *testing.T) {
+	tests := []struct {
+		body     string
+		expected []string
+	}{
+		{"Loving #Go and #golang! #go again", []string{"go", "golang"}},
+		{"no tags here, not even in a#word or &#39;", nil},
+		{"#start and (#paren) #café", []string{"start", "paren", "café"}},
+	}
+
+	for _, tc := range tests {
+		tags := Hashtags(tc.body)
+		if !slices.Equal(tags, tc.expected) {
+			t.Errorf("expected %v for %q, got %v", tc.expected, tc.body, tags)
+		}
+	}
+}
+
+func TestMentions(t *testing.T) {
+	tests := []struct {
+		body     string
+		expected []string
+	}{
+		{"hi @Alice@Example.com and @bob@example.org.", []string{"alice@example.com", "bob@example.org"}},
+		{"mail me at carol@example.com", nil},
+		{"@dave@example.com @dave@example.com", []string{"dave@example.com"}},
+	}
+
+	for _, tc := range tests {
+		mentions := Mentions(tc.body)
+		if !slices.Equal(mentions, tc.expected) {
+			t.Errorf("expected %v for %q, got %v", tc.expected, tc.body, mentions)
+		}
+	}
+}
@@ -0,0 +1,159 @@
+package mail
+
+import (
+	"bufio"
+	"context"
+	"fmt"
+	"io"
+	"net"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestFormat(t *testing.T) {
+	date := time.Date(2024, 1, 2, 3, 4, 5, 0, time.UTC)
+	msg := Message{To: "user@example.com", Subject: "Hello", Body: "line one\nline two"}
+
+	got := string(Format("chirpy@example.com", msg, date))
+	expected := "From: chirpy@example.com\r\n" +
+		"To: user@example.com\r\n" +
+		"Subject: Hello\r\n" +
+		"Date: Tue, 02 Jan 2024 03:04:05 +0000\r\n" +
+		"MIME-Version: 1.0\r\n" +
+		"Content-Type: text/plain; charset=utf-8\r\n" +
+		"\r\n" +
+		"line one\r\nline two"
+
+	if got != expected {
+		t.Errorf("expected\n%q\ngot\n%q", expected, got)
+	}
+}
+
+func TestFileMailer(t *testing.T) {
+	dir := filepath.Join(t.TempDir(), "mail")
+	mailer, err := NewFileMailer(dir, "chirpy@example.com")
+	if err != nil {
+		t.Fatalf("failed to create mailer: %v", err)
+	}
+
+	err = mailer.Send(context.Background(), Message{To: "user@example.com", Subject: "Verify", Body: "token"})
+	if err != nil {
+		t.Fatalf("failed to send: %v", err)
+	}
+
+	entries, err := os.ReadDir(dir)
+	if err != nil || len(entries) != 1 {
+		t.Fatalf("expected one message file, got %d (%v)", len(entries), err)
+	}
+
+	data, err := os.ReadFile(filepath.Join(dir, entries[0].Name()))
+	if err != nil {
+		t.Fatalf("failed to read message: %v", err)
+	}
+	if !strings.Contains(string(data), "To: user@example.com\r\n") {
+		t.Errorf("expected the recipient in the message, got %q", data)
+	}
+
+	err = mailer.Send(context.Background(), Message{To: "user@example.com\r\nBcc: x@example.com", Subject: "Verify"})
+	if err == nil {
+		t.Error("expected header injection to be rejected")
+	}
+}
+
+// serveSMTP accepts one connection on a local listener and hands it to
+// serve, returning the address to send to.
+func serveSMTP(t *testing.T, serve func(conn net.Conn)) string {
+	t.Helper()
+
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("failed to listen: %v", err)
+	}
+	t.Cleanup(func() { ln.Close() })
+
+	go func() {
+		conn, err := ln.Accept()
+		if err != nil {
+			return
+		}
+		defer conn.Close()
+		serve(conn)
+	}()
+
+	return ln.Addr().String()
+}
+
+func TestSMTPMailer(t *testing.T) {
+	received := make(chan string, 1)
+	addr := serveSMTP(t, func(conn net.Conn) {
+		r := bufio.NewReader(conn)
+		fmt.Fprint(conn, "220 localhost ready\r\n")
+
+		var data strings.Builder
+		inData := false
+		for {
+			line, err := r.ReadString('\n')
+			if err != nil {
+				return
+			}
+			if inData {
+				if line == ".\r\n" {
+					inData = false
+					fmt.Fprint(conn, "250 queued\r\n")
+					continue
+				}
+				data.WriteString(line)
+				continue
+			}
+
+			switch cmd := strings.ToUpper(strings.TrimSpace(line)); {
+			case strings.HasPrefix(cmd, "EHLO"):
+				fmt.Fprint(conn, "250 localhost\r\n")
+			case strings.HasPrefix(cmd, "MAIL FROM:<CHIRPY@EXAMPLE.COM>"), strings.HasPrefix(cmd, "RCPT TO:<USER@EXAMPLE.COM>"):
+				fmt.Fprint(conn, "250 ok\r\n")
+			case cmd == "DATA":
+				inData = true
+				fmt.Fprint(conn, "354 go ahead\r\n")
+			case cmd == "QUIT":
+				fmt.Fprint(conn, "221 bye\r\n")
+				received <- data.String()
+				return
+			default:
+				fmt.Fprint(conn, "500 unexpected\r\n")
+			}
+		}
+	})
+
+	mailer := SMTPMailer{Addr: addr, From: "Chirpy <chirpy@example.com>"}
+	err := mailer.Send(context.Background(), Message{To: "user@example.com", Subject: "Hello", Body: "hi"})
+	if err != nil {
+		t.Fatalf("failed to send: %v", err)
+	}
+
+	if got := <-received; !strings.Contains(got, "Subject: Hello\r\n") || !strings.HasSuffix(got, "hi\r\n") {
+		t.Errorf("unexpected message:\n%s", got)
+	}
+}
+
+func TestSMTPMailerGivesUpOnHungServer(t *testing.T) {
+	// The server accepts the connection but never greets.
+	addr := serveSMTP(t, func(conn net.Conn) {
+		io.Copy(io.Discard, conn)
+	})
+
+	ctx, cancel := context.WithTimeout(context.Background(), 200*time.Millisecond)
+	defer cancel()
+
+	start := time.Now()
+	mailer := SMTPMailer{Addr: addr, From: "chirpy@example.com"}
+	err := mailer.Send(ctx, Message{To: "user@example.com", Subject: "Hello", Body: "hi"})
+	if err == nil {
+		t.Fatal("expected an error from a hung server")
+	}
+	if elapsed := time.Since(start); elapsed > 5*time.Second {
+		t.Errorf("expected the send to stop at the deadline, took %s", elapsed)
+	}
+}
@@ -0,0 +1,352 @@
+package metrics
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"math"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+)
+
+// DefaultBuckets are upper bounds in seconds suited to HTTP request
+// latencies.
+var DefaultBuckets = []float64{0.005, 0.01, 0.025, 0.05, 0.1, 0.25, 0.5, 1, 2.5, 5, 10}
+
+// Registry holds every metric that is exposed and writes them in the
+// Prometheus text exposition format, in the order they were registered.
+type Registry struct {
+	mu      sync.Mutex
+	names   map[string]struct{}
+	metrics []metric
+}
+
+type metric interface {
+	write(w *bufio.Writer)
+}
+
+func NewRegistry() *Registry {
+	return &Registry{names: make(map[string]struct{})}
+}
+
+func (r *Registry) register(name string, m metric) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if _, ok := r.names[name]; ok {
+		panic("metrics: duplicate metric " + name)
+	}
+	r.names[name] = struct{}{}
+	r.metrics = append(r.metrics, m)
+}
+
+// WriteText writes every metric in the Prometheus text format.
+func (r *Registry) WriteText(w io.Writer) error {
+	r.mu.Lock()
+	metrics := slices.Clone(r.metrics)
+	r.mu.Unlock()
+
+	bw := bufio.NewWriter(w)
+	for _, m := range metrics {
+		m.write(bw)
+	}
+	return bw.Flush()
+}
+
+// Counter is a count that only goes up.
+type Counter struct {
+	name, help string
+	value      atomic.Uint64
+}
+
+func (r *Registry) NewCounter(name, help string) *Counter {
+	c := &Counter{name: name, help: help}
+	r.register(name, c)
+	return c
+}
+
+func (c *Counter) Inc()          { c.value.Add(1) }
+func (c *Counter) Add(n uint64)  { c.value.Add(n) }
+func (c *Counter) Value() uint64 { return c.value.Load() }
+
+func (c *Counter) write(w *bufio.Writer) {
+	writeHeader(w, c.name, c.help, "counter")
+	writeSample(w, c.name, "", float64(c.Value()))
+}
+
+// Gauge is a value that can go up and down.
+type Gauge struct {
+	name, help string
+	value      atomic.Int64
+}
+
+func (r *Registry) NewGauge(name, help string) *Gauge {
+	g := &Gauge{name: name, help: help}
+	r.register(name, g)
+	return g
+}
+
+func (g *Gauge) Inc()         { g.value.Add(1) }
+func (g *Gauge) Dec()         { g.value.Add(-1) }
+func (g *Gauge) Set(v int64)  { g.value.Store(v) }
+func (g *Gauge) Value() int64 { return g.value.Load() }
+
+func (g *Gauge) write(w *bufio.Writer) {
+	writeHeader(w, g.name, g.help, "gauge")
+	writeSample(w, g.name, "", float64(g.Value()))
+}
+
+// funcMetric reads its value when the registry is written, for values that
+// are tracked elsewhere.
+type funcMetric struct {
+	name, help, kind string
+	value            func() float64
+}
+
+// NewGaugeFunc registers a gauge whose value is read from fn.
+func (r *Registry) NewGaugeFunc(name, help string, fn func() float64) {
+	r.register(name, &funcMetric{name: name, help: help, kind: "gauge", value: fn})
+}
+
+// NewCounterFunc registers a counter whose value is read from fn, which must
+// never decrease.
+func (r *Registry) NewCounterFunc(name, help string, fn func() float64) {
+	r.register(name, &funcMetric{name: name, help: help, kind: "counter", value: fn})
+}
+
+func (m *funcMetric) write(w *bufio.Writer) {
+	writeHeader(w, m.name, m.help, m.kind)
+	writeSample(w, m.name, "", m.value())
+}
+
+// CounterVec is a family of counters told apart by label values.
+type CounterVec struct {
+	name, help string
+	labels     []string
+
+	mu     sync.RWMutex
+	series map[string]*labeledCounter
+}
+
+type labeledCounter struct {
+	values []string
+	value  atomic.Uint64
+}
+
+func (r *Registry) NewCounterVec(name, help string, labels ...string) *CounterVec {
+	c := &CounterVec{name: name, help: help, labels: labels, series: make(map[string]*labeledCounter)}
+	r.register(name, c)
+	return c
+}
+
+// Inc adds one to the counter with the given label values, which must match
+// the label names in number and order.
+func (c *CounterVec) Inc(values ...string) {
+	c.get(values).value.Add(1)
+}
+
+// Value returns the count for the given label values.
+func (c *CounterVec) Value(values ...string) uint64 {
+	return c.get(values).value.Load()
+}
+
+func (c *CounterVec) get(values []string) *labeledCounter {
+	checkLabels(c.name, c.labels, values)
+	key := seriesKey(values)
+
+	c.mu.RLock()
+	s, ok := c.series[key]
+	c.mu.RUnlock()
+	if ok {
+		return s
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if s, ok := c.series[key]; ok {
+		return s
+	}
+	s = &labeledCounter{values: slices.Clone(values)}
+	c.series[key] = s
+	return s
+}
+
+func (c *CounterVec) write(w *bufio.Writer) {
+	c.mu.RLock()
+	series := sortedSeries(c.series)
+	c.mu.RUnlock()
+
+	writeHeader(w, c.name, c.help, "counter")
+	for _, s := range series {
+		writeSample(w, c.name, formatLabels(c.labels, s.values), float64(s.value.Load()))
+	}
+}
+
+// HistogramVec is a family of histograms told apart by label values.
+type HistogramVec struct {
+	name, help string
+	labels     []string
+	buckets    []float64
+
+	mu     sync.RWMutex
+	series map[string]*histogram
+}
+
+type histogram struct {
+	values []string
+
+	mu     sync.Mutex
+	counts []uint64
+	count  uint64
+	sum    float64
+}
+
+// NewHistogramVec registers a histogram family with the given bucket upper
+// bounds, which must be sorted. A +Inf bucket is always added.
+func (r *Registry) NewHistogramVec(name, help string, buckets []float64, labels ...string) *HistogramVec {
+	h := &HistogramVec{
+		name:    name,
+		help:    help,
+		labels:  labels,
+		buckets: slices.Clone(buckets),
+		series:  make(map[string]*histogram),
+	}
+	r.register(name, h)
+	return h
+}
+
+// Observe records v in the histogram with the given label values.
+func (h *HistogramVec) Observe(v float64, values ...string) {
+	s := h.get(values)
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for i, bound := range h.buckets {
+		if v <= bound {
+			s.counts[i]++
+		}
+	}
+	s.count++
+	s.sum += v
+}
+
+func (h *HistogramVec) get(values []string) *histogram {
+	checkLabels(h.name, h.labels, values)
+	key := seriesKey(values)
+
+	h.mu.RLock()
+	s, ok := h.series[key]
+	h.mu.RUnlock()
+	if ok {
+		return s
+	}
+
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	if s, ok := h.series[key]; ok {
+		return s
+	}
+	s = &histogram{values: slices.Clone(values), counts: make([]uint64, len(h.buckets))}
+	h.series[key] = s
+	return s
+}
+
+func (h *HistogramVec) write(w *bufio.Writer) {
+	h.mu.RLock()
+	series := sortedSeries(h.series)
+	h.mu.RUnlock()
+
+	writeHeader(w, h.name, h.help, "histogram")
+	for _, s := range series {
+		s.mu.Lock()
+		counts := slices.Clone(s.counts)
+		count, sum := s.count, s.sum
+		s.mu.Unlock()
+
+		for i, bound := range h.buckets {
+			labels := formatLabels(append(slices.Clone(h.labels), "le"), append(slices.Clone(s.values), formatFloat(bound)))
+			writeSample(w, h.name+"_bucket", labels, float64(counts[i]))
+		}
+		labels := formatLabels(append(slices.Clone(h.labels), "le"), append(slices.Clone(s.values), "+Inf"))
+		writeSample(w, h.name+"_bucket", labels, float64(count))
+
+		labels = formatLabels(h.labels, s.values)
+		writeSample(w, h.name+"_sum", labels, sum)
+		writeSample(w, h.name+"_count", labels, float64(count))
+	}
+}
+
+func checkLabels(name string, labels, values []string) {
+	if len(labels) != len(values) {
+		panic(fmt.Sprintf("metrics: %s has %d labels, got %d values", name, len(labels), len(values)))
+	}
+}
+
+func seriesKey(values []string) string {
+	return strings.Join(values, "\xff")
+}
+
+func sortedSeries[S interface{ labelValues() []string }](series map[string]S) []S {
+	sorted := make([]S, 0, len(series))
+	for _, s := range series {
+		sorted = append(sorted, s)
+	}
+	slices.SortFunc(sorted, func(a, b S) int {
+		return slices.Compare(a.labelValues(), b.labelValues())
+	})
+	return sorted
+}
+
+func (s *labeledCounter) labelValues() []string { return s.values }
+func (s *histogram) labelValues() []string      { return s.values }
+
+func writeHeader(w *bufio.Writer, name, help, kind string) {
+	fmt.Fprintf(w, "# HELP %s %s\n# TYPE %s %s\n", name, escapeHelp(help), name, kind)
+}
+
+func writeSample(w *bufio.Writer, name, labels string, value float64) {
+	fmt.Fprintf(w, "%s%s %s\n", name, labels, formatFloat(value))
+}
+
+func formatLabels(names, values []string) string {
+	if len(names) == 0 {
+		return ""
+	}
+
+	var b strings.Builder
+	b.WriteByte('{')
+	for i, name := range names {
+		if i > 0 {
+			b.WriteByte(',')
+		}
+		b.WriteString(name)
+		b.WriteString(`="`)
+		b.WriteString(escapeLabelValue(values[i]))
+		b.WriteByte('"')
+	}
+	b.WriteByte('}')
+	return b.String()
+}
+
+var (
+	helpEscaper  = strings.NewReplacer(`\`, `\\`, "\n", `\n`)
+	labelEscaper = strings.NewReplacer(`\`, `\\`, "\n", `\n`, `"`, `\"`)
+)
+
+func escapeHelp(s string) string       { return helpEscaper.Replace(s) }
+func escapeLabelValue(s string) string { return labelEscaper.Replace(s) }
+
+func formatFloat(v float64) string {
+	switch {
+	case math.IsInf(v, 1):
+		return "+Inf"
+	case math.IsInf(v, -1):
+		return "-Inf"
+	case math.IsNaN(v):
+		return "NaN"
+	}
+	return strconv.FormatFloat(v, 'g', -1, 64)
+}
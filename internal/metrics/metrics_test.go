@@ -0,0 +1,87 @@
+package metrics
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestWriteText(t *testing.T) {
+	registry := NewRegistry()
+
+	counter := registry.NewCounter("hits_total", "Total hits.")
+	counter.Add(3)
+
+	gauge := registry.NewGauge("in_flight", "Requests in flight.")
+	gauge.Inc()
+	gauge.Inc()
+	gauge.Dec()
+
+	registry.NewGaugeFunc("open_connections", "Open connections.", func() float64 { return 7 })
+
+	requests := registry.NewCounterVec("requests_total", "Requests by route.", "route", "code")
+	requests.Inc("GET /b", "200")
+	requests.Inc("GET /a", "404")
+	requests.Inc("GET /a", "404")
+
+	durations := registry.NewHistogramVec("duration_seconds", "Durations.", []float64{0.1, 1}, "route")
+	durations.Observe(0.05, "GET /a")
+	durations.Observe(0.5, "GET /a")
+	durations.Observe(5, "GET /a")
+
+	var b strings.Builder
+	if err := registry.WriteText(&b); err != nil {
+		t.Fatalf("failed to write metrics: %v", err)
+	}
+
+	expected := `# HELP hits_total Total hits.
+# TYPE hits_total counter
+hits_total 3
+# HELP in_flight Requests in flight.
+# TYPE in_flight gauge
+in_flight 1
+# HELP open_connections Open connections.
+# TYPE open_connections gauge
+open_connections 7
+# HELP requests_total Requests by route.
+# TYPE requests_total counter
+requests_total{route="GET /a",code="404"} 2
+requests_total{route="GET /b",code="200"} 1
+# HELP duration_seconds Durations.
+# TYPE duration_seconds histogram
+duration_seconds_bucket{route="GET /a",le="0.1"} 1
+duration_seconds_bucket{route="GET /a",le="1"} 2
+duration_seconds_bucket{route="GET /a",le="+Inf"} 3
+duration_seconds_sum{route="GET /a"} 5.55
+duration_seconds_count{route="GET /a"} 3
+`
+	if got := b.String(); got != expected {
+		t.Errorf("unexpected output:\n%s\nexpected:\n%s", got, expected)
+	}
+}
+
+func TestLabelEscaping(t *testing.T) {
+	registry := NewRegistry()
+	requests := registry.NewCounterVec("requests_total", "Requests.", "route")
+	requests.Inc("a\"b\\c\nd")
+
+	var b strings.Builder
+	if err := registry.WriteText(&b); err != nil {
+		t.Fatalf("failed to write metrics: %v", err)
+	}
+
+	if !strings.Contains(b.String(), `requests_total{route="a\"b\\c\nd"} 1`) {
+		t.Errorf("label value not escaped:\n%s", b.String())
+	}
+}
+
+func TestDuplicateMetricPanics(t *testing.T) {
+	registry := NewRegistry()
+	registry.NewCounter("hits_total", "Total hits.")
+
+	defer func() {
+		if recover() == nil {
+			t.Error("expected duplicate registration to panic")
+		}
+	}()
+	registry.NewCounter("hits_total", "Total hits.")
+}
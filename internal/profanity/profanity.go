@@ -0,0 +1,162 @@
+package profanity
+
+import (
+	"context"
+	"strings"
+	"sync"
+	"time"
+	"unicode"
+
+	"golang.org/x/text/unicode/norm"
+)
+
+const mask = "****"
+
+type Mode string
+
+const (
+	ModeMask   Mode = "mask"
+	ModeReject Mode = "reject"
+	ModeFlag   Mode = "flag"
+)
+
+func ParseMode(s string) (Mode, bool) {
+	switch Mode(s) {
+	case "":
+		return ModeMask, true
+	case ModeMask, ModeReject, ModeFlag:
+		return Mode(s), true
+	}
+	return "", false
+}
+
+// Result is the outcome of scanning a body. Masked is the body with every
+// profane word replaced, Matches holds the normalized words that were found.
+type Result struct {
+	Masked  string
+	Matches []string
+}
+
+type Filter interface {
+	Scan(ctx context.Context, body string) (Result, error)
+	// Invalidate drops any cached state so the next Scan sees list changes.
+	Invalidate()
+}
+
+type WordSource interface {
+	ListProfaneWords(ctx context.Context) ([]string, error)
+}
+
+// StaticWords is a fixed WordSource, useful for tests and as a fallback.
+type StaticWords []string
+
+func (s StaticWords) ListProfaneWords(ctx context.Context) ([]string, error) {
+	return s, nil
+}
+
+// WordListFilter matches whole words against a list loaded from a
+// WordSource. The list is cached for ttl; call Invalidate after changing it.
+type WordListFilter struct {
+	source WordSource
+	ttl    time.Duration
+
+	mu       sync.Mutex
+	words    map[string]struct{}
+	loadedAt time.Time
+}
+
+func NewWordListFilter(source WordSource, ttl time.Duration) *WordListFilter {
+	return &WordListFilter{
+		source: source,
+		ttl:    ttl,
+	}
+}
+
+func (f *WordListFilter) Invalidate() {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.words = nil
+}
+
+func (f *WordListFilter) Scan(ctx context.Context, body string) (Result, error) {
+	words, err := f.load(ctx)
+	if err != nil {
+		return Result{}, err
+	}
+
+	var masked strings.Builder
+	var matches []string
+	seen := map[string]bool{}
+
+	rest := body
+	for rest != "" {
+		start := strings.IndexFunc(rest, isWordRune)
+		if start == -1 {
+			masked.WriteString(rest)
+			break
+		}
+		masked.WriteString(rest[:start])
+		rest = rest[start:]
+
+		end := strings.IndexFunc(rest, func(r rune) bool { return !isWordRune(r) })
+		if end == -1 {
+			end = len(rest)
+		}
+		word := rest[:end]
+		rest = rest[end:]
+
+		normalized := Normalize(word)
+		if _, ok := words[normalized]; !ok {
+			masked.WriteString(word)
+			continue
+		}
+
+		masked.WriteString(mask)
+		if !seen[normalized] {
+			seen[normalized] = true
+			matches = append(matches, normalized)
+		}
+	}
+
+	return Result{Masked: masked.String(), Matches: matches}, nil
+}
+
+func (f *WordListFilter) load(ctx context.Context) (map[string]struct{}, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	if f.words != nil && time.Since(f.loadedAt) < f.ttl {
+		return f.words, nil
+	}
+
+	list, err := f.source.ListProfaneWords(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	words := make(map[string]struct{}, len(list))
+	for _, word := range list {
+		words[Normalize(word)] = struct{}{}
+	}
+	f.words = words
+	f.loadedAt = time.Now()
+
+	return words, nil
+}
+
+// Normalize folds a word to the form used for matching: compatibility
+// decomposition with combining marks and punctuation dropped, lower cased.
+// "Fôrnax," and "ＦＯＲＮＡＸ" both become "fornax".
+func Normalize(word string) string {
+	var b strings.Builder
+	for _, r := range norm.NFKD.String(word) {
+		if unicode.IsLetter(r) || unicode.IsDigit(r) {
+			b.WriteRune(unicode.ToLower(r))
+		}
+	}
+	return b.String()
+}
+
+func isWordRune(r rune) bool {
+	return unicode.IsLetter(r) || unicode.IsDigit(r) || unicode.Is(unicode.Mn, r)
+}
@@ -0,0 +1,37 @@
+package profanity
+
+import (
+	"context"
+	"testing"
+)
+
+func TestScan(t *testing.T) {
+	filter := NewWordListFilter(StaticWords{"kerfuffle", "sharbert", "fornax"}, 0)
+
+	tests := []struct {
+		body    string
+		masked  string
+		matches int
+	}{
+		{"This is a kerfuffle opinion", "This is a **** opinion", 1},
+		{"Kerfuffle! what a fornax, really", "****! what a ****, really", 2},
+		{"Fôrnax and ＦＯＲＮＡＸ", "**** and ****", 1},
+		{"nothing to see here", "nothing to see here", 0},
+		{"kerfufflers are fine", "kerfufflers are fine", 0},
+	}
+
+	for _, tc := range tests {
+		result, err := filter.Scan(context.Background(), tc.body)
+		if err != nil {
+			t.Fatalf("failed to scan %q: %v", tc.body, err)
+		}
+
+		if result.Masked != tc.masked {
+			t.Errorf("expected %q, got %q", tc.masked, result.Masked)
+		}
+
+		if len(result.Matches) != tc.matches {
+			t.Errorf("expected %d matches for %q, got %v", tc.matches, tc.body, result.Matches)
+		}
+	}
+}
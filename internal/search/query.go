@@ -0,0 +1,83 @@
+package search
+
+import (
+	"errors"
+	"strings"
+	"unicode"
+)
+
+// ParseQuery turns user input into a PostgreSQL tsquery expression.
+// Quoted text becomes a phrase match, a trailing * makes a prefix match and
+// all remaining words must match. Anything that is not a letter or digit is
+// dropped, so the result is always valid tsquery syntax.
+//
+//	fornax "chirpy red" kerf*  ->  fornax & (chirpy <-> red) & kerf:*
+func ParseQuery(input string) (string, error) {
+	var terms []string
+
+	rest := input
+	for rest != "" {
+		rest = strings.TrimLeftFunc(rest, unicode.IsSpace)
+		if rest == "" {
+			break
+		}
+
+		if rest[0] == '"' {
+			phrase, after, _ := strings.Cut(rest[1:], `"`)
+			rest = after
+			if term := phraseTerm(phrase); term != "" {
+				terms = append(terms, term)
+			}
+			continue
+		}
+
+		end := strings.IndexFunc(rest, unicode.IsSpace)
+		if end == -1 {
+			end = len(rest)
+		}
+		word := rest[:end]
+		rest = rest[end:]
+
+		prefix := strings.HasSuffix(word, "*")
+		lexeme := sanitize(word)
+		if lexeme == "" {
+			continue
+		}
+		if prefix {
+			lexeme += ":*"
+		}
+		terms = append(terms, lexeme)
+	}
+
+	if len(terms) == 0 {
+		return "", errors.New("search query has no searchable terms")
+	}
+
+	return strings.Join(terms, " & "), nil
+}
+
+func phraseTerm(phrase string) string {
+	var lexemes []string
+	for _, word := range strings.Fields(phrase) {
+		if lexeme := sanitize(word); lexeme != "" {
+			lexemes = append(lexemes, lexeme)
+		}
+	}
+
+	switch len(lexemes) {
+	case 0:
+		return ""
+	case 1:
+		return lexemes[0]
+	}
+	return "(" + strings.Join(lexemes, " <-> ") + ")"
+}
+
+func sanitize(word string) string {
+	return strings.Map(func(r rune) rune {
+		if unicode.IsLetter(r) || unicode.IsDigit(r) {
+			return unicode.ToLower(r)
+		}
+		return -1
+	}, word)
+}
@@ -0,0 +1,32 @@
+package search
+
+import "testing"
+
+func TestParseQuery(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{"fornax", "fornax"},
+		{"Chirpy red", "chirpy & red"},
+		{`"chirpy red" kerf*`, "(chirpy <-> red) & kerf:*"},
+		{`"unterminated phrase`, "(unterminated <-> phrase)"},
+		{"it's a & b | !c", "its & a & b & c"},
+	}
+
+	for _, tc := range tests {
+		query, err := ParseQuery(tc.input)
+		if err != nil {
+			t.Fatalf("failed to parse %q: %v", tc.input, err)
+		}
+
+		if query != tc.expected {
+			t.Errorf("expected %q, got %q", tc.expected, query)
+		}
+	}
+
+	_, err := ParseQuery(` "" * & `)
+	if err == nil {
+		t.Error("expected an error for a query without terms")
+	}
+}
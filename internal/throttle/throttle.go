@@ -0,0 +1,39 @@
+package throttle
+
+import "time"
+
+// Policy decides how long a key is locked out after repeated failures. The
+// first Threshold-1 failures are free; from then on every failure doubles
+// the lockout, starting at BaseLockout and capped at MaxLockout.
+type Policy struct {
+	Threshold   int
+	BaseLockout time.Duration
+	MaxLockout  time.Duration
+	// Window is how long failures are remembered. A failure after a quiet
+	// period this long starts counting from one again.
+	Window time.Duration
+}
+
+// Lockout returns how long to lock a key out after its failures-th
+// consecutive failure, or zero if it should not be locked.
+func (p Policy) Lockout(failures int) time.Duration {
+	if failures < p.Threshold {
+		return 0
+	}
+
+	lockout := p.BaseLockout
+	for range failures - p.Threshold {
+		lockout *= 2
+		if lockout >= p.MaxLockout {
+			return p.MaxLockout
+		}
+	}
+
+	return min(lockout, p.MaxLockout)
+}
+
+// RetryAfter rounds a wait up to whole seconds for a Retry-After header.
+func RetryAfter(wait time.Duration) int {
+	seconds := int((wait + time.Second - 1) / time.Second)
+	return max(seconds, 1)
+}
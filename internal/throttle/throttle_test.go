@@ -0,0 +1,49 @@
+package throttle
+
+import (
+	"testing"
+	"time"
+)
+
+func TestLockout(t *testing.T) {
+	policy := Policy{Threshold: 3, BaseLockout: time.Minute, MaxLockout: 10 * time.Minute}
+
+	tests := []struct {
+		failures int
+		expected time.Duration
+	}{
+		{0, 0},
+		{2, 0},
+		{3, time.Minute},
+		{4, 2 * time.Minute},
+		{5, 4 * time.Minute},
+		{6, 8 * time.Minute},
+		{7, 10 * time.Minute},
+		{1000, 10 * time.Minute},
+	}
+
+	for _, tt := range tests {
+		if got := policy.Lockout(tt.failures); got != tt.expected {
+			t.Errorf("after %d failures: expected %v, got %v", tt.failures, tt.expected, got)
+		}
+	}
+}
+
+func TestRetryAfter(t *testing.T) {
+	tests := []struct {
+		wait     time.Duration
+		expected int
+	}{
+		{0, 1},
+		{time.Millisecond, 1},
+		{time.Second, 1},
+		{1500 * time.Millisecond, 2},
+		{time.Minute, 60},
+	}
+
+	for _, tt := range tests {
+		if got := RetryAfter(tt.wait); got != tt.expected {
+			t.Errorf("RetryAfter(%v): expected %d, got %d", tt.wait, tt.expected, got)
+		}
+	}
+}
@@ -0,0 +1,219 @@
+package main
+
+import (
+	"context"
+	"database/sql"
+	"log"
+	"net/http"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/dennisdijkstra/go/internal/database"
+	"github.com/dennisdijkstra/go/internal/throttle"
+)
+
+// Failed logins are counted per account and per client IP. The account
+// limit stops guessing one password from many addresses; the looser IP
+// limit stops one address from trying many accounts.
+var (
+	accountLoginPolicy = throttle.Policy{
+		Threshold:   5,
+		BaseLockout: 30 * time.Second,
+		MaxLockout:  15 * time.Minute,
+		Window:      time.Hour,
+	}
+	ipLoginPolicy = throttle.Policy{
+		Threshold:   20,
+		BaseLockout: time.Minute,
+		MaxLockout:  time.Hour,
+		Window:      time.Hour,
+	}
+)
+
+const (
+	// passwordCheckWait is how long a login waits for a free password-check
+	// slot before the server reports itself busy.
+	passwordCheckWait = 2 * time.Second
+	// loginThrottleRetention is how long throttle rows are kept after the
+	// last failure once any lockout has ended.
+	loginThrottleRetention = 24 * time.Hour
+)
+
+type loginThrottleKeys struct {
+	Account string
+	IP      string
+}
+
+func (cfg *apiConfig) loginThrottleKeys(r *http.Request, email string) loginThrottleKeys {
+	return loginThrottleKeys{
+		Account: accountThrottleKey(email),
+		IP:      "ip:" + cfg.clientIP(r),
+	}
+}
+
+func accountThrottleKey(email string) string {
+	return "account:" + strings.ToLower(strings.TrimSpace(email))
+}
+
+// reserveLoginAttempt counts a login attempt against both keys before the
+// password or code is checked, and returns how long to wait instead if
+// either is locked out. Reserving first means a burst of concurrent
+// attempts cannot all pass the check before the first failure lands.
+func (cfg *apiConfig) reserveLoginAttempt(ctx context.Context, keys loginThrottleKeys) (time.Duration, error) {
+	return cfg.reserveAttempt(ctx,
+		throttledKey{Key: keys.Account, Policy: accountLoginPolicy},
+		throttledKey{Key: keys.IP, Policy: ipLoginPolicy},
+	)
+}
+
+// clearLoginFailures forgets an account's failures after a successful
+// login, and gives back the attempt the login reserved against the IP.
+// The IP's other failures are kept, so an attacker cannot reset their
+// count by logging into an account of their own.
+func (cfg *apiConfig) clearLoginFailures(ctx context.Context, keys loginThrottleKeys) {
+	if err := cfg.db.ClearLoginThrottle(ctx, keys.Account); err != nil {
+		log.Printf("Error clearing failed logins for %s: %s", keys.Account, err)
+	}
+
+	cfg.refundLoginAttempt(ctx, keys.IP)
+}
+
+// refundLoginAttempt gives back an attempt that turned out not to be a
+// failure.
+func (cfg *apiConfig) refundLoginAttempt(ctx context.Context, key string) {
+	if err := cfg.db.RefundLoginAttempt(ctx, key); err != nil {
+		log.Printf("Error refunding login attempt for %s: %s", key, err)
+	}
+}
+
+// throttledKey is a login_throttles key and the policy attempts against it
+// are counted under.
+type throttledKey struct {
+	Key    string
+	Policy throttle.Policy
+}
+
+// reserveAttempt counts an attempt against every key before it is made and
+// returns how long to wait instead if any of them is locked out. The rows
+// stay locked until the count is written, so concurrent attempts are
+// counted one at a time and none slips past a lockout an earlier one set.
+func (cfg *apiConfig) reserveAttempt(ctx context.Context, keys ...throttledKey) (time.Duration, error) {
+	// Lock the rows in a fixed order so two reservations cannot deadlock.
+	keys = slices.Clone(keys)
+	slices.SortFunc(keys, func(a, b throttledKey) int {
+		return strings.Compare(a.Key, b.Key)
+	})
+
+	tx, err := cfg.dbConn.BeginTx(ctx, nil)
+	if err != nil {
+		return 0, err
+	}
+	defer tx.Rollback()
+	qtx := cfg.db.WithTx(tx)
+
+	now := time.Now()
+	var wait time.Duration
+	for _, k := range keys {
+		err := qtx.CreateLoginThrottle(ctx, k.Key)
+		if err != nil {
+			return 0, err
+		}
+
+		row, err := qtx.LockLoginThrottle(ctx, k.Key)
+		if err != nil {
+			return 0, err
+		}
+
+		if row.LockedUntil.Valid {
+			wait = max(wait, row.LockedUntil.Time.Sub(now))
+		}
+	}
+
+	if wait > 0 {
+		return wait, nil
+	}
+
+	for _, k := range keys {
+		attempts, err := qtx.RecordLoginFailure(ctx, database.RecordLoginFailureParams{
+			Key:           k.Key,
+			LastFailureAt: now.Add(-k.Policy.Window),
+		})
+		if err != nil {
+			return 0, err
+		}
+
+		lockout := k.Policy.Lockout(int(attempts))
+		if lockout == 0 {
+			continue
+		}
+
+		log.Printf("Locking out %s for %s after %d attempts", k.Key, lockout, attempts)
+		err = qtx.LockLogin(ctx, database.LockLoginParams{
+			Key:         k.Key,
+			LockedUntil: sql.NullTime{Time: now.Add(lockout), Valid: true},
+		})
+		if err != nil {
+			return 0, err
+		}
+	}
+
+	return 0, tx.Commit()
+}
+
+func respondWithLoginLockout(w http.ResponseWriter, wait time.Duration) {
+	w.Header().Set("Retry-After", strconv.Itoa(throttle.RetryAfter(wait)))
+	respondWithError(w, http.StatusTooManyRequests, "Too many failed login attempts, try again later")
+}
+
+// acquirePasswordCheck bounds how many argon2 comparisons run at once. Each
+// one costs a lot of CPU and memory, so without a bound a flood of logins
+// for many different accounts could still take the server down.
+func (cfg *apiConfig) acquirePasswordCheck(ctx context.Context) (func(), bool) {
+	timer := time.NewTimer(passwordCheckWait)
+	defer timer.Stop()
+
+	select {
+	case cfg.passwordChecks <- struct{}{}:
+		return func() { <-cfg.passwordChecks }, true
+	case <-timer.C:
+		return nil, false
+	case <-ctx.Done():
+		return nil, false
+	}
+}
+
+// handlerUnlockUser lifts a lockout on an account before it runs out.
+func (cfg *apiConfig) handlerUnlockUser(w http.ResponseWriter, r *http.Request) {
+	user, code, msg, ok := cfg.requirePathUser(r)
+	if !ok {
+		respondWithError(w, code, msg)
+		return
+	}
+
+	err := cfg.db.ClearLoginThrottle(r.Context(), accountThrottleKey(user.Email))
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Something went wrong while unlocking the user")
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func (cfg *apiConfig) pruneLoginThrottles(ctx context.Context) {
+	ticker := time.NewTicker(time.Hour)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			err := cfg.db.DeleteStaleLoginThrottles(ctx, time.Now().Add(-loginThrottleRetention))
+			if err != nil {
+				log.Printf("Error pruning login throttles: %s", err)
+			}
+		}
+	}
+}
@@ -0,0 +1,169 @@
+package main
+
+import (
+	"context"
+	"database/sql/driver"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/dennisdijkstra/go/internal/throttle"
+)
+
+type fakeThrottle struct {
+	failures      int64
+	lastFailureAt time.Time
+	lockedUntil   time.Time
+}
+
+// stubLoginThrottles answers the login_throttles queries from memory.
+func stubLoginThrottles(f *fakeDB) map[string]*fakeThrottle {
+	rows := map[string]*fakeThrottle{}
+
+	f.stub("CreateLoginThrottle", func(args []driver.Value) (fakeResult, error) {
+		key := args[0].(string)
+		if _, ok := rows[key]; !ok {
+			rows[key] = &fakeThrottle{lastFailureAt: time.Now()}
+		}
+		return fakeResult{rowsAffected: 1}, nil
+	})
+
+	f.stub("LockLoginThrottle", func(args []driver.Value) (fakeResult, error) {
+		key := args[0].(string)
+		row, ok := rows[key]
+		if !ok {
+			return fakeResult{}, nil
+		}
+
+		var lockedUntil driver.Value
+		if !row.lockedUntil.IsZero() {
+			lockedUntil = row.lockedUntil
+		}
+		return fakeResult{rows: [][]driver.Value{{key, row.failures, row.lastFailureAt, lockedUntil}}}, nil
+	})
+
+	f.stub("RecordLoginFailure", func(args []driver.Value) (fakeResult, error) {
+		key, cutoff := args[0].(string), args[1].(time.Time)
+		row, ok := rows[key]
+		if !ok {
+			row = &fakeThrottle{}
+			rows[key] = row
+		}
+
+		if row.lastFailureAt.Before(cutoff) {
+			row.failures = 1
+		} else {
+			row.failures++
+		}
+		row.lastFailureAt = time.Now()
+		return fakeResult{rows: [][]driver.Value{{row.failures}}}, nil
+	})
+
+	f.stub("LockLogin", func(args []driver.Value) (fakeResult, error) {
+		if row, ok := rows[args[0].(string)]; ok {
+			row.lockedUntil = args[1].(time.Time)
+		}
+		return fakeResult{rowsAffected: 1}, nil
+	})
+
+	f.stub("RefundLoginAttempt", func(args []driver.Value) (fakeResult, error) {
+		if row, ok := rows[args[0].(string)]; ok && row.failures > 0 {
+			row.failures--
+		}
+		return fakeResult{rowsAffected: 1}, nil
+	})
+
+	f.stub("ClearLoginThrottle", func(args []driver.Value) (fakeResult, error) {
+		delete(rows, args[0].(string))
+		return fakeResult{rowsAffected: 1}, nil
+	})
+
+	return rows
+}
+
+func TestReserveAttempt(t *testing.T) {
+	f := newFakeDB(t)
+	stubLoginThrottles(f)
+	cfg := &apiConfig{}
+	cfg.dbConn, cfg.db = f.open()
+
+	policy := throttle.Policy{Threshold: 3, BaseLockout: time.Minute, MaxLockout: time.Hour, Window: time.Hour}
+
+	// Concurrent attempts are counted one at a time, so exactly Threshold
+	// of them get through before the lockout.
+	var wg sync.WaitGroup
+	var mu sync.Mutex
+	allowed := 0
+	for range 10 {
+		wg.Go(func() {
+			wait, err := cfg.reserveAttempt(context.Background(), throttledKey{Key: "test:key", Policy: policy})
+			if err != nil {
+				t.Errorf("unexpected error: %v", err)
+				return
+			}
+			if wait == 0 {
+				mu.Lock()
+				allowed++
+				mu.Unlock()
+			}
+		})
+	}
+	wg.Wait()
+
+	if allowed != policy.Threshold {
+		t.Errorf("expected %d attempts to be allowed, got %d", policy.Threshold, allowed)
+	}
+
+	// A lockout on any key refuses the attempt without counting it
+	// against the others.
+	other := throttledKey{Key: "test:other", Policy: policy}
+	wait, err := cfg.reserveAttempt(context.Background(), other, throttledKey{Key: "test:key", Policy: policy})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if wait <= 0 || wait > time.Minute {
+		t.Errorf("expected to wait up to a minute, got %v", wait)
+	}
+
+	for range policy.Threshold - 1 {
+		if wait, _ := cfg.reserveAttempt(context.Background(), other); wait != 0 {
+			t.Fatalf("expected the other key to be unaffected, got a wait of %v", wait)
+		}
+	}
+}
+
+func TestLoginThrottleKeysIgnoreSpoofedForwardedFor(t *testing.T) {
+	cfg := &apiConfig{trustProxyHeaders: true}
+
+	keys := func(forwarded ...string) loginThrottleKeys {
+		req := httptest.NewRequest(http.MethodPost, "/api/login", nil)
+		req.RemoteAddr = "10.0.0.2:41234"
+		for _, value := range forwarded {
+			req.Header.Add("X-Forwarded-For", value)
+		}
+		return cfg.loginThrottleKeys(req, "user@example.com")
+	}
+
+	want := "ip:203.0.113.7"
+	for _, forwarded := range [][]string{
+		{"203.0.113.7"},
+		{"198.51.100.1, 203.0.113.7"},
+		{"192.0.2.99, 198.51.100.2,203.0.113.7"},
+		{"198.51.100.3", "203.0.113.7"},
+	} {
+		if got := keys(forwarded...).IP; got != want {
+			t.Errorf("X-Forwarded-For %q: expected %s, got %s", forwarded, want, got)
+		}
+	}
+
+	if got := keys().IP; got != "ip:10.0.0.2" {
+		t.Errorf("expected the peer address without X-Forwarded-For, got %s", got)
+	}
+
+	cfg.trustProxyHeaders = false
+	if got := keys("203.0.113.7").IP; got != "ip:10.0.0.2" {
+		t.Errorf("expected X-Forwarded-For to be ignored without a trusted proxy, got %s", got)
+	}
+}
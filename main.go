@@ -1,23 +1,58 @@
 package main
 
 import (
+	"context"
 	"database/sql"
 	"log"
 	"net/http"
+	"net/url"
 	"os"
-	"sync/atomic"
+	"runtime"
+	"strings"
+	"time"
 
+	"github.com/dennisdijkstra/go/internal/auth"
+	"github.com/dennisdijkstra/go/internal/blob"
 	"github.com/dennisdijkstra/go/internal/database"
+	"github.com/dennisdijkstra/go/internal/events"
+	"github.com/dennisdijkstra/go/internal/mail"
+	"github.com/dennisdijkstra/go/internal/profanity"
 	"github.com/dennisdijkstra/go/server"
 	"github.com/joho/godotenv"
 	_ "github.com/lib/pq"
 )
 
 type apiConfig struct {
-	fileserverHits atomic.Int32
-	db             *database.Queries
-	environment    string
-	jwtSecret      string
+	db          *database.Queries
+	dbConn      *sql.DB
+	environment string
+
+	metrics      *appMetrics
+	metricsToken string
+
+	signingKeys             *auth.KeySet
+	signingKeyEncryptionKey []byte
+	tokenRevocations        *auth.RevocationCache
+
+	trustProxyHeaders  bool
+	passwordChecks     chan struct{}
+	passwordResetSends chan struct{}
+
+	mailer                   mail.Mailer
+	appURL                   string
+	requireEmailVerification bool
+
+	profanityFilter profanity.Filter
+	profanityMode   profanity.Mode
+
+	blobStore   blob.Store
+	chirpEvents *events.Broker
+
+	polkaKey              string
+	polkaWebhookSecrets   []string
+	polkaWebhookTolerance time.Duration
+
+	webhookClient *http.Client
 }
 
 func main() {
@@ -25,20 +60,83 @@ func main() {
 	dbURL := os.Getenv("DB_URL")
 	environment := os.Getenv("ENVIRONMENT")
 	jwtSecret := os.Getenv("JWT_SECRET")
+	jwtSigningAlgEnv := os.Getenv("JWT_SIGNING_ALG")
+	signingKeyEncryptionKeyEnv := os.Getenv("SIGNING_KEY_ENCRYPTION_KEY")
 	polkaKey := os.Getenv("POLKA_KEY")
+	polkaWebhookSecretsEnv := os.Getenv("POLKA_WEBHOOK_SECRETS")
+	polkaWebhookToleranceEnv := os.Getenv("POLKA_WEBHOOK_TOLERANCE")
+	profanityModeEnv := os.Getenv("PROFANITY_MODE")
+	uploadDir := os.Getenv("UPLOAD_DIR")
+	trustProxyHeaders := os.Getenv("TRUST_PROXY_HEADERS") == "true"
+	appURL := os.Getenv("APP_URL")
+	requireEmailVerification := os.Getenv("REQUIRE_EMAIL_VERIFICATION") == "true"
+	metricsToken := os.Getenv("METRICS_TOKEN")
+
+	// Several comma-separated secrets can be active while one is rotated.
+	var polkaWebhookSecrets []string
+	for _, secret := range strings.Split(polkaWebhookSecretsEnv, ",") {
+		if secret = strings.TrimSpace(secret); secret != "" {
+			polkaWebhookSecrets = append(polkaWebhookSecrets, secret)
+		}
+	}
+
+	if polkaKey == "" && len(polkaWebhookSecrets) == 0 {
+		log.Fatal("POLKA_WEBHOOK_SECRETS or POLKA_KEY must be set")
+	}
+
+	polkaWebhookTolerance := 5 * time.Minute
+	if polkaWebhookToleranceEnv != "" {
+		tolerance, err := time.ParseDuration(polkaWebhookToleranceEnv)
+		if err != nil || tolerance <= 0 {
+			log.Fatal("POLKA_WEBHOOK_TOLERANCE must be a positive duration")
+		}
+		polkaWebhookTolerance = tolerance
+	}
 
-	if polkaKey == "" {
-		log.Fatal("POLKA_KEY must be set")
+	if metricsToken == "" && environment != "dev" {
+		log.Print("METRICS_TOKEN is not set, so /metrics is disabled")
 	}
 
-	if jwtSecret == "" {
-		log.Fatal("JWT_SECRET must be set")
+	jwtSigningAlg, ok := auth.ParseAlgorithm(jwtSigningAlgEnv)
+	if !ok {
+		log.Fatal("JWT_SIGNING_ALG must be RS256 or EdDSA")
+	}
+
+	// Private signing keys are stored encrypted under this key.
+	signingKeyEncryptionKey, err := auth.ParseKeyEncryptionKey(signingKeyEncryptionKeyEnv)
+	if err != nil {
+		log.Fatal("SIGNING_KEY_ENCRYPTION_KEY must be 32 bytes encoded as base64")
 	}
 
 	if dbURL == "" {
 		log.Fatal("DB_URL must be set")
 	}
 
+	profanityMode, ok := profanity.ParseMode(profanityModeEnv)
+	if !ok {
+		log.Fatal("PROFANITY_MODE must be one of mask, reject or flag")
+	}
+
+	if uploadDir == "" {
+		uploadDir = "uploads"
+	}
+
+	blobStore, err := blob.NewLocalStore(uploadDir)
+	if err != nil {
+		log.Fatalf("Failed to create upload directory: %s", err)
+	}
+
+	// Emailed links open /verify-email and /reset-password on the frontend,
+	// which posts the token back to the API. The API serves neither page.
+	if parsed, err := url.Parse(appURL); appURL == "" || err != nil || !parsed.IsAbs() {
+		log.Fatal("APP_URL must be set to the absolute URL of the frontend")
+	}
+
+	mailer, err := mailerFromEnv(environment)
+	if err != nil {
+		log.Fatalf("Failed to configure mailer: %s", err)
+	}
+
 	db, err := sql.Open("postgres", dbURL)
 	if err != nil {
 		log.Fatalf("Failed to connect to database: %s", err)
@@ -48,11 +146,49 @@ func main() {
 	dbQueries := database.New(db)
 
 	apiCfg := &apiConfig{
-		fileserverHits: atomic.Int32{},
-		db:             dbQueries,
-		environment:    environment,
-		jwtSecret:      jwtSecret,
+		db:          dbQueries,
+		dbConn:      db,
+		environment: environment,
+
+		metrics:      newAppMetrics(db),
+		metricsToken: metricsToken,
+
+		// JWT_SECRET only verifies HS256 tokens issued before signing keys
+		// were introduced.
+		signingKeys:             auth.NewKeySet(jwtSecret),
+		signingKeyEncryptionKey: signingKeyEncryptionKey,
+		tokenRevocations:        auth.NewRevocationCache(tokenStateSource{db: dbQueries}, 30*time.Second),
+
+		trustProxyHeaders:  trustProxyHeaders,
+		passwordChecks:     make(chan struct{}, runtime.NumCPU()),
+		passwordResetSends: make(chan struct{}, maxPasswordResetSends),
+
+		mailer:                   mailer,
+		appURL:                   strings.TrimSuffix(appURL, "/"),
+		requireEmailVerification: requireEmailVerification,
+
+		profanityFilter: profanity.NewWordListFilter(dbQueries, time.Minute),
+		profanityMode:   profanityMode,
+
+		blobStore:   blobStore,
+		chirpEvents: events.NewBroker(1000, 64),
+
+		polkaKey:              polkaKey,
+		polkaWebhookSecrets:   polkaWebhookSecrets,
+		polkaWebhookTolerance: polkaWebhookTolerance,
+
+		webhookClient: newWebhookClient(environment == "dev"),
+	}
+
+	if err := apiCfg.initSigningKeys(context.Background(), jwtSigningAlg); err != nil {
+		log.Fatalf("Failed to load signing keys: %s", err)
 	}
+	go apiCfg.reloadSigningKeys(context.Background())
+	go apiCfg.pruneLoginThrottles(context.Background())
+	go apiCfg.pruneStaleAttachments(context.Background())
+	go apiCfg.runSubscriptionExpiry(context.Background())
+	go apiCfg.runWebhookDispatcher(context.Background())
+
 	mux := http.NewServeMux()
 
 	fs := http.StripPrefix("/app", http.FileServer(http.Dir(".")))
@@ -67,26 +203,91 @@ func main() {
 		w.Write([]byte("OK"))
 	})
 
+	mux.HandleFunc("GET /metrics", apiCfg.handlerPrometheusMetrics)
+
+	mux.HandleFunc("GET /.well-known/jwks.json", apiCfg.handlerGetJWKS)
+
+	mux.HandleFunc("GET /media/{attachmentID}", apiCfg.handlerGetMedia)
+	mux.HandleFunc("POST /api/attachments", apiCfg.handlerUploadAttachment)
+
 	mux.HandleFunc("POST /api/chirps", apiCfg.handlerCreateChirp)
 	mux.HandleFunc("GET /api/chirps", apiCfg.handlerGetChirps)
+	mux.HandleFunc("GET /api/chirps/search", apiCfg.handlerSearchChirps)
+	mux.HandleFunc("GET /api/chirps/stream", apiCfg.handlerStreamChirps)
 	mux.HandleFunc("GET /api/chirps/{chirpID}", apiCfg.handlerGetChirpByID)
+	mux.HandleFunc("PUT /api/chirps/{chirpID}", apiCfg.handlerUpdateChirp)
 	mux.HandleFunc("DELETE /api/chirps/{chirpID}", apiCfg.handlerDeleteChirpByID)
+	mux.HandleFunc("GET /api/chirps/{chirpID}/revisions", apiCfg.handlerGetChirpRevisions)
+	mux.HandleFunc("GET /api/chirps/{chirpID}/thread", apiCfg.handlerGetChirpThread)
+	mux.HandleFunc("POST /api/chirps/{chirpID}/reactions", apiCfg.handlerCreateChirpReaction)
+	mux.HandleFunc("DELETE /api/chirps/{chirpID}/reactions", apiCfg.handlerDeleteChirpReaction)
 
 	mux.HandleFunc("POST /api/users", apiCfg.handlerCreateUser)
 	mux.HandleFunc("POST /api/login", apiCfg.handlerLoginUser)
+	mux.HandleFunc("POST /api/login/mfa", apiCfg.handlerLoginMFA)
 	mux.HandleFunc("PUT /api/users", apiCfg.handlerUpdateUser)
+	mux.HandleFunc("POST /api/users/verify", apiCfg.handlerVerifyEmail)
+	mux.HandleFunc("POST /api/users/verify/resend", apiCfg.handlerResendEmailVerification)
+	mux.HandleFunc("POST /api/users/2fa", apiCfg.handlerEnrollTOTP)
+	mux.HandleFunc("POST /api/users/2fa/confirm", apiCfg.handlerConfirmTOTP)
+	mux.HandleFunc("DELETE /api/users/2fa", apiCfg.handlerDisableTOTP)
+	mux.HandleFunc("POST /api/users/{userID}/follow", apiCfg.handlerFollowUser)
+	mux.HandleFunc("DELETE /api/users/{userID}/follow", apiCfg.handlerUnfollowUser)
+	mux.HandleFunc("GET /api/users/{userID}/followers", apiCfg.handlerGetFollowers)
+	mux.HandleFunc("GET /api/users/{userID}/following", apiCfg.handlerGetFollowing)
+
+	mux.HandleFunc("GET /api/users/me/mentions", apiCfg.handlerGetMentions)
+
+	mux.HandleFunc("GET /api/subscription", apiCfg.handlerGetSubscription)
+
+	mux.HandleFunc("POST /api/webhooks", apiCfg.handlerCreateWebhookEndpoint)
+	mux.HandleFunc("GET /api/webhooks", apiCfg.handlerGetWebhookEndpoints)
+	mux.HandleFunc("DELETE /api/webhooks/{webhookID}", apiCfg.handlerDeleteWebhookEndpoint)
+	mux.HandleFunc("GET /api/webhooks/{webhookID}/deliveries", apiCfg.handlerGetWebhookDeliveries)
+	mux.HandleFunc("GET /api/webhooks/{webhookID}/deliveries/{deliveryID}/attempts", apiCfg.handlerGetWebhookDeliveryAttempts)
+
+	mux.HandleFunc("GET /api/timeline", apiCfg.handlerGetTimeline)
+
+	mux.HandleFunc("GET /api/hashtags/trending", apiCfg.handlerGetTrendingHashtags)
+	mux.HandleFunc("GET /api/hashtags/{tag}/chirps", apiCfg.handlerGetHashtagChirps)
+
+	mux.HandleFunc("POST /api/password/forgot", apiCfg.handlerForgotPassword)
+	mux.HandleFunc("POST /api/password/reset", apiCfg.handlerResetPassword)
 
 	mux.HandleFunc("POST /api/refresh", apiCfg.handlerRefresh)
 	mux.HandleFunc("POST /api/revoke", apiCfg.handlerRevoke)
+	mux.HandleFunc("POST /api/logout", apiCfg.handlerLogout)
 
-	mux.HandleFunc("GET /admin/metrics", apiCfg.handlerWriteMetrics)
+	mux.HandleFunc("GET /api/sessions", apiCfg.handlerGetSessions)
+	mux.HandleFunc("DELETE /api/sessions", apiCfg.handlerDeleteAllSessions)
+	mux.HandleFunc("DELETE /api/sessions/{sessionID}", apiCfg.handlerDeleteSession)
+
+	// Admin routes are gated by scope. /admin/reset stays outside so test
+	// suites can wipe a dev database before any admin exists; it refuses
+	// to run outside dev on its own.
 	mux.HandleFunc("POST /admin/reset", apiCfg.handlerResetAll)
 
+	mux.Handle("GET /admin/metrics", apiCfg.middlewareRequireScope(auth.ScopeAdmin, http.HandlerFunc(apiCfg.handlerWriteMetrics)))
+
+	mux.Handle("PUT /admin/users/{userID}/role", apiCfg.middlewareRequireScope(auth.ScopeAdmin, http.HandlerFunc(apiCfg.handlerUpdateUserRole)))
+	mux.Handle("POST /admin/users/{userID}/unlock", apiCfg.middlewareRequireScope(auth.ScopeAdmin, http.HandlerFunc(apiCfg.handlerUnlockUser)))
+
+	mux.Handle("GET /admin/signing-keys", apiCfg.middlewareRequireScope(auth.ScopeAdmin, http.HandlerFunc(apiCfg.handlerListSigningKeys)))
+	mux.Handle("POST /admin/signing-keys/rotate", apiCfg.middlewareRequireScope(auth.ScopeAdmin, http.HandlerFunc(apiCfg.handlerRotateSigningKey)))
+
+	mux.Handle("GET /admin/webhook-events/failed", apiCfg.middlewareRequireScope(auth.ScopeAdmin, http.HandlerFunc(apiCfg.handlerGetFailedWebhookEvents)))
+	mux.Handle("POST /admin/webhook-events/{eventID}/replay", apiCfg.middlewareRequireScope(auth.ScopeAdmin, http.HandlerFunc(apiCfg.handlerReplayWebhookEvent)))
+
+	mux.Handle("GET /admin/profanity", apiCfg.middlewareRequireScope(auth.ScopeModerate, http.HandlerFunc(apiCfg.handlerListProfaneWords)))
+	mux.Handle("POST /admin/profanity", apiCfg.middlewareRequireScope(auth.ScopeModerate, http.HandlerFunc(apiCfg.handlerCreateProfaneWord)))
+	mux.Handle("DELETE /admin/profanity/{word}", apiCfg.middlewareRequireScope(auth.ScopeModerate, http.HandlerFunc(apiCfg.handlerDeleteProfaneWord)))
+	mux.Handle("GET /admin/profanity/flags", apiCfg.middlewareRequireScope(auth.ScopeModerate, http.HandlerFunc(apiCfg.handlerGetChirpFlags)))
+
 	mux.HandleFunc("POST /api/polka/webhooks", apiCfg.handlerPolkaWebhook)
 
 	httpServer := &http.Server{
 		Addr:    ":8080",
-		Handler: mux,
+		Handler: apiCfg.middlewareInstrument(mux),
 	}
 	httpServer.ListenAndServe()
 }
@@ -1,19 +1,175 @@
 package main
 
 import (
+	"crypto/subtle"
+	"database/sql"
 	"fmt"
+	"log"
 	"net/http"
+	"strconv"
+	"sync/atomic"
+	"time"
+
+	"github.com/dennisdijkstra/go/internal/auth"
+	"github.com/dennisdijkstra/go/internal/metrics"
 )
 
+// appMetrics are the metrics exposed on /metrics. The admin page reads from
+// the same registry.
+type appMetrics struct {
+	registry *metrics.Registry
+
+	fileserverHits *metrics.Counter
+	// fileserverHitsAtReset is the hit count when the admin page was last
+	// reset. The counter itself only ever goes up, as rate() expects.
+	fileserverHitsAtReset atomic.Uint64
+
+	httpRequests        *metrics.CounterVec
+	httpRequestDuration *metrics.HistogramVec
+	httpInFlight        *metrics.Gauge
+
+	chirpsCreated *metrics.Counter
+	logins        *metrics.Counter
+	loginFailures *metrics.Counter
+}
+
+func newAppMetrics(db *sql.DB) *appMetrics {
+	registry := metrics.NewRegistry()
+
+	m := &appMetrics{
+		registry: registry,
+
+		fileserverHits: registry.NewCounter("chirpy_fileserver_hits_total", "Requests served from /app/."),
+
+		httpRequests:        registry.NewCounterVec("chirpy_http_requests_total", "HTTP requests by route pattern and status code.", "route", "code"),
+		httpRequestDuration: registry.NewHistogramVec("chirpy_http_request_duration_seconds", "HTTP request latency by route pattern and status code.", metrics.DefaultBuckets, "route", "code"),
+		httpInFlight:        registry.NewGauge("chirpy_http_requests_in_flight", "HTTP requests currently being served."),
+
+		chirpsCreated: registry.NewCounter("chirpy_chirps_created_total", "Chirps created."),
+		logins:        registry.NewCounter("chirpy_logins_total", "Successful logins."),
+		loginFailures: registry.NewCounter("chirpy_login_failures_total", "Failed login attempts, including failed second factors."),
+	}
+
+	stat := func(fn func(sql.DBStats) float64) func() float64 {
+		return func() float64 { return fn(db.Stats()) }
+	}
+	registry.NewGaugeFunc("chirpy_db_max_open_connections", "Maximum number of open database connections.",
+		stat(func(s sql.DBStats) float64 { return float64(s.MaxOpenConnections) }))
+	registry.NewGaugeFunc("chirpy_db_open_connections", "Open database connections, in use or idle.",
+		stat(func(s sql.DBStats) float64 { return float64(s.OpenConnections) }))
+	registry.NewGaugeFunc("chirpy_db_in_use_connections", "Database connections currently in use.",
+		stat(func(s sql.DBStats) float64 { return float64(s.InUse) }))
+	registry.NewGaugeFunc("chirpy_db_idle_connections", "Idle database connections.",
+		stat(func(s sql.DBStats) float64 { return float64(s.Idle) }))
+	registry.NewCounterFunc("chirpy_db_wait_count_total", "Times a query waited for a database connection.",
+		stat(func(s sql.DBStats) float64 { return float64(s.WaitCount) }))
+	registry.NewCounterFunc("chirpy_db_wait_duration_seconds_total", "Time spent waiting for database connections.",
+		stat(func(s sql.DBStats) float64 { return s.WaitDuration.Seconds() }))
+	registry.NewCounterFunc("chirpy_db_max_idle_closed_total", "Connections closed because of the idle connection limit.",
+		stat(func(s sql.DBStats) float64 { return float64(s.MaxIdleClosed) }))
+	registry.NewCounterFunc("chirpy_db_max_idle_time_closed_total", "Connections closed because they were idle too long.",
+		stat(func(s sql.DBStats) float64 { return float64(s.MaxIdleTimeClosed) }))
+	registry.NewCounterFunc("chirpy_db_max_lifetime_closed_total", "Connections closed because they reached their maximum lifetime.",
+		stat(func(s sql.DBStats) float64 { return float64(s.MaxLifetimeClosed) }))
+
+	return m
+}
+
+// fileserverHitsSinceReset is the hit count the admin page shows.
+func (m *appMetrics) fileserverHitsSinceReset() uint64 {
+	return m.fileserverHits.Value() - m.fileserverHitsAtReset.Load()
+}
+
+// resetFileserverHits restarts the admin page's count from zero without
+// touching the exported counter.
+func (m *appMetrics) resetFileserverHits() {
+	m.fileserverHitsAtReset.Store(m.fileserverHits.Value())
+}
+
 func (cfg *apiConfig) middlewareMetricsInc(next http.Handler) http.Handler {
 	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
-		cfg.fileserverHits.Add(1)
+		cfg.metrics.fileserverHits.Inc()
 		next.ServeHTTP(w, r)
 	})
 }
 
+// middlewareInstrument counts and times every request. It wraps the whole
+// mux, and labels requests with the pattern the mux matched rather than the
+// path, so IDs in paths do not create a series each.
+func (cfg *apiConfig) middlewareInstrument(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		cfg.metrics.httpInFlight.Inc()
+		defer cfg.metrics.httpInFlight.Dec()
+
+		start := time.Now()
+		recorder := &statusRecorder{ResponseWriter: w}
+		next.ServeHTTP(recorder, r)
+
+		route := r.Pattern
+		if route == "" {
+			route = "unmatched"
+		}
+		status := recorder.status
+		if status == 0 {
+			status = http.StatusOK
+		}
+		code := strconv.Itoa(status)
+
+		cfg.metrics.httpRequests.Inc(route, code)
+		cfg.metrics.httpRequestDuration.Observe(time.Since(start).Seconds(), route, code)
+	})
+}
+
+type statusRecorder struct {
+	http.ResponseWriter
+	status int
+}
+
+func (r *statusRecorder) WriteHeader(code int) {
+	if r.status == 0 {
+		r.status = code
+	}
+	r.ResponseWriter.WriteHeader(code)
+}
+
+func (r *statusRecorder) Write(b []byte) (int, error) {
+	if r.status == 0 {
+		r.status = http.StatusOK
+	}
+	return r.ResponseWriter.Write(b)
+}
+
+// Unwrap lets http.ResponseController reach the underlying writer, which
+// streaming responses need in order to flush.
+func (r *statusRecorder) Unwrap() http.ResponseWriter {
+	return r.ResponseWriter
+}
+
+// handlerPrometheusMetrics serves every metric in the Prometheus text
+// format. Scrapers must send METRICS_TOKEN as a bearer token. Without one
+// configured, metrics are only served in development.
+func (cfg *apiConfig) handlerPrometheusMetrics(w http.ResponseWriter, r *http.Request) {
+	if cfg.metricsToken == "" {
+		if cfg.environment != "dev" {
+			respondWithError(w, http.StatusForbidden, "Forbidden")
+			return
+		}
+	} else {
+		token, err := auth.GetBearerToken(r.Header)
+		if err != nil || subtle.ConstantTimeCompare([]byte(token), []byte(cfg.metricsToken)) != 1 {
+			respondWithError(w, http.StatusUnauthorized, "Unauthorized")
+			return
+		}
+	}
+
+	w.Header().Set("Content-Type", "text/plain; version=0.0.4; charset=utf-8")
+	if err := cfg.metrics.registry.WriteText(w); err != nil {
+		log.Printf("Error writing metrics: %s", err)
+	}
+}
+
 func (cfg *apiConfig) handlerWriteMetrics(w http.ResponseWriter, r *http.Request) {
-	numberOfRequests := cfg.fileserverHits.Load()
+	numberOfRequests := cfg.metrics.fileserverHitsSinceReset()
 	w.Header().Set("Content-Type", "text/html; charset=utf-8")
 	template := fmt.Sprintf(`
 		<html>
@@ -0,0 +1,74 @@
+package main
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestPrometheusMetricsAccess(t *testing.T) {
+	tests := []struct {
+		name        string
+		environment string
+		token       string
+		auth        string
+		want        int
+	}{
+		{"no token outside dev", "prod", "", "", http.StatusForbidden},
+		{"no token in dev", "dev", "", "", http.StatusOK},
+		{"missing bearer token", "prod", "scrape-secret", "", http.StatusUnauthorized},
+		{"wrong bearer token", "prod", "scrape-secret", "Bearer wrong", http.StatusUnauthorized},
+		{"right bearer token", "prod", "scrape-secret", "Bearer scrape-secret", http.StatusOK},
+		{"token still required in dev", "dev", "scrape-secret", "", http.StatusUnauthorized},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			f := newFakeDB(t)
+			cfg := &apiConfig{environment: tt.environment, metricsToken: tt.token}
+			cfg.dbConn, cfg.db = f.open()
+			cfg.metrics = newAppMetrics(cfg.dbConn)
+
+			req := httptest.NewRequest(http.MethodGet, "/metrics", nil)
+			if tt.auth != "" {
+				req.Header.Set("Authorization", tt.auth)
+			}
+			rec := httptest.NewRecorder()
+			cfg.handlerPrometheusMetrics(rec, req)
+
+			if rec.Code != tt.want {
+				t.Errorf("expected status %d, got %d", tt.want, rec.Code)
+			}
+			if rec.Code == http.StatusOK && !strings.Contains(rec.Body.String(), "chirpy_fileserver_hits_total") {
+				t.Errorf("expected metrics in the response, got %s", rec.Body)
+			}
+		})
+	}
+}
+
+func TestResetFileserverHitsKeepsCounterMonotonic(t *testing.T) {
+	f := newFakeDB(t)
+	cfg := &apiConfig{}
+	cfg.dbConn, cfg.db = f.open()
+	cfg.metrics = newAppMetrics(cfg.dbConn)
+
+	for range 3 {
+		cfg.metrics.fileserverHits.Inc()
+	}
+	cfg.metrics.resetFileserverHits()
+	cfg.metrics.fileserverHits.Inc()
+
+	if got := cfg.metrics.fileserverHitsSinceReset(); got != 1 {
+		t.Errorf("expected 1 hit since the reset, got %d", got)
+	}
+	if got := cfg.metrics.fileserverHits.Value(); got != 4 {
+		t.Errorf("expected the exported counter to keep counting, got %d", got)
+	}
+
+	rec := httptest.NewRecorder()
+	cfg.handlerWriteMetrics(rec, httptest.NewRequest(http.MethodGet, "/admin/metrics", nil))
+	if !strings.Contains(rec.Body.String(), "visited 1 times") {
+		t.Errorf("expected the admin page to count from the reset, got %s", rec.Body)
+	}
+}
@@ -0,0 +1,423 @@
+package main
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"net/http"
+	"net/url"
+	"slices"
+	"time"
+
+	"github.com/dennisdijkstra/go/internal/auth"
+	"github.com/dennisdijkstra/go/internal/database"
+	"github.com/google/uuid"
+)
+
+const (
+	eventUserUpgraded = "user.upgraded"
+
+	maxWebhookEndpointsPerUser = 10
+	// maxGlobalWebhookEndpoints caps the endpoints, across all users, that
+	// receive globalWebhookEvents. Every chirp is delivered to each of
+	// them, so the cap bounds the work one chirp can cause.
+	maxGlobalWebhookEndpoints = 100
+)
+
+var (
+	// outboundWebhookEvents are the event types integrators can subscribe to.
+	outboundWebhookEvents = []string{eventChirpCreated, eventChirpDeleted, eventUserUpgraded}
+	// globalWebhookEvents are the event types sent to every subscriber
+	// rather than only the user they concern.
+	globalWebhookEvents = []string{eventChirpCreated, eventChirpDeleted}
+)
+
+type WebhookEndpointParams struct {
+	URL    string   `json:"url"`
+	Events []string `json:"events"`
+}
+
+type WebhookEndpoint struct {
+	ID        uuid.UUID `json:"id"`
+	CreatedAt time.Time `json:"created_at"`
+	URL       string    `json:"url"`
+	Events    []string  `json:"events"`
+	// Secret signs deliveries to the endpoint. It is only returned when the
+	// endpoint is created.
+	Secret string `json:"secret,omitempty"`
+}
+
+type WebhookDelivery struct {
+	ID            uuid.UUID  `json:"id"`
+	CreatedAt     time.Time  `json:"created_at"`
+	EventID       uuid.UUID  `json:"event_id"`
+	EventType     string     `json:"event_type"`
+	Status        string     `json:"status"`
+	Attempts      int32      `json:"attempts"`
+	NextAttemptAt *time.Time `json:"next_attempt_at"`
+	DeliveredAt   *time.Time `json:"delivered_at"`
+	LastError     string     `json:"last_error,omitempty"`
+}
+
+type WebhookDeliveriesPage struct {
+	Deliveries []WebhookDelivery `json:"deliveries"`
+	NextCursor string            `json:"next_cursor"`
+}
+
+type WebhookDeliveryAttempt struct {
+	CreatedAt  time.Time `json:"created_at"`
+	StatusCode *int32    `json:"status_code"`
+	Error      string    `json:"error,omitempty"`
+	DurationMs int32     `json:"duration_ms"`
+}
+
+// OutboundWebhookEvent is the body of every delivery. ID stays the same
+// across retries, so receivers can discard duplicates.
+type OutboundWebhookEvent struct {
+	ID        uuid.UUID `json:"id"`
+	Type      string    `json:"type"`
+	CreatedAt time.Time `json:"created_at"`
+	Data      any       `json:"data"`
+}
+
+type UserUpgradedEvent struct {
+	UserID uuid.UUID `json:"user_id"`
+}
+
+func (cfg *apiConfig) handlerCreateWebhookEndpoint(w http.ResponseWriter, r *http.Request) {
+	userID, code, msg, ok := cfg.requireJWTUserID(r)
+	if !ok {
+		respondWithError(w, code, msg)
+		return
+	}
+
+	decoder := json.NewDecoder(r.Body)
+	params := WebhookEndpointParams{}
+	err := decoder.Decode(&params)
+
+	if err != nil {
+		respondWithError(w, http.StatusBadRequest, "Something went wrong while decoding the request body")
+		return
+	}
+
+	if !cfg.validWebhookURL(params.URL) {
+		respondWithError(w, http.StatusBadRequest, "Invalid webhook URL")
+		return
+	}
+
+	if len(params.Events) == 0 {
+		respondWithError(w, http.StatusBadRequest, "At least one event is required")
+		return
+	}
+
+	for _, event := range params.Events {
+		if !slices.Contains(outboundWebhookEvents, event) {
+			respondWithError(w, http.StatusBadRequest, "Unknown event type: "+event)
+			return
+		}
+	}
+
+	secret, err := auth.MakeOneTimeToken()
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Something went wrong while creating the webhook secret")
+		return
+	}
+
+	// The caps are checked with the table locked against other inserts, so
+	// concurrent requests cannot both take the last slot.
+	tx, err := cfg.dbConn.BeginTx(r.Context(), nil)
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Something went wrong while creating the webhook")
+		return
+	}
+	defer tx.Rollback()
+	qtx := cfg.db.WithTx(tx)
+
+	err = qtx.LockWebhookEndpoints(r.Context())
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Something went wrong while creating the webhook")
+		return
+	}
+
+	existing, err := qtx.GetWebhookEndpoints(r.Context(), userID)
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Something went wrong while fetching webhooks")
+		return
+	}
+
+	if len(existing) >= maxWebhookEndpointsPerUser {
+		respondWithError(w, http.StatusBadRequest, "Too many webhooks")
+		return
+	}
+
+	if slices.ContainsFunc(params.Events, func(event string) bool { return slices.Contains(globalWebhookEvents, event) }) {
+		subscribers, err := qtx.CountWebhookEndpointsForEvents(r.Context(), globalWebhookEvents)
+		if err != nil {
+			respondWithError(w, http.StatusInternalServerError, "Something went wrong while fetching webhooks")
+			return
+		}
+
+		if subscribers >= maxGlobalWebhookEndpoints {
+			respondWithError(w, http.StatusConflict, "No more webhooks can subscribe to chirp events")
+			return
+		}
+	}
+
+	slices.Sort(params.Events)
+	endpoint, err := qtx.CreateWebhookEndpoint(r.Context(), database.CreateWebhookEndpointParams{
+		UserID:     userID,
+		Url:        params.URL,
+		Secret:     secret,
+		EventTypes: slices.Compact(params.Events),
+	})
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Something went wrong while creating the webhook")
+		return
+	}
+
+	if err := tx.Commit(); err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Something went wrong while creating the webhook")
+		return
+	}
+
+	response := webhookEndpointFromDB(endpoint)
+	response.Secret = endpoint.Secret
+
+	respondWithJSON(w, http.StatusCreated, response)
+}
+
+func (cfg *apiConfig) handlerGetWebhookEndpoints(w http.ResponseWriter, r *http.Request) {
+	userID, code, msg, ok := cfg.requireJWTUserID(r)
+	if !ok {
+		respondWithError(w, code, msg)
+		return
+	}
+
+	endpoints, err := cfg.db.GetWebhookEndpoints(r.Context(), userID)
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Something went wrong while fetching webhooks")
+		return
+	}
+
+	response := make([]WebhookEndpoint, 0, len(endpoints))
+	for _, endpoint := range endpoints {
+		response = append(response, webhookEndpointFromDB(endpoint))
+	}
+
+	respondWithJSON(w, http.StatusOK, response)
+}
+
+func (cfg *apiConfig) handlerDeleteWebhookEndpoint(w http.ResponseWriter, r *http.Request) {
+	userID, code, msg, ok := cfg.requireJWTUserID(r)
+	if !ok {
+		respondWithError(w, code, msg)
+		return
+	}
+
+	endpointID, err := uuid.Parse(r.PathValue("webhookID"))
+	if err != nil {
+		respondWithError(w, http.StatusBadRequest, "Invalid webhook ID")
+		return
+	}
+
+	deleted, err := cfg.db.DeleteWebhookEndpoint(r.Context(), database.DeleteWebhookEndpointParams{
+		ID:     endpointID,
+		UserID: userID,
+	})
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Something went wrong while deleting the webhook")
+		return
+	}
+
+	if deleted == 0 {
+		respondWithError(w, http.StatusNotFound, "Webhook not found")
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// handlerGetWebhookDeliveries lists deliveries to one of the caller's
+// endpoints, newest first.
+func (cfg *apiConfig) handlerGetWebhookDeliveries(w http.ResponseWriter, r *http.Request) {
+	endpoint, code, msg, ok := cfg.requireOwnedWebhookEndpoint(r)
+	if !ok {
+		respondWithError(w, code, msg)
+		return
+	}
+
+	limit, cursorCreatedAt, cursorID, err := parsePageParams(r)
+	if err != nil {
+		respondWithError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	deliveries, err := cfg.db.GetWebhookDeliveriesPage(r.Context(), database.GetWebhookDeliveriesPageParams{
+		EndpointID:      endpoint.ID,
+		CursorCreatedAt: cursorCreatedAt,
+		CursorID:        cursorID,
+		PageSize:        limit + 1,
+	})
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Something went wrong while fetching webhook deliveries")
+		return
+	}
+
+	nextCursor := ""
+	if len(deliveries) > int(limit) {
+		deliveries = deliveries[:limit]
+		last := deliveries[len(deliveries)-1]
+		nextCursor = encodeCursor(last.CreatedAt, last.ID)
+	}
+
+	response := WebhookDeliveriesPage{
+		Deliveries: make([]WebhookDelivery, 0, len(deliveries)),
+		NextCursor: nextCursor,
+	}
+	for _, delivery := range deliveries {
+		response.Deliveries = append(response.Deliveries, webhookDeliveryFromDB(delivery))
+	}
+
+	respondWithJSON(w, http.StatusOK, response)
+}
+
+// handlerGetWebhookDeliveryAttempts lists every attempt at one delivery,
+// oldest first.
+func (cfg *apiConfig) handlerGetWebhookDeliveryAttempts(w http.ResponseWriter, r *http.Request) {
+	endpoint, code, msg, ok := cfg.requireOwnedWebhookEndpoint(r)
+	if !ok {
+		respondWithError(w, code, msg)
+		return
+	}
+
+	deliveryID, err := uuid.Parse(r.PathValue("deliveryID"))
+	if err != nil {
+		respondWithError(w, http.StatusBadRequest, "Invalid delivery ID")
+		return
+	}
+
+	delivery, err := cfg.db.GetWebhookDelivery(r.Context(), deliveryID)
+	if err != nil && err != sql.ErrNoRows {
+		respondWithError(w, http.StatusInternalServerError, "Something went wrong while fetching the webhook delivery")
+		return
+	}
+
+	if err == sql.ErrNoRows || delivery.EndpointID != endpoint.ID {
+		respondWithError(w, http.StatusNotFound, "Webhook delivery not found")
+		return
+	}
+
+	attempts, err := cfg.db.GetWebhookDeliveryAttempts(r.Context(), delivery.ID)
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Something went wrong while fetching webhook delivery attempts")
+		return
+	}
+
+	response := make([]WebhookDeliveryAttempt, 0, len(attempts))
+	for _, attempt := range attempts {
+		item := WebhookDeliveryAttempt{
+			CreatedAt:  attempt.CreatedAt,
+			Error:      attempt.Error.String,
+			DurationMs: attempt.DurationMs,
+		}
+		if attempt.StatusCode.Valid {
+			item.StatusCode = &attempt.StatusCode.Int32
+		}
+		response = append(response, item)
+	}
+
+	respondWithJSON(w, http.StatusOK, response)
+}
+
+// requireOwnedWebhookEndpoint loads the endpoint named by the {webhookID}
+// path value and checks that it belongs to the caller. Other users'
+// endpoints are reported as missing.
+func (cfg *apiConfig) requireOwnedWebhookEndpoint(r *http.Request) (database.WebhookEndpoint, int, string, bool) {
+	userID, code, msg, ok := cfg.requireJWTUserID(r)
+	if !ok {
+		return database.WebhookEndpoint{}, code, msg, false
+	}
+
+	endpointID, err := uuid.Parse(r.PathValue("webhookID"))
+	if err != nil {
+		return database.WebhookEndpoint{}, http.StatusBadRequest, "Invalid webhook ID", false
+	}
+
+	endpoint, err := cfg.db.GetWebhookEndpoint(r.Context(), endpointID)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return database.WebhookEndpoint{}, http.StatusNotFound, "Webhook not found", false
+		}
+		return database.WebhookEndpoint{}, http.StatusInternalServerError, "Something went wrong while fetching the webhook", false
+	}
+
+	if endpoint.UserID != userID {
+		return database.WebhookEndpoint{}, http.StatusNotFound, "Webhook not found", false
+	}
+
+	return endpoint, 0, "", true
+}
+
+// validWebhookURL accepts absolute HTTPS URLs, and plain HTTP in
+// development.
+func (cfg *apiConfig) validWebhookURL(raw string) bool {
+	u, err := url.Parse(raw)
+	if err != nil || u.Host == "" || u.User != nil {
+		return false
+	}
+
+	return u.Scheme == "https" || (u.Scheme == "http" && cfg.environment == "dev")
+}
+
+// enqueueWebhookEvent writes the event to the outbox, using q so it commits
+// together with the change it reports. The dispatcher later delivers it to
+// every endpoint subscribed to eventType; with a valid ownerID only that
+// user's endpoints receive it.
+func enqueueWebhookEvent(ctx context.Context, q *database.Queries, eventType string, ownerID uuid.NullUUID, data any) error {
+	event := OutboundWebhookEvent{
+		ID:        uuid.New(),
+		Type:      eventType,
+		CreatedAt: time.Now().UTC(),
+		Data:      data,
+	}
+
+	payload, err := json.Marshal(event)
+	if err != nil {
+		return err
+	}
+
+	return q.CreateOutboundWebhookEvent(ctx, database.CreateOutboundWebhookEventParams{
+		ID:        event.ID,
+		CreatedAt: event.CreatedAt,
+		EventType: eventType,
+		Payload:   string(payload),
+		OwnerID:   ownerID,
+	})
+}
+
+func webhookEndpointFromDB(endpoint database.WebhookEndpoint) WebhookEndpoint {
+	return WebhookEndpoint{
+		ID:        endpoint.ID,
+		CreatedAt: endpoint.CreatedAt,
+		URL:       endpoint.Url,
+		Events:    endpoint.EventTypes,
+	}
+}
+
+func webhookDeliveryFromDB(delivery database.WebhookDelivery) WebhookDelivery {
+	response := WebhookDelivery{
+		ID:          delivery.ID,
+		CreatedAt:   delivery.CreatedAt,
+		EventID:     delivery.EventID,
+		EventType:   delivery.EventType,
+		Status:      delivery.Status,
+		Attempts:    delivery.Attempts,
+		DeliveredAt: nullTimePtr(delivery.DeliveredAt),
+		LastError:   delivery.LastError.String,
+	}
+	if delivery.Status == webhookDeliveryPending {
+		response.NextAttemptAt = &delivery.NextAttemptAt
+	}
+
+	return response
+}
@@ -0,0 +1,118 @@
+package main
+
+import (
+	"database/sql"
+	"encoding/base64"
+	"errors"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+const (
+	defaultPageSize = 50
+	maxPageSize     = 100
+)
+
+type pageCursor struct {
+	CreatedAt time.Time
+	ID        uuid.UUID
+}
+
+// encodeCursor turns the keyset position of the last row on a page into an
+// opaque token that clients pass back as ?cursor= to fetch the next page.
+func encodeCursor(createdAt time.Time, id uuid.UUID) string {
+	raw := createdAt.UTC().Format(time.RFC3339Nano) + "|" + id.String()
+	return base64.RawURLEncoding.EncodeToString([]byte(raw))
+}
+
+func decodeCursor(cursor string) (pageCursor, error) {
+	raw, err := base64.RawURLEncoding.DecodeString(cursor)
+	if err != nil {
+		return pageCursor{}, err
+	}
+
+	createdAtStr, idStr, found := strings.Cut(string(raw), "|")
+	if !found {
+		return pageCursor{}, errors.New("malformed cursor")
+	}
+
+	createdAt, err := time.Parse(time.RFC3339Nano, createdAtStr)
+	if err != nil {
+		return pageCursor{}, err
+	}
+
+	id, err := uuid.Parse(idStr)
+	if err != nil {
+		return pageCursor{}, err
+	}
+
+	return pageCursor{CreatedAt: createdAt, ID: id}, nil
+}
+
+// encodeOffsetCursor is used where results are not ordered by a stable
+// keyset, such as search results ordered by relevance.
+func encodeOffsetCursor(offset int32) string {
+	return base64.RawURLEncoding.EncodeToString([]byte("offset|" + strconv.Itoa(int(offset))))
+}
+
+func decodeOffsetCursor(cursor string) (int32, error) {
+	raw, err := base64.RawURLEncoding.DecodeString(cursor)
+	if err != nil {
+		return 0, err
+	}
+
+	offsetStr, found := strings.CutPrefix(string(raw), "offset|")
+	if !found {
+		return 0, errors.New("malformed cursor")
+	}
+
+	offset, err := strconv.ParseInt(offsetStr, 10, 32)
+	if err != nil || offset < 0 {
+		return 0, errors.New("malformed cursor")
+	}
+
+	return int32(offset), nil
+}
+
+// parsePageParams reads ?limit= and ?cursor= from the request. The returned
+// cursor fields are invalid (NULL) when no cursor was given.
+func parsePageParams(r *http.Request) (int32, sql.NullTime, uuid.NullUUID, error) {
+	limit, err := parseLimit(r)
+	if err != nil {
+		return 0, sql.NullTime{}, uuid.NullUUID{}, err
+	}
+
+	cursorQuery := r.URL.Query().Get("cursor")
+	if cursorQuery == "" {
+		return limit, sql.NullTime{}, uuid.NullUUID{}, nil
+	}
+
+	cursor, err := decodeCursor(cursorQuery)
+	if err != nil {
+		return 0, sql.NullTime{}, uuid.NullUUID{}, errors.New("invalid cursor")
+	}
+
+	return limit,
+		sql.NullTime{Time: cursor.CreatedAt, Valid: true},
+		uuid.NullUUID{UUID: cursor.ID, Valid: true},
+		nil
+}
+
+// parseLimit reads ?limit= from the request, capped at maxPageSize.
+func parseLimit(r *http.Request) (int32, error) {
+	limit := defaultPageSize
+	limitQuery := r.URL.Query().Get("limit")
+	if limitQuery != "" {
+		parsed, err := strconv.Atoi(limitQuery)
+		if err != nil || parsed < 1 {
+			return 0, errors.New("invalid limit")
+		}
+		limit = min(parsed, maxPageSize)
+	}
+
+	return int32(limit), nil
+}
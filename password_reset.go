@@ -0,0 +1,236 @@
+package main
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/dennisdijkstra/go/internal/auth"
+	"github.com/dennisdijkstra/go/internal/database"
+	"github.com/dennisdijkstra/go/internal/mail"
+	"github.com/dennisdijkstra/go/internal/throttle"
+)
+
+const (
+	passwordResetLifetime = 30 * time.Minute
+	// maxPasswordResetSends bounds how many reset emails are being sent in
+	// the background at once.
+	maxPasswordResetSends = 16
+)
+
+// Reset requests are throttled per address, so nobody can flood an inbox,
+// and per client IP, so nobody can spray reset emails at many addresses.
+// Every request counts, whether or not the address has an account.
+var (
+	emailPasswordResetPolicy = throttle.Policy{
+		Threshold:   3,
+		BaseLockout: 15 * time.Minute,
+		MaxLockout:  24 * time.Hour,
+		Window:      time.Hour,
+	}
+	ipPasswordResetPolicy = throttle.Policy{
+		Threshold:   10,
+		BaseLockout: time.Minute,
+		MaxLockout:  time.Hour,
+		Window:      time.Hour,
+	}
+)
+
+type ForgotPasswordParams struct {
+	Email string `json:"email"`
+}
+
+type ResetPasswordParams struct {
+	Token    string `json:"token"`
+	Password string `json:"password"`
+}
+
+// handlerForgotPassword mails a reset link if the address belongs to an
+// account. The lookup runs after the response is sent, so neither the
+// body nor the response time tells the caller whether it does.
+func (cfg *apiConfig) handlerForgotPassword(w http.ResponseWriter, r *http.Request) {
+	decoder := json.NewDecoder(r.Body)
+	params := ForgotPasswordParams{}
+	err := decoder.Decode(&params)
+
+	if err != nil {
+		respondWithError(w, http.StatusBadRequest, "Something went wrong while decoding the request body")
+		return
+	}
+
+	email := strings.ToLower(strings.TrimSpace(params.Email))
+	if email == "" {
+		respondWithError(w, http.StatusBadRequest, "Email is required")
+		return
+	}
+
+	wait, err := cfg.reserveAttempt(r.Context(),
+		throttledKey{Key: "reset-email:" + email, Policy: emailPasswordResetPolicy},
+		throttledKey{Key: "reset-ip:" + cfg.clientIP(r), Policy: ipPasswordResetPolicy},
+	)
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Something went wrong while checking password reset limits")
+		return
+	}
+
+	if wait > 0 {
+		w.Header().Set("Retry-After", strconv.Itoa(throttle.RetryAfter(wait)))
+		respondWithError(w, http.StatusTooManyRequests, "Too many password reset requests, try again later")
+		return
+	}
+
+	select {
+	case cfg.passwordResetSends <- struct{}{}:
+	default:
+		respondWithError(w, http.StatusServiceUnavailable, "Too many password resets in progress, try again later")
+		return
+	}
+
+	go func() {
+		defer func() { <-cfg.passwordResetSends }()
+
+		ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+		defer cancel()
+
+		if err := cfg.sendPasswordReset(ctx, params.Email); err != nil {
+			log.Printf("Error sending password reset email: %s", err)
+		}
+	}()
+
+	w.WriteHeader(http.StatusAccepted)
+}
+
+func (cfg *apiConfig) handlerResetPassword(w http.ResponseWriter, r *http.Request) {
+	decoder := json.NewDecoder(r.Body)
+	params := ResetPasswordParams{}
+	err := decoder.Decode(&params)
+
+	if err != nil {
+		respondWithError(w, http.StatusBadRequest, "Something went wrong while decoding the request body")
+		return
+	}
+
+	if params.Password == "" {
+		respondWithError(w, http.StatusBadRequest, "Password is required")
+		return
+	}
+
+	hashedPassword, err := auth.HashPassword(params.Password)
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Something went wrong while hashing the password")
+		return
+	}
+
+	tx, err := cfg.dbConn.BeginTx(r.Context(), nil)
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Something went wrong while resetting the password")
+		return
+	}
+	defer tx.Rollback()
+	qtx := cfg.db.WithTx(tx)
+
+	userID, err := qtx.UsePasswordResetToken(r.Context(), auth.HashOneTimeToken(params.Token))
+	if err != nil {
+		if err == sql.ErrNoRows {
+			respondWithError(w, http.StatusBadRequest, "Invalid or expired reset token")
+			return
+		}
+		respondWithError(w, http.StatusInternalServerError, "Something went wrong while resetting the password")
+		return
+	}
+
+	err = qtx.UpdateUserPassword(r.Context(), database.UpdateUserPasswordParams{
+		ID:             userID,
+		HashedPassword: hashedPassword,
+	})
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Something went wrong while resetting the password")
+		return
+	}
+
+	// Whoever had the old password may still hold tokens issued with it.
+	err = qtx.RevokeUserRefreshTokens(r.Context(), userID)
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Something went wrong while revoking sessions")
+		return
+	}
+
+	err = invalidateAccessTokens(r.Context(), qtx, userID)
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Something went wrong while revoking access tokens")
+		return
+	}
+
+	err = qtx.DeleteUnusedPasswordResetTokens(r.Context(), userID)
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Something went wrong while resetting the password")
+		return
+	}
+
+	if err := tx.Commit(); err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Something went wrong while resetting the password")
+		return
+	}
+	cfg.tokenRevocations.Invalidate(userID)
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// sendPasswordReset replaces any unused reset tokens for the account with
+// email and mails a new one. Unknown addresses are silently ignored.
+func (cfg *apiConfig) sendPasswordReset(ctx context.Context, email string) error {
+	user, err := cfg.db.GetUserByEmail(ctx, email)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return nil
+		}
+		return err
+	}
+
+	token, err := auth.MakeOneTimeToken()
+	if err != nil {
+		return err
+	}
+
+	tx, err := cfg.dbConn.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+	qtx := cfg.db.WithTx(tx)
+
+	err = qtx.DeleteUnusedPasswordResetTokens(ctx, user.ID)
+	if err != nil {
+		return err
+	}
+
+	err = qtx.CreatePasswordResetToken(ctx, database.CreatePasswordResetTokenParams{
+		TokenHash: auth.HashOneTimeToken(token),
+		UserID:    user.ID,
+		ExpiresAt: time.Now().UTC().Add(passwordResetLifetime),
+	})
+	if err != nil {
+		return err
+	}
+
+	if err := tx.Commit(); err != nil {
+		return err
+	}
+
+	link := cfg.appURL + "/reset-password?token=" + url.QueryEscape(token)
+	return cfg.mailer.Send(ctx, mail.Message{
+		To:      user.Email,
+		Subject: "Reset your Chirpy password",
+		Body: fmt.Sprintf(
+			"Someone asked to reset the password for your Chirpy account.\n\nChoose a new password by opening this link within %d minutes:\n\n%s\n\nIf this was not you, you can ignore this email; your password has not changed.\n",
+			int(passwordResetLifetime.Minutes()), link,
+		),
+	})
+}
@@ -0,0 +1,111 @@
+package main
+
+import (
+	"database/sql/driver"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func newPasswordResetTestConfig(t *testing.T, sends int) (*apiConfig, *fakeDB) {
+	t.Helper()
+
+	f := newFakeDB(t)
+	stubLoginThrottles(f)
+	f.stub("GetUserByEmail", func([]driver.Value) (fakeResult, error) {
+		return fakeResult{}, nil
+	})
+
+	cfg := &apiConfig{passwordResetSends: make(chan struct{}, sends)}
+	cfg.dbConn, cfg.db = f.open()
+
+	// Wait for the background sends to finish before the database closes.
+	t.Cleanup(func() {
+		for range sends {
+			cfg.passwordResetSends <- struct{}{}
+		}
+	})
+
+	return cfg, f
+}
+
+func forgotPassword(cfg *apiConfig, email, ip string) *httptest.ResponseRecorder {
+	req := httptest.NewRequest(http.MethodPost, "/api/password/forgot", strings.NewReader(`{"email":"`+email+`"}`))
+	req.RemoteAddr = ip + ":1234"
+	rec := httptest.NewRecorder()
+	cfg.handlerForgotPassword(rec, req)
+	return rec
+}
+
+func TestForgotPasswordThrottlesEmail(t *testing.T) {
+	cfg, _ := newPasswordResetTestConfig(t, maxPasswordResetSends)
+
+	// Spelling the address differently does not get around the limit.
+	emails := []string{"user@example.com", "USER@example.com", " user@Example.com "}
+	for i, email := range emails {
+		if rec := forgotPassword(cfg, email, fmt.Sprintf("10.0.0.%d", i+1)); rec.Code != http.StatusAccepted {
+			t.Fatalf("request %d: expected 202, got %d", i+1, rec.Code)
+		}
+	}
+
+	rec := forgotPassword(cfg, "user@example.com", "10.0.0.9")
+	if rec.Code != http.StatusTooManyRequests {
+		t.Fatalf("expected 429, got %d", rec.Code)
+	}
+	if rec.Header().Get("Retry-After") == "" {
+		t.Error("expected a Retry-After header")
+	}
+
+	if rec := forgotPassword(cfg, "other@example.com", "10.0.0.9"); rec.Code != http.StatusAccepted {
+		t.Errorf("expected other addresses to be unaffected, got %d", rec.Code)
+	}
+}
+
+func TestForgotPasswordThrottlesIP(t *testing.T) {
+	cfg, _ := newPasswordResetTestConfig(t, maxPasswordResetSends)
+
+	for i := range ipPasswordResetPolicy.Threshold {
+		email := fmt.Sprintf("user%d@example.com", i)
+		if rec := forgotPassword(cfg, email, "10.0.0.1"); rec.Code != http.StatusAccepted {
+			t.Fatalf("request %d: expected 202, got %d", i+1, rec.Code)
+		}
+	}
+
+	if rec := forgotPassword(cfg, "another@example.com", "10.0.0.1"); rec.Code != http.StatusTooManyRequests {
+		t.Fatalf("expected 429, got %d", rec.Code)
+	}
+}
+
+func TestForgotPasswordBoundsSends(t *testing.T) {
+	cfg, f := newPasswordResetTestConfig(t, 1)
+
+	cfg.passwordResetSends <- struct{}{}
+	if rec := forgotPassword(cfg, "user@example.com", "10.0.0.1"); rec.Code != http.StatusServiceUnavailable {
+		t.Fatalf("expected 503 while every send slot is taken, got %d", rec.Code)
+	}
+	<-cfg.passwordResetSends
+
+	if rec := forgotPassword(cfg, "user@example.com", "10.0.0.1"); rec.Code != http.StatusAccepted {
+		t.Fatalf("expected 202 once a slot is free, got %d", rec.Code)
+	}
+
+	// The background send holds the slot until it is done.
+	cfg.passwordResetSends <- struct{}{}
+	if n := f.called("GetUserByEmail"); n != 1 {
+		t.Errorf("expected one lookup, got %d", n)
+	}
+	<-cfg.passwordResetSends
+}
+
+func TestForgotPasswordRequiresEmail(t *testing.T) {
+	cfg, f := newPasswordResetTestConfig(t, 1)
+
+	if rec := forgotPassword(cfg, "  ", "10.0.0.1"); rec.Code != http.StatusBadRequest {
+		t.Fatalf("expected 400, got %d", rec.Code)
+	}
+	if n := f.called("RecordLoginFailure"); n != 0 {
+		t.Errorf("expected nothing to be counted, got %d", n)
+	}
+}
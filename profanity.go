@@ -0,0 +1,122 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"log"
+	"net/http"
+	"time"
+
+	"github.com/dennisdijkstra/go/internal/database"
+	"github.com/dennisdijkstra/go/internal/profanity"
+	"github.com/google/uuid"
+)
+
+type ProfaneWordParams struct {
+	Word string `json:"word"`
+}
+
+type ChirpFlag struct {
+	ID           uuid.UUID `json:"id"`
+	CreatedAt    time.Time `json:"created_at"`
+	ChirpID      uuid.UUID `json:"chirp_id"`
+	MatchedWords []string  `json:"matched_words"`
+}
+
+func (cfg *apiConfig) handlerListProfaneWords(w http.ResponseWriter, r *http.Request) {
+	words, err := cfg.db.ListProfaneWords(r.Context())
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Something went wrong while fetching the profanity list")
+		return
+	}
+
+	if words == nil {
+		words = []string{}
+	}
+
+	respondWithJSON(w, http.StatusOK, words)
+}
+
+func (cfg *apiConfig) handlerCreateProfaneWord(w http.ResponseWriter, r *http.Request) {
+	decoder := json.NewDecoder(r.Body)
+	params := ProfaneWordParams{}
+	err := decoder.Decode(&params)
+
+	if err != nil {
+		respondWithError(w, http.StatusBadRequest, "Something went wrong while decoding the request body")
+		return
+	}
+
+	word := profanity.Normalize(params.Word)
+	if word == "" {
+		respondWithError(w, http.StatusBadRequest, "Word is required")
+		return
+	}
+
+	err = cfg.db.CreateProfaneWord(r.Context(), word)
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Something went wrong while adding the word")
+		return
+	}
+	cfg.profanityFilter.Invalidate()
+
+	respondWithJSON(w, http.StatusCreated, ProfaneWordParams{Word: word})
+}
+
+func (cfg *apiConfig) handlerDeleteProfaneWord(w http.ResponseWriter, r *http.Request) {
+	word := profanity.Normalize(r.PathValue("word"))
+	if word == "" {
+		respondWithError(w, http.StatusBadRequest, "Word is required")
+		return
+	}
+
+	deleted, err := cfg.db.DeleteProfaneWord(r.Context(), word)
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Something went wrong while removing the word")
+		return
+	}
+
+	if deleted == 0 {
+		respondWithError(w, http.StatusNotFound, "Word not found")
+		return
+	}
+	cfg.profanityFilter.Invalidate()
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func (cfg *apiConfig) handlerGetChirpFlags(w http.ResponseWriter, r *http.Request) {
+	flags, err := cfg.db.GetChirpFlags(r.Context())
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Something went wrong while fetching flagged chirps")
+		return
+	}
+
+	response := make([]ChirpFlag, 0, len(flags))
+	for _, flag := range flags {
+		response = append(response, ChirpFlag{
+			ID:           flag.ID,
+			CreatedAt:    flag.CreatedAt,
+			ChirpID:      flag.ChirpID,
+			MatchedWords: flag.MatchedWords,
+		})
+	}
+
+	respondWithJSON(w, http.StatusOK, response)
+}
+
+// flagChirp records a chirp for moderation. The chirp itself is already
+// stored, so a failure here is logged rather than returned to the author.
+func (cfg *apiConfig) flagChirp(ctx context.Context, chirpID uuid.UUID, words []string) {
+	if len(words) == 0 {
+		return
+	}
+
+	_, err := cfg.db.CreateChirpFlag(ctx, database.CreateChirpFlagParams{
+		ChirpID:      chirpID,
+		MatchedWords: words,
+	})
+	if err != nil {
+		log.Printf("Error flagging chirp %s: %s", chirpID, err)
+	}
+}
@@ -0,0 +1,148 @@
+package main
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"net/http"
+
+	"github.com/dennisdijkstra/go/internal/database"
+	"github.com/google/uuid"
+)
+
+var reactionTypes = map[string]bool{
+	"like":  true,
+	"love":  true,
+	"laugh": true,
+	"wow":   true,
+	"sad":   true,
+	"angry": true,
+}
+
+type ReactionParams struct {
+	Type string `json:"type"`
+}
+
+func (cfg *apiConfig) handlerCreateChirpReaction(w http.ResponseWriter, r *http.Request) {
+	userID, chirpID, reaction, code, msg, ok := cfg.parseReactionRequest(r)
+	if !ok {
+		respondWithError(w, code, msg)
+		return
+	}
+
+	err := cfg.db.CreateChirpReaction(r.Context(), database.CreateChirpReactionParams{
+		UserID:   userID,
+		ChirpID:  chirpID,
+		Reaction: reaction,
+	})
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Something went wrong while adding the reaction")
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func (cfg *apiConfig) handlerDeleteChirpReaction(w http.ResponseWriter, r *http.Request) {
+	userID, chirpID, reaction, code, msg, ok := cfg.parseReactionRequest(r)
+	if !ok {
+		respondWithError(w, code, msg)
+		return
+	}
+
+	deleted, err := cfg.db.DeleteChirpReaction(r.Context(), database.DeleteChirpReactionParams{
+		UserID:   userID,
+		ChirpID:  chirpID,
+		Reaction: reaction,
+	})
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Something went wrong while removing the reaction")
+		return
+	}
+
+	if deleted == 0 {
+		respondWithError(w, http.StatusNotFound, "Reaction not found")
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// parseReactionRequest authenticates the caller, checks the chirp exists and
+// reads the reaction type from the body. An empty type means "like".
+func (cfg *apiConfig) parseReactionRequest(r *http.Request) (uuid.UUID, uuid.UUID, string, int, string, bool) {
+	userID, code, msg, ok := cfg.requireJWTUserID(r)
+	if !ok {
+		return uuid.Nil, uuid.Nil, "", code, msg, false
+	}
+
+	chirpUUID, err := uuid.Parse(r.PathValue("chirpID"))
+	if err != nil {
+		return uuid.Nil, uuid.Nil, "", http.StatusBadRequest, "Invalid chirp ID", false
+	}
+
+	params := ReactionParams{}
+	if r.ContentLength != 0 {
+		err = json.NewDecoder(r.Body).Decode(&params)
+		if err != nil {
+			return uuid.Nil, uuid.Nil, "", http.StatusBadRequest, "Something went wrong while decoding the request body", false
+		}
+	}
+
+	if params.Type == "" {
+		params.Type = "like"
+	}
+
+	if !reactionTypes[params.Type] {
+		return uuid.Nil, uuid.Nil, "", http.StatusBadRequest, "Invalid reaction type", false
+	}
+
+	_, err = cfg.db.GetChirpByID(r.Context(), chirpUUID)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return uuid.Nil, uuid.Nil, "", http.StatusNotFound, "Chirp not found", false
+		}
+		return uuid.Nil, uuid.Nil, "", http.StatusInternalServerError, "Something went wrong while fetching the chirp", false
+	}
+
+	return userID, chirpUUID, params.Type, 0, "", true
+}
+
+// attachReactions fills in reaction counts for every chirp in groups with a
+// single query. Chirps are updated in place.
+func (cfg *apiConfig) attachReactions(ctx context.Context, viewerID uuid.NullUUID, groups ...[]Chirp) error {
+	var chirpIDs []uuid.UUID
+	for _, chirps := range groups {
+		for _, chirp := range chirps {
+			chirpIDs = append(chirpIDs, chirp.ID)
+		}
+	}
+
+	if len(chirpIDs) == 0 {
+		return nil
+	}
+
+	summaries, err := cfg.db.GetReactionSummaries(ctx, database.GetReactionSummariesParams{
+		ViewerID: viewerID,
+		ChirpIds: chirpIDs,
+	})
+	if err != nil {
+		return err
+	}
+
+	byChirp := make(map[uuid.UUID][]database.GetReactionSummariesRow, len(summaries))
+	for _, summary := range summaries {
+		byChirp[summary.ChirpID] = append(byChirp[summary.ChirpID], summary)
+	}
+
+	for _, chirps := range groups {
+		for i := range chirps {
+			for _, summary := range byChirp[chirps[i].ID] {
+				chirps[i].Reactions[summary.Reaction] = summary.Count
+				chirps[i].ViewerReacted = chirps[i].ViewerReacted || summary.ViewerReacted
+			}
+		}
+	}
+
+	return nil
+}
@@ -1,16 +1,27 @@
 package main
 
 import (
+	"context"
+	"database/sql"
+	"log"
 	"net/http"
 	"time"
 
 	"github.com/dennisdijkstra/go/internal/auth"
+	"github.com/dennisdijkstra/go/internal/database"
+	"github.com/google/uuid"
 )
 
+const refreshTokenLifetime = 60 * 24 * time.Hour
+
 type RefreshToken struct {
-	Token string `json:"token"`
+	Token        string `json:"token"`
+	RefreshToken string `json:"refresh_token"`
 }
 
+// handlerRefresh exchanges a refresh token for a new access token and a new
+// refresh token. The presented token is rotated out; presenting it again
+// means it leaked, so its whole family is revoked.
 func (cfg *apiConfig) handlerRefresh(w http.ResponseWriter, r *http.Request) {
 	bearerToken, err := auth.GetBearerToken(r.Header)
 	if err != nil {
@@ -18,20 +29,87 @@ func (cfg *apiConfig) handlerRefresh(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	user, err := cfg.db.GetUserFromRefreshToken(r.Context(), bearerToken)
+	refreshToken, err := cfg.db.GetRefreshToken(r.Context(), auth.HashRefreshToken(bearerToken))
+	if err != nil {
+		if err == sql.ErrNoRows {
+			respondWithError(w, http.StatusUnauthorized, "Invalid refresh token")
+			return
+		}
+		respondWithError(w, http.StatusInternalServerError, "Something went wrong while fetching the refresh token")
+		return
+	}
+
+	if refreshToken.RotatedAt.Valid {
+		cfg.revokeReusedRefreshToken(r.Context(), refreshToken)
+		respondWithError(w, http.StatusUnauthorized, "Invalid refresh token")
+		return
+	}
+
+	if refreshToken.RevokedAt.Valid || time.Now().After(refreshToken.ExpiresAt) {
+		respondWithError(w, http.StatusUnauthorized, "Invalid refresh token")
+		return
+	}
+
+	tx, err := cfg.dbConn.BeginTx(r.Context(), nil)
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Something went wrong while rotating the refresh token")
+		return
+	}
+	defer tx.Rollback()
+	qtx := cfg.db.WithTx(tx)
+
+	rotated, err := qtx.RotateRefreshToken(r.Context(), refreshToken.TokenHash)
 	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Something went wrong while rotating the refresh token")
+		return
+	}
+
+	// Another request rotated or revoked the token since we read it, which
+	// is the same as presenting it twice.
+	if rotated == 0 {
+		tx.Rollback()
+		cfg.revokeReusedRefreshToken(r.Context(), refreshToken)
 		respondWithError(w, http.StatusUnauthorized, "Invalid refresh token")
 		return
 	}
 
-	accessToken, err := auth.MakeJWT(user.ID, cfg.jwtSecret, time.Hour)
+	err = qtx.TouchSession(r.Context(), database.TouchSessionParams{
+		ID:        refreshToken.FamilyID,
+		UserAgent: userAgent(r),
+		IpAddress: cfg.clientIP(r),
+	})
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Something went wrong while updating the session")
+		return
+	}
+
+	newRefreshToken, err := issueRefreshToken(r.Context(), qtx, refreshToken.UserID, refreshToken.FamilyID)
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Something went wrong while creating the refresh token")
+		return
+	}
+
+	if err := tx.Commit(); err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Something went wrong while rotating the refresh token")
+		return
+	}
+
+	// The role is read fresh so a changed role takes effect on refresh.
+	user, err := cfg.db.GetUserByID(r.Context(), refreshToken.UserID)
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Something went wrong while fetching the user")
+		return
+	}
+
+	accessToken, err := auth.MakeJWT(user.ID, user.Role, cfg.signingKeys, accessTokenLifetime)
 	if err != nil {
 		respondWithError(w, http.StatusInternalServerError, "Something went wrong while creating the access token")
 		return
 	}
 
 	body := RefreshToken{
-		Token: accessToken,
+		Token:        accessToken,
+		RefreshToken: newRefreshToken,
 	}
 
 	respondWithJSON(w, http.StatusOK, body)
@@ -43,7 +121,7 @@ func (cfg *apiConfig) handlerRevoke(w http.ResponseWriter, r *http.Request) {
 		respondWithError(w, http.StatusUnauthorized, "Something went wrong while parsing the bearer token")
 		return
 	}
-	err = cfg.db.RevokeRefreshToken(r.Context(), bearerToken)
+	err = cfg.db.RevokeRefreshToken(r.Context(), auth.HashRefreshToken(bearerToken))
 	if err != nil {
 		respondWithError(w, http.StatusInternalServerError, "Something went wrong while revoking the refresh token")
 		return
@@ -51,3 +129,34 @@ func (cfg *apiConfig) handlerRevoke(w http.ResponseWriter, r *http.Request) {
 
 	w.WriteHeader(http.StatusNoContent)
 }
+
+// issueRefreshToken creates a refresh token in familyID, which is the ID of
+// the session the token belongs to. Only the token's hash is stored; the
+// returned plaintext is shown to the client once.
+func issueRefreshToken(ctx context.Context, q *database.Queries, userID, familyID uuid.UUID) (string, error) {
+	token, err := auth.MakeRefreshToken()
+	if err != nil {
+		return "", err
+	}
+
+	_, err = q.CreateRefreshToken(ctx, database.CreateRefreshTokenParams{
+		TokenHash: auth.HashRefreshToken(token),
+		UserID:    userID,
+		ExpiresAt: time.Now().Add(refreshTokenLifetime),
+		FamilyID:  familyID,
+	})
+	if err != nil {
+		return "", err
+	}
+
+	return token, nil
+}
+
+func (cfg *apiConfig) revokeReusedRefreshToken(ctx context.Context, refreshToken database.RefreshToken) {
+	log.Printf("Refresh token reuse detected for user %s, revoking token family %s", refreshToken.UserID, refreshToken.FamilyID)
+
+	err := cfg.db.RevokeRefreshTokenFamily(ctx, refreshToken.FamilyID)
+	if err != nil {
+		log.Printf("Error revoking token family %s: %s", refreshToken.FamilyID, err)
+	}
+}
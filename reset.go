@@ -10,7 +10,7 @@ func (cfg *apiConfig) handlerResetAll(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	cfg.fileserverHits.Store(0)
+	cfg.metrics.resetFileserverHits()
 	err := cfg.db.DeleteUsers(r.Context())
 	if err != nil {
 		respondWithError(w, http.StatusInternalServerError, "Something went wrong while resetting the database")
@@ -0,0 +1,82 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+
+	"github.com/dennisdijkstra/go/internal/auth"
+	"github.com/dennisdijkstra/go/internal/database"
+)
+
+type RoleParams struct {
+	Role string `json:"role"`
+}
+
+// handlerUpdateUserRole changes a user's role. The user's outstanding access
+// tokens still carry the old role, so they are invalidated; the next refresh
+// picks up the new one.
+func (cfg *apiConfig) handlerUpdateUserRole(w http.ResponseWriter, r *http.Request) {
+	adminID, code, msg, ok := cfg.requireJWTUserID(r)
+	if !ok {
+		respondWithError(w, code, msg)
+		return
+	}
+
+	user, code, msg, ok := cfg.requirePathUser(r)
+	if !ok {
+		respondWithError(w, code, msg)
+		return
+	}
+
+	// Demoting yourself could leave nobody able to manage roles.
+	if user.ID == adminID {
+		respondWithError(w, http.StatusBadRequest, "You cannot change your own role")
+		return
+	}
+
+	decoder := json.NewDecoder(r.Body)
+	params := RoleParams{}
+	err := decoder.Decode(&params)
+
+	if err != nil {
+		respondWithError(w, http.StatusBadRequest, "Something went wrong while decoding the request body")
+		return
+	}
+
+	role, ok := auth.ParseRole(params.Role)
+	if !ok {
+		respondWithError(w, http.StatusBadRequest, "Role must be one of user, moderator or admin")
+		return
+	}
+
+	tx, err := cfg.dbConn.BeginTx(r.Context(), nil)
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Something went wrong while updating the role")
+		return
+	}
+	defer tx.Rollback()
+	qtx := cfg.db.WithTx(tx)
+
+	updated, err := qtx.UpdateUserRole(r.Context(), database.UpdateUserRoleParams{
+		ID:   user.ID,
+		Role: role,
+	})
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Something went wrong while updating the role")
+		return
+	}
+
+	err = invalidateAccessTokens(r.Context(), qtx, user.ID)
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Something went wrong while revoking access tokens")
+		return
+	}
+
+	if err := tx.Commit(); err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Something went wrong while updating the role")
+		return
+	}
+	cfg.tokenRevocations.Invalidate(user.ID)
+
+	respondWithJSON(w, http.StatusOK, userFromDB(updated))
+}
@@ -0,0 +1,109 @@
+package main
+
+import (
+	"html"
+	"net/http"
+	"strings"
+
+	"github.com/dennisdijkstra/go/internal/database"
+	"github.com/dennisdijkstra/go/internal/search"
+)
+
+// Postgres marks matches with these control characters. The body is HTML
+// escaped before they are swapped for <mark> tags, so user text can never
+// inject markup into the highlight.
+const (
+	highlightStart = "\x02"
+	highlightStop  = "\x03"
+)
+
+type SearchResult struct {
+	Chirp
+	Rank      float32 `json:"rank"`
+	Highlight string  `json:"highlight"`
+}
+
+type SearchPage struct {
+	Results    []SearchResult `json:"results"`
+	NextCursor string         `json:"next_cursor"`
+}
+
+func (cfg *apiConfig) handlerSearchChirps(w http.ResponseWriter, r *http.Request) {
+	query, err := search.ParseQuery(r.URL.Query().Get("q"))
+	if err != nil {
+		respondWithError(w, http.StatusBadRequest, "Search query is required")
+		return
+	}
+
+	limit, err := parseLimit(r)
+	if err != nil {
+		respondWithError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	var offset int32
+	if cursor := r.URL.Query().Get("cursor"); cursor != "" {
+		offset, err = decodeOffsetCursor(cursor)
+		if err != nil {
+			respondWithError(w, http.StatusBadRequest, "Invalid cursor")
+			return
+		}
+	}
+
+	rows, err := cfg.db.SearchChirps(r.Context(), database.SearchChirpsParams{
+		StartSel:   highlightStart,
+		StopSel:    highlightStop,
+		Query:      query,
+		PageSize:   limit + 1,
+		PageOffset: offset,
+	})
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Something went wrong while searching chirps")
+		return
+	}
+
+	nextCursor := ""
+	if len(rows) > int(limit) {
+		rows = rows[:limit]
+		nextCursor = encodeOffsetCursor(offset + limit)
+	}
+
+	chirps := make([]Chirp, 0, len(rows))
+	for _, row := range rows {
+		chirps = append(chirps, chirpFromDB(database.Chirp{
+			ID:        row.ID,
+			CreatedAt: row.CreatedAt,
+			UpdatedAt: row.UpdatedAt,
+			Body:      row.Body,
+			UserID:    row.UserID,
+			ReplyTo:   row.ReplyTo,
+			DeletedAt: row.DeletedAt,
+		}))
+	}
+
+	err = cfg.decorateChirps(r.Context(), cfg.optionalJWTUserID(r), chirps)
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Something went wrong while fetching chirp details")
+		return
+	}
+
+	results := make([]SearchResult, 0, len(rows))
+	for i, row := range rows {
+		results = append(results, SearchResult{
+			Chirp:     chirps[i],
+			Rank:      row.Rank,
+			Highlight: renderHighlight(row.Headline),
+		})
+	}
+
+	respondWithJSON(w, http.StatusOK, SearchPage{
+		Results:    results,
+		NextCursor: nextCursor,
+	})
+}
+
+func renderHighlight(headline string) string {
+	escaped := html.EscapeString(headline)
+	escaped = strings.ReplaceAll(escaped, highlightStart, "<mark>")
+	return strings.ReplaceAll(escaped, highlightStop, "</mark>")
+}
@@ -0,0 +1,154 @@
+package main
+
+import (
+	"database/sql"
+	"net"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+const maxUserAgentLength = 512
+
+type Session struct {
+	ID         uuid.UUID `json:"id"`
+	CreatedAt  time.Time `json:"created_at"`
+	LastUsedAt time.Time `json:"last_used_at"`
+	UserAgent  string    `json:"user_agent"`
+	IPAddress  string    `json:"ip_address"`
+}
+
+func (cfg *apiConfig) handlerGetSessions(w http.ResponseWriter, r *http.Request) {
+	userID, code, msg, ok := cfg.requireJWTUserID(r)
+	if !ok {
+		respondWithError(w, code, msg)
+		return
+	}
+
+	sessions, err := cfg.db.GetActiveSessions(r.Context(), userID)
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Something went wrong while fetching sessions")
+		return
+	}
+
+	response := make([]Session, 0, len(sessions))
+	for _, session := range sessions {
+		response = append(response, Session{
+			ID:         session.ID,
+			CreatedAt:  session.CreatedAt,
+			LastUsedAt: session.LastUsedAt,
+			UserAgent:  session.UserAgent,
+			IPAddress:  session.IpAddress,
+		})
+	}
+
+	respondWithJSON(w, http.StatusOK, response)
+}
+
+func (cfg *apiConfig) handlerDeleteSession(w http.ResponseWriter, r *http.Request) {
+	userID, code, msg, ok := cfg.requireJWTUserID(r)
+	if !ok {
+		respondWithError(w, code, msg)
+		return
+	}
+
+	sessionID, err := uuid.Parse(r.PathValue("sessionID"))
+	if err != nil {
+		respondWithError(w, http.StatusBadRequest, "Invalid session ID")
+		return
+	}
+
+	session, err := cfg.db.GetSessionByID(r.Context(), sessionID)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			respondWithError(w, http.StatusNotFound, "Session not found")
+			return
+		}
+		respondWithError(w, http.StatusInternalServerError, "Something went wrong while fetching the session")
+		return
+	}
+
+	// Someone else's session is reported as missing rather than forbidden
+	// so session IDs cannot be probed.
+	if session.UserID != userID {
+		respondWithError(w, http.StatusNotFound, "Session not found")
+		return
+	}
+
+	err = cfg.db.RevokeRefreshTokenFamily(r.Context(), session.ID)
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Something went wrong while revoking the session")
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// handlerDeleteAllSessions logs the user out everywhere by revoking every
+// refresh token they hold and every access token issued so far.
+func (cfg *apiConfig) handlerDeleteAllSessions(w http.ResponseWriter, r *http.Request) {
+	userID, code, msg, ok := cfg.requireJWTUserID(r)
+	if !ok {
+		respondWithError(w, code, msg)
+		return
+	}
+
+	tx, err := cfg.dbConn.BeginTx(r.Context(), nil)
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Something went wrong while revoking sessions")
+		return
+	}
+	defer tx.Rollback()
+	qtx := cfg.db.WithTx(tx)
+
+	err = qtx.RevokeUserRefreshTokens(r.Context(), userID)
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Something went wrong while revoking sessions")
+		return
+	}
+
+	err = invalidateAccessTokens(r.Context(), qtx, userID)
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Something went wrong while revoking access tokens")
+		return
+	}
+
+	if err := tx.Commit(); err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Something went wrong while revoking sessions")
+		return
+	}
+	cfg.tokenRevocations.Invalidate(userID)
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// clientIP returns the caller's address. X-Forwarded-For is only honoured
+// when the server is configured to sit behind a trusted proxy, and then only
+// its rightmost entry, which that proxy appended. Entries to its left come
+// from the client and can be anything.
+func (cfg *apiConfig) clientIP(r *http.Request) string {
+	if cfg.trustProxyHeaders {
+		forwarded := strings.Join(r.Header.Values("X-Forwarded-For"), ",")
+		entries := strings.Split(forwarded, ",")
+		if last := strings.TrimSpace(entries[len(entries)-1]); last != "" {
+			return last
+		}
+	}
+
+	host, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		return r.RemoteAddr
+	}
+
+	return host
+}
+
+func userAgent(r *http.Request) string {
+	ua := r.UserAgent()
+	if len(ua) > maxUserAgentLength {
+		return ua[:maxUserAgentLength]
+	}
+	return ua
+}
@@ -0,0 +1,210 @@
+package main
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+	"time"
+
+	"github.com/dennisdijkstra/go/internal/auth"
+	"github.com/dennisdijkstra/go/internal/database"
+)
+
+const (
+	// signingKeyReloadInterval is how often every instance picks up keys
+	// created by another instance.
+	signingKeyReloadInterval = time.Minute
+	// signingKeyActivationDelay gives every instance, and every verifier
+	// caching the JWKS, time to see a new key before tokens are signed
+	// with it.
+	signingKeyActivationDelay = 10 * time.Minute
+	jwksMaxAge                = 5 * time.Minute
+)
+
+type SigningKeyParams struct {
+	Algorithm string `json:"algorithm"`
+}
+
+type SigningKey struct {
+	ID          string     `json:"kid"`
+	CreatedAt   time.Time  `json:"created_at"`
+	Algorithm   string     `json:"algorithm"`
+	ActivatesAt time.Time  `json:"activates_at"`
+	ExpiresAt   *time.Time `json:"expires_at"`
+}
+
+func (cfg *apiConfig) handlerGetJWKS(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Cache-Control", fmt.Sprintf("public, max-age=%d", int(jwksMaxAge.Seconds())))
+	respondWithJSON(w, http.StatusOK, cfg.signingKeys.JWKS())
+}
+
+func (cfg *apiConfig) handlerListSigningKeys(w http.ResponseWriter, r *http.Request) {
+	keys, err := cfg.db.GetSigningKeys(r.Context())
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Something went wrong while fetching signing keys")
+		return
+	}
+
+	response := make([]SigningKey, 0, len(keys))
+	for _, key := range keys {
+		response = append(response, signingKeyFromDB(key))
+	}
+
+	respondWithJSON(w, http.StatusOK, response)
+}
+
+// handlerRotateSigningKey creates a new signing key that takes over once
+// signingKeyActivationDelay has passed. Current keys keep verifying tokens
+// until every token they could have signed has expired.
+func (cfg *apiConfig) handlerRotateSigningKey(w http.ResponseWriter, r *http.Request) {
+	params := SigningKeyParams{}
+	if r.ContentLength != 0 {
+		decoder := json.NewDecoder(r.Body)
+		if err := decoder.Decode(&params); err != nil {
+			respondWithError(w, http.StatusBadRequest, "Something went wrong while decoding the request body")
+			return
+		}
+	}
+
+	algorithm, ok := auth.ParseAlgorithm(params.Algorithm)
+	if !ok {
+		respondWithError(w, http.StatusBadRequest, "Algorithm must be RS256 or EdDSA")
+		return
+	}
+
+	activatesAt := time.Now().UTC().Add(signingKeyActivationDelay)
+	key, err := cfg.createSigningKey(r.Context(), algorithm, activatesAt)
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Something went wrong while rotating the signing key")
+		return
+	}
+
+	if err := cfg.loadSigningKeys(r.Context()); err != nil {
+		log.Printf("Error reloading signing keys: %s", err)
+	}
+
+	respondWithJSON(w, http.StatusCreated, signingKeyFromDB(key))
+}
+
+// createSigningKey stores a new key and schedules every other key to expire
+// one access token lifetime after it activates.
+func (cfg *apiConfig) createSigningKey(ctx context.Context, algorithm string, activatesAt time.Time) (database.SigningKey, error) {
+	key, err := auth.GenerateSigningKey(algorithm)
+	if err != nil {
+		return database.SigningKey{}, err
+	}
+
+	privateKey, err := key.SealPrivateKey(cfg.signingKeyEncryptionKey)
+	if err != nil {
+		return database.SigningKey{}, err
+	}
+
+	tx, err := cfg.dbConn.BeginTx(ctx, nil)
+	if err != nil {
+		return database.SigningKey{}, err
+	}
+	defer tx.Rollback()
+	qtx := cfg.db.WithTx(tx)
+
+	created, err := qtx.CreateSigningKey(ctx, database.CreateSigningKeyParams{
+		ID:          key.ID,
+		Algorithm:   key.Algorithm,
+		PrivateKey:  privateKey,
+		ActivatesAt: activatesAt,
+	})
+	if err != nil {
+		return database.SigningKey{}, err
+	}
+
+	err = qtx.ExpireSigningKeys(ctx, database.ExpireSigningKeysParams{
+		ID:        created.ID,
+		ExpiresAt: sql.NullTime{Time: activatesAt.Add(accessTokenLifetime), Valid: true},
+	})
+	if err != nil {
+		return database.SigningKey{}, err
+	}
+
+	return created, tx.Commit()
+}
+
+// loadSigningKeys replaces the in-memory key set with the keys in the
+// database.
+func (cfg *apiConfig) loadSigningKeys(ctx context.Context) error {
+	rows, err := cfg.db.GetSigningKeys(ctx)
+	if err != nil {
+		return err
+	}
+
+	keys := make([]auth.SigningKey, 0, len(rows))
+	for _, row := range rows {
+		privateKey, err := auth.OpenPrivateKey(cfg.signingKeyEncryptionKey, row.ID, row.Algorithm, row.PrivateKey)
+		if err != nil {
+			return fmt.Errorf("signing key %s: %w", row.ID, err)
+		}
+
+		key := auth.SigningKey{
+			ID:          row.ID,
+			Algorithm:   row.Algorithm,
+			PrivateKey:  privateKey,
+			ActivatesAt: row.ActivatesAt,
+		}
+		if row.ExpiresAt.Valid {
+			key.ExpiresAt = row.ExpiresAt.Time
+		}
+		keys = append(keys, key)
+	}
+
+	cfg.signingKeys.SetKeys(keys)
+	return nil
+}
+
+// initSigningKeys loads the key set, creating a first key that is active
+// straight away when the database has none.
+func (cfg *apiConfig) initSigningKeys(ctx context.Context, algorithm string) error {
+	rows, err := cfg.db.GetSigningKeys(ctx)
+	if err != nil {
+		return err
+	}
+
+	if len(rows) == 0 {
+		_, err := cfg.createSigningKey(ctx, algorithm, time.Now().UTC())
+		if err != nil {
+			return err
+		}
+	}
+
+	return cfg.loadSigningKeys(ctx)
+}
+
+func (cfg *apiConfig) reloadSigningKeys(ctx context.Context) {
+	ticker := time.NewTicker(signingKeyReloadInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			if err := cfg.loadSigningKeys(ctx); err != nil {
+				log.Printf("Error reloading signing keys: %s", err)
+			}
+		}
+	}
+}
+
+func signingKeyFromDB(key database.SigningKey) SigningKey {
+	response := SigningKey{
+		ID:          key.ID,
+		CreatedAt:   key.CreatedAt,
+		Algorithm:   key.Algorithm,
+		ActivatesAt: key.ActivatesAt,
+	}
+	if key.ExpiresAt.Valid {
+		response.ExpiresAt = &key.ExpiresAt.Time
+	}
+
+	return response
+}
@@ -0,0 +1,13 @@
+-- name: CreateRevokedAccessToken :exec
+INSERT INTO revoked_access_tokens (jti, created_at, user_id, expires_at)
+VALUES ($1, CURRENT_TIMESTAMP, $2, $3)
+ON CONFLICT (jti) DO NOTHING;
+
+-- name: GetRevokedAccessTokenIDs :many
+SELECT jti FROM revoked_access_tokens
+WHERE user_id = $1
+AND expires_at > NOW();
+
+-- name: DeleteExpiredRevokedAccessTokens :exec
+DELETE FROM revoked_access_tokens
+WHERE expires_at <= NOW();
\ No newline at end of file
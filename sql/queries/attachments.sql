@@ -0,0 +1,49 @@
+-- name: CreateAttachment :one
+INSERT INTO attachments (id, created_at, user_id, chirp_id, storage_key, content_type, size_bytes)
+VALUES (
+    $1,
+    CURRENT_TIMESTAMP,
+    $2,
+    NULL,
+    $3,
+    $4,
+    $5
+)
+RETURNING *;
+
+-- name: GetAttachmentByID :one
+SELECT * FROM attachments
+WHERE id = $1;
+
+-- name: AttachToChirp :execrows
+UPDATE attachments
+SET chirp_id = sqlc.arg('chirp_id')
+WHERE id = ANY(sqlc.arg('ids')::uuid[])
+AND user_id = sqlc.arg('user_id')
+AND chirp_id IS NULL;
+
+-- name: GetAttachmentsForChirps :many
+SELECT * FROM attachments
+WHERE chirp_id = ANY(sqlc.arg('chirp_ids')::uuid[])
+ORDER BY created_at ASC;
+
+-- name: DeleteChirpAttachments :many
+DELETE FROM attachments
+WHERE chirp_id = $1
+RETURNING storage_key;
+
+-- name: LockUserUploads :exec
+SELECT id FROM users
+WHERE id = $1
+FOR NO KEY UPDATE;
+
+-- name: GetPendingAttachmentUsage :one
+SELECT COUNT(*) AS count, COALESCE(SUM(size_bytes), 0)::bigint AS size_bytes FROM attachments
+WHERE user_id = $1
+AND chirp_id IS NULL;
+
+-- name: DeleteStaleAttachments :many
+DELETE FROM attachments
+WHERE chirp_id IS NULL
+AND created_at < $1
+RETURNING storage_key;
\ No newline at end of file
@@ -0,0 +1,51 @@
+-- name: CreateChirpHashtags :exec
+INSERT INTO chirp_hashtags (chirp_id, tag, created_at)
+SELECT chirps.id, unnest(sqlc.arg('tags')::text[]), chirps.created_at FROM chirps
+WHERE chirps.id = sqlc.arg('chirp_id')
+ON CONFLICT (chirp_id, tag) DO NOTHING;
+
+-- name: DeleteChirpHashtags :exec
+DELETE FROM chirp_hashtags
+WHERE chirp_id = $1;
+
+-- name: CreateChirpMentions :exec
+INSERT INTO chirp_mentions (chirp_id, user_id, created_at)
+SELECT chirps.id, users.id, chirps.created_at FROM chirps, users
+WHERE chirps.id = sqlc.arg('chirp_id')
+AND LOWER(users.email) = ANY(sqlc.arg('emails')::text[])
+ON CONFLICT (chirp_id, user_id) DO NOTHING;
+
+-- name: DeleteChirpMentions :exec
+DELETE FROM chirp_mentions
+WHERE chirp_id = $1;
+
+-- name: GetHashtagChirpsPage :many
+SELECT chirps.* FROM chirps
+JOIN chirp_hashtags ON chirp_hashtags.chirp_id = chirps.id
+WHERE chirp_hashtags.tag = sqlc.arg('tag')
+AND chirps.deleted_at IS NULL
+AND (
+    sqlc.narg('cursor_created_at')::timestamptz IS NULL
+    OR (chirps.created_at, chirps.id) < (sqlc.narg('cursor_created_at')::timestamptz, sqlc.narg('cursor_id')::uuid)
+)
+ORDER BY chirps.created_at DESC, chirps.id DESC
+LIMIT sqlc.arg('page_size');
+
+-- name: GetMentionChirpsPage :many
+SELECT chirps.* FROM chirps
+JOIN chirp_mentions ON chirp_mentions.chirp_id = chirps.id
+WHERE chirp_mentions.user_id = sqlc.arg('user_id')
+AND chirps.deleted_at IS NULL
+AND (
+    sqlc.narg('cursor_created_at')::timestamptz IS NULL
+    OR (chirps.created_at, chirps.id) < (sqlc.narg('cursor_created_at')::timestamptz, sqlc.narg('cursor_id')::uuid)
+)
+ORDER BY chirps.created_at DESC, chirps.id DESC
+LIMIT sqlc.arg('page_size');
+
+-- name: GetTrendingHashtags :many
+SELECT tag, COUNT(*) AS uses FROM chirp_hashtags
+WHERE created_at > sqlc.arg('since')
+GROUP BY tag
+ORDER BY uses DESC, tag ASC
+LIMIT sqlc.arg('page_size');
\ No newline at end of file
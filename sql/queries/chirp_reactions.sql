@@ -0,0 +1,20 @@
+-- name: CreateChirpReaction :exec
+INSERT INTO chirp_reactions (user_id, chirp_id, reaction, created_at)
+VALUES ($1, $2, $3, CURRENT_TIMESTAMP)
+ON CONFLICT (user_id, chirp_id, reaction) DO NOTHING;
+
+-- name: DeleteChirpReaction :execrows
+DELETE FROM chirp_reactions
+WHERE user_id = $1
+AND chirp_id = $2
+AND reaction = $3;
+
+-- name: GetReactionSummaries :many
+SELECT
+    chirp_id,
+    reaction,
+    COUNT(*) AS count,
+    COALESCE(BOOL_OR(user_id = sqlc.narg('viewer_id')::uuid), false)::boolean AS viewer_reacted
+FROM chirp_reactions
+WHERE chirp_id = ANY(sqlc.arg('chirp_ids')::uuid[])
+GROUP BY chirp_id, reaction;
\ No newline at end of file
@@ -1,27 +1,106 @@
 -- name: CreateChirp :one
-INSERT INTO chirps (id, created_at, updated_at, body, user_id)
+INSERT INTO chirps (id, created_at, updated_at, body, user_id, reply_to)
 VALUES (
     gen_random_uuid(),
     CURRENT_TIMESTAMP,
     CURRENT_TIMESTAMP,
     $1,
-    $2
+    $2,
+    $3
 )
 RETURNING *;
 
--- name: GetChirps :many
+-- name: GetChirpsPageAsc :many
 SELECT * FROM chirps
-ORDER BY created_at ASC;
+WHERE deleted_at IS NULL
+AND (sqlc.narg('author_id')::uuid IS NULL OR user_id = sqlc.narg('author_id')::uuid)
+AND (
+    sqlc.narg('cursor_created_at')::timestamptz IS NULL
+    OR (created_at, id) > (sqlc.narg('cursor_created_at')::timestamptz, sqlc.narg('cursor_id')::uuid)
+)
+ORDER BY created_at ASC, id ASC
+LIMIT sqlc.arg('page_size');
 
--- name: GetChirpsByAuthorID :many
+-- name: GetChirpsPageDesc :many
 SELECT * FROM chirps
-WHERE user_id = $1
-ORDER BY created_at ASC;
+WHERE deleted_at IS NULL
+AND (sqlc.narg('author_id')::uuid IS NULL OR user_id = sqlc.narg('author_id')::uuid)
+AND (
+    sqlc.narg('cursor_created_at')::timestamptz IS NULL
+    OR (created_at, id) < (sqlc.narg('cursor_created_at')::timestamptz, sqlc.narg('cursor_id')::uuid)
+)
+ORDER BY created_at DESC, id DESC
+LIMIT sqlc.arg('page_size');
 
 -- name: GetChirpByID :one
 SELECT * FROM chirps
+WHERE id = $1
+AND deleted_at IS NULL;
+
+-- name: GetChirpIncludingDeleted :one
+SELECT * FROM chirps
 WHERE id = $1;
 
+-- name: LockChirp :one
+SELECT * FROM chirps
+WHERE id = $1
+AND deleted_at IS NULL
+FOR UPDATE;
+
 -- name: DeleteChirpByID :exec
 DELETE FROM chirps
-WHERE id = $1;
\ No newline at end of file
+WHERE id = $1;
+
+-- name: UpdateChirpBody :one
+UPDATE chirps
+SET body = $2, updated_at = CURRENT_TIMESTAMP
+WHERE id = $1
+RETURNING *;
+
+-- name: TombstoneChirp :exec
+UPDATE chirps
+SET body = '', deleted_at = CURRENT_TIMESTAMP, updated_at = CURRENT_TIMESTAMP
+WHERE id = $1;
+
+-- name: ChirpHasReplies :one
+SELECT EXISTS (
+    SELECT 1 FROM chirps
+    WHERE reply_to = $1
+);
+
+-- name: GetChirpAncestors :many
+WITH RECURSIVE ancestors AS (
+    SELECT parent.*, 1 AS depth FROM chirps parent
+    WHERE parent.id = (SELECT child.reply_to FROM chirps child WHERE child.id = $1)
+    UNION ALL
+    SELECT parent.*, ancestors.depth + 1 FROM chirps parent
+    JOIN ancestors ON parent.id = ancestors.reply_to
+)
+SELECT id, created_at, updated_at, body, user_id, reply_to, deleted_at, search_vector FROM ancestors
+ORDER BY depth DESC;
+
+-- name: GetChirpRepliesPage :many
+SELECT * FROM chirps
+WHERE reply_to = sqlc.arg('chirp_id')
+AND (
+    sqlc.narg('cursor_created_at')::timestamptz IS NULL
+    OR (created_at, id) > (sqlc.narg('cursor_created_at')::timestamptz, sqlc.narg('cursor_id')::uuid)
+)
+ORDER BY created_at ASC, id ASC
+LIMIT sqlc.arg('page_size');
+
+-- name: SearchChirps :many
+SELECT
+    chirps.*,
+    ts_rank(chirps.search_vector, query)::real AS rank,
+    ts_headline(
+        'english',
+        chirps.body,
+        query,
+        'StartSel=' || sqlc.arg('start_sel')::text || ', StopSel=' || sqlc.arg('stop_sel')::text || ', HighlightAll=true'
+    ) AS headline
+FROM chirps, to_tsquery('english', sqlc.arg('query')) query
+WHERE chirps.deleted_at IS NULL
+AND chirps.search_vector @@ query
+ORDER BY rank DESC, chirps.created_at DESC, chirps.id DESC
+LIMIT sqlc.arg('page_size') OFFSET sqlc.arg('page_offset');
\ No newline at end of file
@@ -0,0 +1,16 @@
+-- name: CreateEmailVerificationToken :exec
+INSERT INTO email_verification_tokens (token_hash, created_at, user_id, email, expires_at)
+VALUES ($1, CURRENT_TIMESTAMP, $2, $3, $4);
+
+-- name: UseEmailVerificationToken :one
+UPDATE email_verification_tokens
+SET used_at = NOW()
+WHERE token_hash = $1
+AND used_at IS NULL
+AND expires_at > NOW()
+RETURNING user_id, email;
+
+-- name: DeleteUnusedEmailVerificationTokens :exec
+DELETE FROM email_verification_tokens
+WHERE user_id = $1
+AND used_at IS NULL;
\ No newline at end of file
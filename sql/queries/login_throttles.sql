@@ -0,0 +1,39 @@
+-- name: CreateLoginThrottle :exec
+INSERT INTO login_throttles (key, failures, last_failure_at)
+VALUES ($1, 0, NOW())
+ON CONFLICT (key) DO NOTHING;
+
+-- name: LockLoginThrottle :one
+SELECT * FROM login_throttles
+WHERE key = $1
+FOR UPDATE;
+
+-- name: RecordLoginFailure :one
+INSERT INTO login_throttles (key, failures, last_failure_at)
+VALUES ($1, 1, NOW())
+ON CONFLICT (key) DO UPDATE
+SET failures = CASE
+        WHEN login_throttles.last_failure_at < $2 THEN 1
+        ELSE login_throttles.failures + 1
+    END,
+    last_failure_at = NOW()
+RETURNING failures;
+
+-- name: LockLogin :exec
+UPDATE login_throttles
+SET locked_until = $2
+WHERE key = $1;
+
+-- name: RefundLoginAttempt :exec
+UPDATE login_throttles
+SET failures = GREATEST(failures - 1, 0)
+WHERE key = $1;
+
+-- name: ClearLoginThrottle :exec
+DELETE FROM login_throttles
+WHERE key = $1;
+
+-- name: DeleteStaleLoginThrottles :exec
+DELETE FROM login_throttles
+WHERE last_failure_at < $1
+AND (locked_until IS NULL OR locked_until < NOW());
\ No newline at end of file
@@ -0,0 +1,29 @@
+-- name: CreateOutboundWebhookEvent :exec
+INSERT INTO outbound_webhook_events (id, created_at, event_type, payload, owner_id)
+VALUES (
+    $1,
+    $2,
+    $3,
+    $4,
+    $5
+);
+
+-- name: ClaimUnexpandedWebhookEvents :many
+UPDATE outbound_webhook_events
+SET expanded_at = NOW()
+WHERE id IN (
+    SELECT id FROM outbound_webhook_events
+    WHERE expanded_at IS NULL
+    ORDER BY created_at
+    LIMIT $1
+    FOR UPDATE SKIP LOCKED
+)
+RETURNING id, created_at, event_type, owner_id;
+
+-- name: DeleteExpandedWebhookEvents :exec
+DELETE FROM outbound_webhook_events
+WHERE expanded_at < sqlc.arg('expanded_before')::timestamptz
+AND NOT EXISTS (
+    SELECT 1 FROM webhook_deliveries
+    WHERE webhook_deliveries.event_id = outbound_webhook_events.id
+);
\ No newline at end of file
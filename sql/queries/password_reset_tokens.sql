@@ -0,0 +1,16 @@
+-- name: CreatePasswordResetToken :exec
+INSERT INTO password_reset_tokens (token_hash, created_at, user_id, expires_at)
+VALUES ($1, CURRENT_TIMESTAMP, $2, $3);
+
+-- name: UsePasswordResetToken :one
+UPDATE password_reset_tokens
+SET used_at = NOW()
+WHERE token_hash = $1
+AND used_at IS NULL
+AND expires_at > NOW()
+RETURNING user_id;
+
+-- name: DeleteUnusedPasswordResetTokens :exec
+DELETE FROM password_reset_tokens
+WHERE user_id = $1
+AND used_at IS NULL;
\ No newline at end of file
@@ -0,0 +1,26 @@
+-- name: ListProfaneWords :many
+SELECT word FROM profane_words
+ORDER BY word ASC;
+
+-- name: CreateProfaneWord :exec
+INSERT INTO profane_words (word, created_at)
+VALUES ($1, CURRENT_TIMESTAMP)
+ON CONFLICT (word) DO NOTHING;
+
+-- name: DeleteProfaneWord :execrows
+DELETE FROM profane_words
+WHERE word = $1;
+
+-- name: CreateChirpFlag :one
+INSERT INTO chirp_flags (id, created_at, chirp_id, matched_words)
+VALUES (
+    gen_random_uuid(),
+    CURRENT_TIMESTAMP,
+    $1,
+    $2
+)
+RETURNING *;
+
+-- name: GetChirpFlags :many
+SELECT * FROM chirp_flags
+ORDER BY created_at DESC;
\ No newline at end of file
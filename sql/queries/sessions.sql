@@ -0,0 +1,32 @@
+-- name: CreateSession :one
+INSERT INTO sessions (id, created_at, updated_at, user_id, user_agent, ip_address, last_used_at)
+VALUES (
+    gen_random_uuid(),
+    CURRENT_TIMESTAMP,
+    CURRENT_TIMESTAMP,
+    $1,
+    $2,
+    $3,
+    CURRENT_TIMESTAMP
+)
+RETURNING *;
+
+-- name: TouchSession :exec
+UPDATE sessions
+SET user_agent = $2, ip_address = $3, last_used_at = CURRENT_TIMESTAMP, updated_at = CURRENT_TIMESTAMP
+WHERE id = $1;
+
+-- name: GetSessionByID :one
+SELECT * FROM sessions
+WHERE id = $1;
+
+-- name: GetActiveSessions :many
+SELECT * FROM sessions
+WHERE user_id = $1
+AND EXISTS (
+    SELECT 1 FROM refresh_tokens
+    WHERE refresh_tokens.family_id = sessions.id
+    AND refresh_tokens.revoked_at IS NULL
+    AND refresh_tokens.expires_at > NOW()
+)
+ORDER BY last_used_at DESC;
\ No newline at end of file
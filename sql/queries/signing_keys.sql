@@ -0,0 +1,15 @@
+-- name: CreateSigningKey :one
+INSERT INTO signing_keys (id, created_at, algorithm, private_key, activates_at)
+VALUES ($1, CURRENT_TIMESTAMP, $2, $3, $4)
+RETURNING *;
+
+-- name: GetSigningKeys :many
+SELECT * FROM signing_keys
+WHERE expires_at IS NULL OR expires_at > NOW()
+ORDER BY activates_at ASC;
+
+-- name: ExpireSigningKeys :exec
+UPDATE signing_keys
+SET expires_at = $2
+WHERE expires_at IS NULL
+AND id <> $1;
\ No newline at end of file
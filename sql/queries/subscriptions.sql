@@ -0,0 +1,59 @@
+-- name: GetSubscription :one
+SELECT * FROM subscriptions
+WHERE user_id = $1;
+
+-- name: ActivateSubscription :one
+INSERT INTO subscriptions (user_id, created_at, updated_at, status, current_period_end, last_event_at)
+VALUES (sqlc.arg('user_id'), CURRENT_TIMESTAMP, CURRENT_TIMESTAMP, 'active', sqlc.narg('current_period_end'), sqlc.arg('event_at')::timestamptz)
+ON CONFLICT (user_id) DO UPDATE
+SET status = 'active',
+    current_period_end = EXCLUDED.current_period_end,
+    cancelled_at = NULL,
+    ended_at = NULL,
+    last_event_at = EXCLUDED.last_event_at,
+    updated_at = CURRENT_TIMESTAMP
+WHERE subscriptions.last_event_at IS NULL
+OR subscriptions.last_event_at <= EXCLUDED.last_event_at
+RETURNING *;
+
+-- name: CancelSubscription :one
+UPDATE subscriptions
+SET status = 'cancelled',
+    cancelled_at = NOW(),
+    current_period_end = COALESCE(current_period_end, NOW()),
+    last_event_at = sqlc.arg('event_at')::timestamptz,
+    updated_at = CURRENT_TIMESTAMP
+WHERE user_id = sqlc.arg('user_id')
+AND status IN ('active', 'past_due')
+AND (last_event_at IS NULL OR last_event_at <= sqlc.arg('event_at')::timestamptz)
+RETURNING *;
+
+-- name: MarkSubscriptionPastDue :one
+UPDATE subscriptions
+SET status = 'past_due',
+    current_period_end = LEAST(COALESCE(current_period_end, sqlc.arg('grace_period_end')::timestamptz), sqlc.arg('grace_period_end')::timestamptz),
+    last_event_at = sqlc.arg('event_at')::timestamptz,
+    updated_at = CURRENT_TIMESTAMP
+WHERE user_id = sqlc.arg('user_id')
+AND status IN ('active', 'past_due')
+AND (last_event_at IS NULL OR last_event_at <= sqlc.arg('event_at')::timestamptz)
+RETURNING *;
+
+-- name: EndSubscription :one
+UPDATE subscriptions
+SET status = 'expired',
+    ended_at = NOW(),
+    current_period_end = LEAST(COALESCE(current_period_end, NOW()), NOW()),
+    last_event_at = sqlc.arg('event_at')::timestamptz,
+    updated_at = CURRENT_TIMESTAMP
+WHERE user_id = sqlc.arg('user_id')
+AND status <> 'expired'
+AND (last_event_at IS NULL OR last_event_at <= sqlc.arg('event_at')::timestamptz)
+RETURNING *;
+
+-- name: ExpireLapsedSubscriptions :many
+UPDATE subscriptions
+SET status = 'expired', ended_at = NOW(), updated_at = CURRENT_TIMESTAMP
+WHERE status <> 'expired'
+AND current_period_end <= NOW()
+RETURNING user_id;
\ No newline at end of file
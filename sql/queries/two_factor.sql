@@ -0,0 +1,61 @@
+-- name: UpsertUserTOTP :one
+INSERT INTO user_totp (user_id, created_at, secret)
+VALUES ($1, CURRENT_TIMESTAMP, $2)
+ON CONFLICT (user_id) DO UPDATE
+SET secret = EXCLUDED.secret, created_at = CURRENT_TIMESTAMP, last_used_step = 0
+WHERE user_totp.confirmed_at IS NULL
+RETURNING *;
+
+-- name: GetUserTOTP :one
+SELECT * FROM user_totp
+WHERE user_id = $1;
+
+-- name: ConfirmUserTOTP :execrows
+UPDATE user_totp
+SET confirmed_at = NOW(), last_used_step = $2
+WHERE user_id = $1
+AND confirmed_at IS NULL;
+
+-- name: UseTOTPStep :execrows
+UPDATE user_totp
+SET last_used_step = $2
+WHERE user_id = $1
+AND last_used_step < $2;
+
+-- name: DeleteUserTOTP :exec
+DELETE FROM user_totp
+WHERE user_id = $1;
+
+-- name: CreateRecoveryCode :exec
+INSERT INTO totp_recovery_codes (code_hash, created_at, user_id)
+VALUES ($1, CURRENT_TIMESTAMP, $2);
+
+-- name: UseRecoveryCode :execrows
+UPDATE totp_recovery_codes
+SET used_at = NOW()
+WHERE code_hash = $1
+AND user_id = $2
+AND used_at IS NULL;
+
+-- name: DeleteRecoveryCodes :exec
+DELETE FROM totp_recovery_codes
+WHERE user_id = $1;
+
+-- name: CreateMFAChallenge :exec
+INSERT INTO mfa_challenges (token_hash, created_at, user_id, expires_at)
+VALUES ($1, CURRENT_TIMESTAMP, $2, $3);
+
+-- name: AttemptMFAChallenge :one
+UPDATE mfa_challenges
+SET attempts = attempts + 1
+WHERE token_hash = $1
+AND used_at IS NULL
+AND expires_at > NOW()
+AND attempts < $2
+RETURNING user_id;
+
+-- name: UseMFAChallenge :execrows
+UPDATE mfa_challenges
+SET used_at = NOW()
+WHERE token_hash = $1
+AND used_at IS NULL;
\ No newline at end of file
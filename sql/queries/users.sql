@@ -16,21 +16,54 @@ DELETE FROM users;
 SELECT * FROM users
 WHERE email = $1;
 
--- name: GetUserFromRefreshToken :one
-SELECT users.* FROM users
-JOIN refresh_tokens ON users.id = refresh_tokens.user_id
-WHERE refresh_tokens.token = $1
-AND revoked_at IS NULL
-AND expires_at > NOW();
-
 -- name: UpdateUser :one
 UPDATE users
-SET email = $2, hashed_password = $3, updated_at = CURRENT_TIMESTAMP
+SET email = $2,
+    hashed_password = $3,
+    email_verified_at = CASE WHEN email = $2 THEN email_verified_at END,
+    updated_at = CURRENT_TIMESTAMP
 WHERE id = $1
 RETURNING *;
 
--- name: UpdateUserIsChirpyRed :one
+-- name: SyncUserIsChirpyRed :one
 UPDATE users
-SET is_chirpy_red = $2, updated_at = CURRENT_TIMESTAMP
+SET is_chirpy_red = EXISTS (
+        SELECT 1 FROM subscriptions
+        WHERE subscriptions.user_id = users.id
+        AND subscriptions.status <> 'expired'
+        AND (subscriptions.current_period_end IS NULL OR subscriptions.current_period_end > NOW())
+    ),
+    updated_at = CURRENT_TIMESTAMP
 WHERE id = $1
-RETURNING *;
\ No newline at end of file
+RETURNING *;
+
+-- name: GetUserByID :one
+SELECT * FROM users
+WHERE id = $1;
+
+-- name: GetUserTokensValidAfter :one
+SELECT tokens_valid_after FROM users
+WHERE id = $1;
+
+-- name: UpdateUserTokensValidAfter :exec
+UPDATE users
+SET tokens_valid_after = $2, updated_at = CURRENT_TIMESTAMP
+WHERE id = $1;
+
+-- name: UpdateUserRole :one
+UPDATE users
+SET role = $2, updated_at = CURRENT_TIMESTAMP
+WHERE id = $1
+RETURNING *;
+
+-- name: MarkUserEmailVerified :one
+UPDATE users
+SET email_verified_at = NOW(), updated_at = CURRENT_TIMESTAMP
+WHERE id = $1
+AND email = $2
+RETURNING *;
+
+-- name: UpdateUserPassword :exec
+UPDATE users
+SET hashed_password = $2, updated_at = CURRENT_TIMESTAMP
+WHERE id = $1;
\ No newline at end of file
@@ -0,0 +1,90 @@
+-- name: EnqueueWebhookDeliveries :exec
+INSERT INTO webhook_deliveries (id, created_at, updated_at, endpoint_id, event_id, event_type, status, next_attempt_at)
+SELECT
+    gen_random_uuid(),
+    CURRENT_TIMESTAMP,
+    CURRENT_TIMESTAMP,
+    id,
+    sqlc.arg('event_id'),
+    sqlc.arg('event_type')::text,
+    'pending',
+    NOW()
+FROM webhook_endpoints
+WHERE sqlc.arg('event_type')::text = ANY(event_types)
+AND created_at <= sqlc.arg('event_created_at')::timestamptz
+AND (sqlc.narg('owner_id')::uuid IS NULL OR user_id = sqlc.narg('owner_id')::uuid);
+
+-- name: ClaimDueWebhookDeliveries :many
+UPDATE webhook_deliveries d
+SET attempts = d.attempts + 1,
+    next_attempt_at = sqlc.arg('lease_until')::timestamptz,
+    updated_at = CURRENT_TIMESTAMP
+FROM webhook_endpoints e, outbound_webhook_events ev
+WHERE e.id = d.endpoint_id
+AND ev.id = d.event_id
+AND d.id IN (
+    SELECT id FROM webhook_deliveries
+    WHERE status = 'pending'
+    AND next_attempt_at <= NOW()
+    ORDER BY next_attempt_at
+    LIMIT sqlc.arg('batch_size')
+    FOR UPDATE SKIP LOCKED
+)
+RETURNING d.id, d.event_id, d.event_type, ev.payload, d.attempts, e.url, e.secret;
+
+-- name: RecordWebhookDeliveryAttempt :exec
+INSERT INTO webhook_delivery_attempts (id, created_at, delivery_id, status_code, error, duration_ms)
+VALUES (
+    gen_random_uuid(),
+    CURRENT_TIMESTAMP,
+    $1,
+    $2,
+    $3,
+    $4
+);
+
+-- name: MarkWebhookDeliveryDelivered :exec
+UPDATE webhook_deliveries
+SET status = 'delivered',
+    delivered_at = NOW(),
+    last_error = NULL,
+    updated_at = CURRENT_TIMESTAMP
+WHERE id = $1;
+
+-- name: RetryWebhookDelivery :exec
+UPDATE webhook_deliveries
+SET next_attempt_at = $2,
+    last_error = $3,
+    updated_at = CURRENT_TIMESTAMP
+WHERE id = $1;
+
+-- name: FailWebhookDelivery :exec
+UPDATE webhook_deliveries
+SET status = 'failed',
+    last_error = $2,
+    updated_at = CURRENT_TIMESTAMP
+WHERE id = $1;
+
+-- name: GetWebhookDeliveriesPage :many
+SELECT * FROM webhook_deliveries
+WHERE endpoint_id = sqlc.arg('endpoint_id')
+AND (
+    sqlc.narg('cursor_created_at')::timestamptz IS NULL
+    OR (created_at, id) < (sqlc.narg('cursor_created_at')::timestamptz, sqlc.narg('cursor_id')::uuid)
+)
+ORDER BY created_at DESC, id DESC
+LIMIT sqlc.arg('page_size');
+
+-- name: GetWebhookDelivery :one
+SELECT * FROM webhook_deliveries
+WHERE id = $1;
+
+-- name: GetWebhookDeliveryAttempts :many
+SELECT * FROM webhook_delivery_attempts
+WHERE delivery_id = $1
+ORDER BY created_at;
+
+-- name: DeleteFinishedWebhookDeliveries :exec
+DELETE FROM webhook_deliveries
+WHERE status <> 'pending'
+AND updated_at < $1;
\ No newline at end of file
@@ -0,0 +1,33 @@
+-- name: CreateWebhookEndpoint :one
+INSERT INTO webhook_endpoints (id, created_at, updated_at, user_id, url, secret, event_types)
+VALUES (
+    gen_random_uuid(),
+    CURRENT_TIMESTAMP,
+    CURRENT_TIMESTAMP,
+    $1,
+    $2,
+    $3,
+    $4
+)
+RETURNING *;
+
+-- name: GetWebhookEndpoints :many
+SELECT * FROM webhook_endpoints
+WHERE user_id = $1
+ORDER BY created_at;
+
+-- name: GetWebhookEndpoint :one
+SELECT * FROM webhook_endpoints
+WHERE id = $1;
+
+-- name: DeleteWebhookEndpoint :execrows
+DELETE FROM webhook_endpoints
+WHERE id = $1
+AND user_id = $2;
+
+-- name: LockWebhookEndpoints :exec
+LOCK TABLE webhook_endpoints IN SHARE ROW EXCLUSIVE MODE;
+
+-- name: CountWebhookEndpointsForEvents :one
+SELECT COUNT(*) FROM webhook_endpoints
+WHERE event_types && sqlc.arg('event_types')::text[];
\ No newline at end of file
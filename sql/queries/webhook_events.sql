@@ -0,0 +1,55 @@
+-- name: CreateWebhookEvent :one
+INSERT INTO webhook_events (id, received_at, updated_at, event_id, event_type, payload, status)
+VALUES (
+    gen_random_uuid(),
+    CURRENT_TIMESTAMP,
+    CURRENT_TIMESTAMP,
+    $1,
+    $2,
+    $3,
+    'pending'
+)
+ON CONFLICT (event_id) DO NOTHING
+RETURNING *;
+
+-- name: RetireWebhookEventID :exec
+UPDATE webhook_events
+SET event_id = event_id || ':' || id::text,
+    updated_at = CURRENT_TIMESTAMP
+WHERE event_id = $1
+AND received_at < $2;
+
+-- name: GetWebhookEvent :one
+SELECT * FROM webhook_events
+WHERE id = $1;
+
+-- name: GetWebhookEventByEventID :one
+SELECT * FROM webhook_events
+WHERE event_id = $1;
+
+-- name: LockWebhookEvent :one
+SELECT * FROM webhook_events
+WHERE id = $1
+FOR UPDATE;
+
+-- name: GetWebhookEventsByStatus :many
+SELECT * FROM webhook_events
+WHERE status = $1
+ORDER BY received_at DESC;
+
+-- name: MarkWebhookEventProcessed :exec
+UPDATE webhook_events
+SET status = 'processed',
+    attempts = attempts + 1,
+    last_error = NULL,
+    processed_at = NOW(),
+    updated_at = CURRENT_TIMESTAMP
+WHERE id = $1;
+
+-- name: MarkWebhookEventFailed :exec
+UPDATE webhook_events
+SET status = 'failed',
+    attempts = attempts + 1,
+    last_error = $2,
+    updated_at = CURRENT_TIMESTAMP
+WHERE id = $1;
\ No newline at end of file
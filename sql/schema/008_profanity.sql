@@ -0,0 +1,18 @@
+-- +goose Up
+CREATE TABLE profane_words (
+    word TEXT PRIMARY KEY,
+    created_at TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP NOT NULL
+);
+
+INSERT INTO profane_words (word) VALUES ('kerfuffle'), ('sharbert'), ('fornax');
+
+CREATE TABLE chirp_flags (
+    id UUID PRIMARY KEY,
+    created_at TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP NOT NULL,
+    chirp_id UUID NOT NULL REFERENCES chirps(id) ON DELETE CASCADE,
+    matched_words TEXT[] NOT NULL
+);
+
+-- +goose Down
+DROP TABLE chirp_flags;
+DROP TABLE profane_words;
\ No newline at end of file
@@ -0,0 +1,13 @@
+-- +goose Up
+ALTER TABLE chirps
+ADD COLUMN reply_to UUID REFERENCES chirps(id) ON DELETE SET NULL,
+ADD COLUMN deleted_at TIMESTAMP WITH TIME ZONE;
+
+CREATE INDEX chirps_reply_to_created_at_id_idx ON chirps (reply_to, created_at, id);
+
+-- +goose Down
+DROP INDEX chirps_reply_to_created_at_id_idx;
+
+ALTER TABLE chirps
+DROP COLUMN deleted_at,
+DROP COLUMN reply_to;
\ No newline at end of file
@@ -0,0 +1,13 @@
+-- +goose Up
+CREATE TABLE chirp_reactions (
+    user_id UUID NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    chirp_id UUID NOT NULL REFERENCES chirps(id) ON DELETE CASCADE,
+    reaction TEXT NOT NULL,
+    created_at TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP NOT NULL,
+    PRIMARY KEY (user_id, chirp_id, reaction)
+);
+
+CREATE INDEX chirp_reactions_chirp_id_idx ON chirp_reactions (chirp_id);
+
+-- +goose Down
+DROP TABLE chirp_reactions;
\ No newline at end of file
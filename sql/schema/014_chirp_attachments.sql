@@ -0,0 +1,18 @@
+-- +goose Up
+CREATE TABLE attachments (
+    id UUID PRIMARY KEY,
+    created_at TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP NOT NULL,
+    user_id UUID NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    chirp_id UUID REFERENCES chirps(id) ON DELETE CASCADE,
+    storage_key TEXT NOT NULL UNIQUE,
+    content_type TEXT NOT NULL,
+    size_bytes BIGINT NOT NULL
+);
+
+CREATE INDEX attachments_chirp_id_idx ON attachments (chirp_id);
+
+CREATE INDEX attachments_pending_user_id_idx ON attachments (user_id) WHERE chirp_id IS NULL;
+CREATE INDEX attachments_pending_created_at_idx ON attachments (created_at) WHERE chirp_id IS NULL;
+
+-- +goose Down
+DROP TABLE attachments;
\ No newline at end of file
@@ -0,0 +1,16 @@
+-- +goose Up
+-- Existing tokens are hashed in place, so sessions survive the migration.
+UPDATE refresh_tokens
+SET token = encode(sha256(convert_to(token, 'UTF8')), 'hex');
+
+ALTER TABLE refresh_tokens
+RENAME COLUMN token TO token_hash;
+
+-- +goose Down
+-- Hashes cannot be reversed; every refresh token is invalid after this.
+ALTER TABLE refresh_tokens
+RENAME COLUMN token_hash TO token;
+
+UPDATE refresh_tokens
+SET revoked_at = NOW()
+WHERE revoked_at IS NULL;
\ No newline at end of file
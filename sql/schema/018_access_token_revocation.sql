@@ -0,0 +1,18 @@
+-- +goose Up
+ALTER TABLE users
+ADD COLUMN tokens_valid_after TIMESTAMP WITH TIME ZONE DEFAULT '1970-01-01 00:00:00+00' NOT NULL;
+
+CREATE TABLE revoked_access_tokens (
+    jti UUID PRIMARY KEY,
+    created_at TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP NOT NULL,
+    user_id UUID NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    expires_at TIMESTAMP WITH TIME ZONE NOT NULL
+);
+
+CREATE INDEX revoked_access_tokens_user_id_idx ON revoked_access_tokens (user_id, expires_at);
+
+-- +goose Down
+DROP TABLE revoked_access_tokens;
+
+ALTER TABLE users
+DROP COLUMN tokens_valid_after;
\ No newline at end of file
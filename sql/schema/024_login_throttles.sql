@@ -0,0 +1,12 @@
+-- +goose Up
+CREATE TABLE login_throttles (
+    key TEXT PRIMARY KEY,
+    failures INTEGER NOT NULL,
+    last_failure_at TIMESTAMP WITH TIME ZONE NOT NULL,
+    locked_until TIMESTAMP WITH TIME ZONE
+);
+
+CREATE INDEX login_throttles_last_failure_at_idx ON login_throttles (last_failure_at);
+
+-- +goose Down
+DROP TABLE login_throttles;
\ No newline at end of file
@@ -0,0 +1,22 @@
+-- +goose Up
+CREATE TABLE subscriptions (
+    user_id UUID PRIMARY KEY REFERENCES users(id) ON DELETE CASCADE,
+    created_at TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP NOT NULL,
+    updated_at TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP NOT NULL,
+    status TEXT NOT NULL CHECK (status IN ('active', 'past_due', 'cancelled', 'expired')),
+    current_period_end TIMESTAMP WITH TIME ZONE,
+    cancelled_at TIMESTAMP WITH TIME ZONE,
+    ended_at TIMESTAMP WITH TIME ZONE,
+    last_event_at TIMESTAMP WITH TIME ZONE
+);
+
+CREATE INDEX subscriptions_lapsing_idx ON subscriptions (current_period_end)
+WHERE status <> 'expired';
+
+-- Everyone upgraded so far has an open-ended subscription.
+INSERT INTO subscriptions (user_id, status)
+SELECT id, 'active' FROM users
+WHERE is_chirpy_red;
+
+-- +goose Down
+DROP TABLE subscriptions;
\ No newline at end of file
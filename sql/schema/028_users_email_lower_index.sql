@@ -0,0 +1,5 @@
+-- +goose Up
+CREATE INDEX users_email_lower_idx ON users (LOWER(email));
+
+-- +goose Down
+DROP INDEX users_email_lower_idx;
\ No newline at end of file
@@ -0,0 +1,95 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+	"time"
+
+	"github.com/dennisdijkstra/go/internal/events"
+	"github.com/google/uuid"
+)
+
+const (
+	eventChirpCreated = "chirp.created"
+	eventChirpDeleted = "chirp.deleted"
+
+	streamHeartbeatInterval = 15 * time.Second
+)
+
+type ChirpDeletedEvent struct {
+	ID uuid.UUID `json:"id"`
+}
+
+// handlerStreamChirps pushes created and deleted chirps as Server-Sent
+// Events. Clients that reconnect with Last-Event-ID get the events they
+// missed, as long as they are still in the broker's history.
+func (cfg *apiConfig) handlerStreamChirps(w http.ResponseWriter, r *http.Request) {
+	var authorID uuid.UUID
+	if authorIDQuery := r.URL.Query().Get("author_id"); authorIDQuery != "" {
+		parsedID, err := uuid.Parse(authorIDQuery)
+		if err != nil {
+			respondWithError(w, http.StatusBadRequest, "Invalid author_id")
+			return
+		}
+		authorID = parsedID
+	}
+
+	// An ID from before a restart starts a fresh stream rather than an
+	// error, so the client does not reconnect with it forever.
+	lastEventID := r.Header.Get("Last-Event-ID")
+
+	sub := cfg.chirpEvents.Subscribe(lastEventID, func(event events.Event) bool {
+		return authorID == uuid.Nil || event.UserID == authorID
+	})
+	defer cfg.chirpEvents.Unsubscribe(sub)
+
+	rc := http.NewResponseController(w)
+	w.Header().Set("Content-Type", "text/event-stream")
+	w.Header().Set("Cache-Control", "no-cache")
+	w.Header().Set("Connection", "keep-alive")
+	w.Header().Set("X-Accel-Buffering", "no")
+	w.WriteHeader(http.StatusOK)
+
+	err := rc.Flush()
+	if err != nil {
+		return
+	}
+
+	heartbeat := time.NewTicker(streamHeartbeatInterval)
+	defer heartbeat.Stop()
+
+	for {
+		select {
+		case <-r.Context().Done():
+			return
+		case <-heartbeat.C:
+			_, err = fmt.Fprint(w, ": heartbeat\n\n")
+		case event, ok := <-sub.C:
+			if !ok {
+				// Dropped for falling behind. The client reconnects with
+				// Last-Event-ID and picks up from the history buffer.
+				return
+			}
+			_, err = fmt.Fprintf(w, "id: %s\nevent: %s\ndata: %s\n\n", event.LastEventID(), event.Type, event.Data)
+		}
+
+		if err == nil {
+			err = rc.Flush()
+		}
+		if err != nil {
+			return
+		}
+	}
+}
+
+func (cfg *apiConfig) publishChirpEvent(eventType string, userID uuid.UUID, payload any) {
+	data, err := json.Marshal(payload)
+	if err != nil {
+		log.Printf("Error marshalling %s event: %s", eventType, err)
+		return
+	}
+
+	cfg.chirpEvents.Publish(eventType, userID, data)
+}
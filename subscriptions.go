@@ -0,0 +1,179 @@
+package main
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"log"
+	"net/http"
+	"time"
+
+	"github.com/dennisdijkstra/go/internal/database"
+	"github.com/google/uuid"
+)
+
+const (
+	// paymentGracePeriod is how long a user keeps Chirpy Red after a failed
+	// payment, giving Polka time to retry the charge.
+	paymentGracePeriod = 3 * 24 * time.Hour
+	// subscriptionExpiryInterval is how often lapsed subscriptions are
+	// expired. A lapsed user can keep Chirpy Red for up to this long.
+	subscriptionExpiryInterval = 10 * time.Minute
+)
+
+var errSubscriptionUserNotFound = errors.New("subscription user not found")
+
+type Subscription struct {
+	Status           string     `json:"status"`
+	CurrentPeriodEnd *time.Time `json:"current_period_end"`
+	CancelledAt      *time.Time `json:"cancelled_at"`
+	EndedAt          *time.Time `json:"ended_at"`
+}
+
+func (cfg *apiConfig) handlerGetSubscription(w http.ResponseWriter, r *http.Request) {
+	userID, code, msg, ok := cfg.requireJWTUserID(r)
+	if !ok {
+		respondWithError(w, code, msg)
+		return
+	}
+
+	subscription, err := cfg.db.GetSubscription(r.Context(), userID)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			respondWithError(w, http.StatusNotFound, "Subscription not found")
+			return
+		}
+		respondWithError(w, http.StatusInternalServerError, "Something went wrong while fetching the subscription")
+		return
+	}
+
+	respondWithJSON(w, http.StatusOK, Subscription{
+		Status:           subscription.Status,
+		CurrentPeriodEnd: nullTimePtr(subscription.CurrentPeriodEnd),
+		CancelledAt:      nullTimePtr(subscription.CancelledAt),
+		EndedAt:          nullTimePtr(subscription.EndedAt),
+	})
+}
+
+// applyPolkaEvent moves the user's subscription through its lifecycle and
+// recomputes their Chirpy Red flag from it, using q so the change commits
+// together with the caller's record of the event. Events that do not apply
+// to the subscription's current state, events that occurred before the last
+// one applied to it, and unknown event types, are ignored.
+//
+//   - user.upgraded starts or renews the subscription.
+//   - subscription.cancelled keeps access until the paid period ends.
+//   - payment.failed keeps access for a grace period at most.
+//   - user.downgraded ends access at once, as after a refund.
+func applyPolkaEvent(ctx context.Context, q *database.Queries, event WebhookParams, occurredAt time.Time) error {
+	switch event.Event {
+	case "user.upgraded", "user.downgraded", "subscription.cancelled", "payment.failed":
+	default:
+		return nil
+	}
+
+	userID := event.Data.UserID
+	previous, err := q.GetUserByID(ctx, userID)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return errSubscriptionUserNotFound
+		}
+		return err
+	}
+
+	switch event.Event {
+	case "user.upgraded":
+		periodEnd := sql.NullTime{}
+		if event.Data.CurrentPeriodEnd != nil {
+			periodEnd = sql.NullTime{Time: *event.Data.CurrentPeriodEnd, Valid: true}
+		}
+		_, err = q.ActivateSubscription(ctx, database.ActivateSubscriptionParams{
+			UserID:           userID,
+			CurrentPeriodEnd: periodEnd,
+			EventAt:          occurredAt,
+		})
+	case "subscription.cancelled":
+		_, err = q.CancelSubscription(ctx, database.CancelSubscriptionParams{
+			EventAt: occurredAt,
+			UserID:  userID,
+		})
+	case "payment.failed":
+		_, err = q.MarkSubscriptionPastDue(ctx, database.MarkSubscriptionPastDueParams{
+			GracePeriodEnd: time.Now().UTC().Add(paymentGracePeriod),
+			EventAt:        occurredAt,
+			UserID:         userID,
+		})
+	case "user.downgraded":
+		_, err = q.EndSubscription(ctx, database.EndSubscriptionParams{
+			EventAt: occurredAt,
+			UserID:  userID,
+		})
+	}
+	if err != nil && !errors.Is(err, sql.ErrNoRows) {
+		return err
+	}
+
+	user, err := q.SyncUserIsChirpyRed(ctx, userID)
+	if err != nil {
+		return err
+	}
+
+	// Only the user's own webhooks learn that they upgraded.
+	if user.IsChirpyRed && !previous.IsChirpyRed {
+		return enqueueWebhookEvent(ctx, q, eventUserUpgraded, uuid.NullUUID{UUID: userID, Valid: true}, UserUpgradedEvent{UserID: userID})
+	}
+
+	return nil
+}
+
+// expireSubscriptions ends every subscription whose paid period is over and
+// takes Chirpy Red away from its user.
+func (cfg *apiConfig) expireSubscriptions(ctx context.Context) (int, error) {
+	tx, err := cfg.dbConn.BeginTx(ctx, nil)
+	if err != nil {
+		return 0, err
+	}
+	defer tx.Rollback()
+	qtx := cfg.db.WithTx(tx)
+
+	userIDs, err := qtx.ExpireLapsedSubscriptions(ctx)
+	if err != nil {
+		return 0, err
+	}
+
+	for _, userID := range userIDs {
+		_, err = qtx.SyncUserIsChirpyRed(ctx, userID)
+		if err != nil {
+			return 0, err
+		}
+	}
+
+	return len(userIDs), tx.Commit()
+}
+
+func (cfg *apiConfig) runSubscriptionExpiry(ctx context.Context) {
+	ticker := time.NewTicker(subscriptionExpiryInterval)
+	defer ticker.Stop()
+
+	for {
+		expired, err := cfg.expireSubscriptions(ctx)
+		if err != nil {
+			log.Printf("Error expiring subscriptions: %s", err)
+		} else if expired > 0 {
+			log.Printf("Expired %d lapsed subscriptions", expired)
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+func nullTimePtr(t sql.NullTime) *time.Time {
+	if !t.Valid {
+		return nil
+	}
+	return &t.Time
+}
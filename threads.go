@@ -0,0 +1,98 @@
+package main
+
+import (
+	"database/sql"
+	"net/http"
+
+	"github.com/dennisdijkstra/go/internal/database"
+	"github.com/google/uuid"
+)
+
+type ChirpThread struct {
+	Chirp      Chirp   `json:"chirp"`
+	Ancestors  []Chirp `json:"ancestors"`
+	Replies    []Chirp `json:"replies"`
+	NextCursor string  `json:"next_cursor"`
+}
+
+// handlerGetChirpThread returns a chirp with the chain of chirps it replies
+// to, root first, and a page of its direct replies, oldest first. Deleted
+// chirps in the thread show up as tombstones.
+func (cfg *apiConfig) handlerGetChirpThread(w http.ResponseWriter, r *http.Request) {
+	chirpID := r.PathValue("chirpID")
+	if chirpID == "" {
+		respondWithError(w, http.StatusBadRequest, "Chirp ID is required")
+		return
+	}
+
+	chirpUUID, err := uuid.Parse(chirpID)
+	if err != nil {
+		respondWithError(w, http.StatusBadRequest, "Invalid chirp ID")
+		return
+	}
+
+	limit, cursorCreatedAt, cursorID, err := parsePageParams(r)
+	if err != nil {
+		respondWithError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	// A tombstone keeps its thread navigable, so it can be fetched here.
+	chirp, err := cfg.db.GetChirpIncludingDeleted(r.Context(), chirpUUID)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			respondWithError(w, http.StatusNotFound, "Chirp not found")
+			return
+		}
+		respondWithError(w, http.StatusInternalServerError, "Something went wrong while fetching the chirp")
+		return
+	}
+
+	ancestors, err := cfg.db.GetChirpAncestors(r.Context(), chirpUUID)
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Something went wrong while fetching the thread")
+		return
+	}
+
+	replies, err := cfg.db.GetChirpRepliesPage(r.Context(), database.GetChirpRepliesPageParams{
+		ChirpID:         uuid.NullUUID{UUID: chirpUUID, Valid: true},
+		CursorCreatedAt: cursorCreatedAt,
+		CursorID:        cursorID,
+		PageSize:        limit + 1,
+	})
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Something went wrong while fetching the replies")
+		return
+	}
+
+	nextCursor := ""
+	if len(replies) > int(limit) {
+		replies = replies[:limit]
+		last := replies[len(replies)-1]
+		nextCursor = encodeCursor(last.CreatedAt, last.ID)
+	}
+
+	root := []Chirp{chirpFromDB(chirp)}
+	response := ChirpThread{
+		Ancestors:  make([]Chirp, 0, len(ancestors)),
+		Replies:    make([]Chirp, 0, len(replies)),
+		NextCursor: nextCursor,
+	}
+
+	for _, ancestor := range ancestors {
+		response.Ancestors = append(response.Ancestors, chirpFromDB(database.Chirp(ancestor)))
+	}
+
+	for _, reply := range replies {
+		response.Replies = append(response.Replies, chirpFromDB(reply))
+	}
+
+	err = cfg.decorateChirps(r.Context(), cfg.optionalJWTUserID(r), root, response.Ancestors, response.Replies)
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Something went wrong while fetching chirp details")
+		return
+	}
+	response.Chirp = root[0]
+
+	respondWithJSON(w, http.StatusOK, response)
+}
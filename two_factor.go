@@ -0,0 +1,438 @@
+package main
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"log"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/dennisdijkstra/go/internal/auth"
+	"github.com/dennisdijkstra/go/internal/database"
+	"github.com/dennisdijkstra/go/internal/throttle"
+	"github.com/google/uuid"
+)
+
+const (
+	totpIssuer           = "Chirpy"
+	recoveryCodeCount    = 10
+	mfaChallengeLifetime = 5 * time.Minute
+	maxMFAAttempts       = 5
+)
+
+type TOTPEnrollment struct {
+	Secret string `json:"secret"`
+	URI    string `json:"otpauth_uri"`
+}
+
+type TOTPCodeParams struct {
+	Code string `json:"code"`
+}
+
+type RecoveryCodes struct {
+	RecoveryCodes []string `json:"recovery_codes"`
+}
+
+type MFAChallenge struct {
+	MFARequired bool      `json:"mfa_required"`
+	MFAToken    string    `json:"mfa_token"`
+	ExpiresAt   time.Time `json:"expires_at"`
+}
+
+type MFALoginParams struct {
+	MFAToken string `json:"mfa_token"`
+	Code     string `json:"code"`
+}
+
+// handlerEnrollTOTP creates a new TOTP secret for the user. It only takes
+// effect once confirmed with a code, so an abandoned enrollment never locks
+// anyone out; enrolling again replaces it.
+func (cfg *apiConfig) handlerEnrollTOTP(w http.ResponseWriter, r *http.Request) {
+	userID, code, msg, ok := cfg.requireJWTUserID(r)
+	if !ok {
+		respondWithError(w, code, msg)
+		return
+	}
+
+	user, err := cfg.db.GetUserByID(r.Context(), userID)
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Something went wrong while fetching the user")
+		return
+	}
+
+	secret, err := auth.GenerateTOTPSecret()
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Something went wrong while creating the secret")
+		return
+	}
+
+	sealed, err := auth.SealTOTPSecret(cfg.signingKeyEncryptionKey, user.ID, secret)
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Something went wrong while saving the secret")
+		return
+	}
+
+	_, err = cfg.db.UpsertUserTOTP(r.Context(), database.UpsertUserTOTPParams{
+		UserID: user.ID,
+		Secret: sealed,
+	})
+	if err != nil {
+		if err == sql.ErrNoRows {
+			respondWithError(w, http.StatusConflict, "Two-factor authentication is already enabled")
+			return
+		}
+		respondWithError(w, http.StatusInternalServerError, "Something went wrong while saving the secret")
+		return
+	}
+
+	respondWithJSON(w, http.StatusCreated, TOTPEnrollment{
+		Secret: secret,
+		URI:    auth.TOTPURI(secret, totpIssuer, user.Email),
+	})
+}
+
+// handlerConfirmTOTP turns on two-factor authentication once the user shows
+// their authenticator produces valid codes, and hands out recovery codes.
+func (cfg *apiConfig) handlerConfirmTOTP(w http.ResponseWriter, r *http.Request) {
+	userID, code, msg, ok := cfg.requireJWTUserID(r)
+	if !ok {
+		respondWithError(w, code, msg)
+		return
+	}
+
+	params, code, msg, ok := parseTOTPCodeParams(r)
+	if !ok {
+		respondWithError(w, code, msg)
+		return
+	}
+
+	totp, err := cfg.db.GetUserTOTP(r.Context(), userID)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			respondWithError(w, http.StatusNotFound, "Two-factor enrollment not found")
+			return
+		}
+		respondWithError(w, http.StatusInternalServerError, "Something went wrong while fetching the enrollment")
+		return
+	}
+
+	if totp.ConfirmedAt.Valid {
+		respondWithError(w, http.StatusConflict, "Two-factor authentication is already enabled")
+		return
+	}
+
+	secret, err := auth.OpenTOTPSecret(cfg.signingKeyEncryptionKey, userID, totp.Secret)
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Something went wrong while checking the code")
+		return
+	}
+
+	step, valid := auth.ValidateTOTP(secret, params.Code, time.Now())
+	if !valid {
+		respondWithError(w, http.StatusBadRequest, "Invalid code")
+		return
+	}
+
+	codes, err := auth.GenerateRecoveryCodes(recoveryCodeCount)
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Something went wrong while creating recovery codes")
+		return
+	}
+
+	tx, err := cfg.dbConn.BeginTx(r.Context(), nil)
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Something went wrong while enabling two-factor authentication")
+		return
+	}
+	defer tx.Rollback()
+	qtx := cfg.db.WithTx(tx)
+
+	confirmed, err := qtx.ConfirmUserTOTP(r.Context(), database.ConfirmUserTOTPParams{
+		UserID:       userID,
+		LastUsedStep: step,
+	})
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Something went wrong while enabling two-factor authentication")
+		return
+	}
+
+	if confirmed == 0 {
+		respondWithError(w, http.StatusConflict, "Two-factor authentication is already enabled")
+		return
+	}
+
+	err = qtx.DeleteRecoveryCodes(r.Context(), userID)
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Something went wrong while creating recovery codes")
+		return
+	}
+
+	for _, recoveryCode := range codes {
+		err = qtx.CreateRecoveryCode(r.Context(), database.CreateRecoveryCodeParams{
+			CodeHash: auth.HashRecoveryCode(recoveryCode),
+			UserID:   userID,
+		})
+		if err != nil {
+			respondWithError(w, http.StatusInternalServerError, "Something went wrong while creating recovery codes")
+			return
+		}
+	}
+
+	if err := tx.Commit(); err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Something went wrong while enabling two-factor authentication")
+		return
+	}
+
+	respondWithJSON(w, http.StatusOK, RecoveryCodes{RecoveryCodes: codes})
+}
+
+// handlerDisableTOTP turns two-factor authentication off. It takes a current
+// code or a recovery code so a stolen access token is not enough.
+func (cfg *apiConfig) handlerDisableTOTP(w http.ResponseWriter, r *http.Request) {
+	userID, code, msg, ok := cfg.requireJWTUserID(r)
+	if !ok {
+		respondWithError(w, code, msg)
+		return
+	}
+
+	params, code, msg, ok := parseTOTPCodeParams(r)
+	if !ok {
+		respondWithError(w, code, msg)
+		return
+	}
+
+	// Every attempt is counted before the code is checked, so a stolen
+	// access token only allows a handful of guesses.
+	throttleKey := throttledKey{Key: "2fa-disable:" + userID.String(), Policy: accountLoginPolicy}
+	wait, err := cfg.reserveAttempt(r.Context(), throttleKey)
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Something went wrong while checking the code")
+		return
+	}
+
+	if wait > 0 {
+		w.Header().Set("Retry-After", strconv.Itoa(throttle.RetryAfter(wait)))
+		respondWithError(w, http.StatusTooManyRequests, "Too many invalid codes, try again later")
+		return
+	}
+
+	tx, err := cfg.dbConn.BeginTx(r.Context(), nil)
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Something went wrong while disabling two-factor authentication")
+		return
+	}
+	defer tx.Rollback()
+	qtx := cfg.db.WithTx(tx)
+
+	valid, err := cfg.verifySecondFactor(r.Context(), qtx, userID, params.Code)
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Something went wrong while checking the code")
+		return
+	}
+
+	if !valid {
+		respondWithError(w, http.StatusBadRequest, "Invalid code")
+		return
+	}
+
+	err = qtx.DeleteUserTOTP(r.Context(), userID)
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Something went wrong while disabling two-factor authentication")
+		return
+	}
+
+	err = qtx.DeleteRecoveryCodes(r.Context(), userID)
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Something went wrong while disabling two-factor authentication")
+		return
+	}
+
+	if err := tx.Commit(); err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Something went wrong while disabling two-factor authentication")
+		return
+	}
+
+	if err := cfg.db.ClearLoginThrottle(r.Context(), throttleKey.Key); err != nil {
+		log.Printf("Error clearing failed codes for %s: %s", throttleKey.Key, err)
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// handlerLoginMFA is the second step of login for users with two-factor
+// authentication: it exchanges the challenge token from /api/login and a
+// code for the real token pair.
+func (cfg *apiConfig) handlerLoginMFA(w http.ResponseWriter, r *http.Request) {
+	decoder := json.NewDecoder(r.Body)
+	params := MFALoginParams{}
+	err := decoder.Decode(&params)
+
+	if err != nil {
+		respondWithError(w, http.StatusBadRequest, "Something went wrong while decoding the request body")
+		return
+	}
+
+	challengeHash := auth.HashOneTimeToken(params.MFAToken)
+
+	// Every attempt is counted before the code is checked, so a challenge
+	// only allows a handful of guesses.
+	userID, err := cfg.db.AttemptMFAChallenge(r.Context(), database.AttemptMFAChallengeParams{
+		TokenHash: challengeHash,
+		Attempts:  maxMFAAttempts,
+	})
+	if err != nil {
+		if err == sql.ErrNoRows {
+			respondWithError(w, http.StatusUnauthorized, "Invalid or expired MFA token")
+			return
+		}
+		respondWithError(w, http.StatusInternalServerError, "Something went wrong while checking the MFA token")
+		return
+	}
+
+	user, err := cfg.db.GetUserByID(r.Context(), userID)
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Something went wrong while fetching the user")
+		return
+	}
+
+	// Wrong codes count as failed logins too, so starting new challenges
+	// does not buy an attacker more guesses.
+	throttleKeys := cfg.loginThrottleKeys(r, user.Email)
+	wait, err := cfg.reserveLoginAttempt(r.Context(), throttleKeys)
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Something went wrong while checking login attempts")
+		return
+	}
+
+	if wait > 0 {
+		respondWithLoginLockout(w, wait)
+		return
+	}
+
+	tx, err := cfg.dbConn.BeginTx(r.Context(), nil)
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Something went wrong while checking the code")
+		return
+	}
+	defer tx.Rollback()
+	qtx := cfg.db.WithTx(tx)
+
+	valid, err := cfg.verifySecondFactor(r.Context(), qtx, userID, params.Code)
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Something went wrong while checking the code")
+		return
+	}
+
+	if !valid {
+		cfg.metrics.loginFailures.Inc()
+		respondWithError(w, http.StatusUnauthorized, "Invalid code")
+		return
+	}
+
+	used, err := qtx.UseMFAChallenge(r.Context(), challengeHash)
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Something went wrong while checking the MFA token")
+		return
+	}
+
+	if used == 0 {
+		respondWithError(w, http.StatusUnauthorized, "Invalid or expired MFA token")
+		return
+	}
+
+	if err := tx.Commit(); err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Something went wrong while checking the code")
+		return
+	}
+
+	cfg.clearLoginFailures(r.Context(), throttleKeys)
+	cfg.completeLogin(w, r, user)
+}
+
+// startMFAChallenge issues a challenge token if the user has two-factor
+// authentication enabled. required is false when they do not.
+func (cfg *apiConfig) startMFAChallenge(ctx context.Context, userID uuid.UUID) (MFAChallenge, bool, error) {
+	totp, err := cfg.db.GetUserTOTP(ctx, userID)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return MFAChallenge{}, false, nil
+		}
+		return MFAChallenge{}, false, err
+	}
+
+	if !totp.ConfirmedAt.Valid {
+		return MFAChallenge{}, false, nil
+	}
+
+	token, err := auth.MakeOneTimeToken()
+	if err != nil {
+		return MFAChallenge{}, false, err
+	}
+
+	expiresAt := time.Now().UTC().Add(mfaChallengeLifetime)
+	err = cfg.db.CreateMFAChallenge(ctx, database.CreateMFAChallengeParams{
+		TokenHash: auth.HashOneTimeToken(token),
+		UserID:    userID,
+		ExpiresAt: expiresAt,
+	})
+	if err != nil {
+		return MFAChallenge{}, false, err
+	}
+
+	return MFAChallenge{MFARequired: true, MFAToken: token, ExpiresAt: expiresAt}, true, nil
+}
+
+// verifySecondFactor accepts either a current TOTP code or an unused
+// recovery code. Both are consumed: a TOTP code cannot be replayed and a
+// recovery code works once.
+func (cfg *apiConfig) verifySecondFactor(ctx context.Context, q *database.Queries, userID uuid.UUID, code string) (bool, error) {
+	totp, err := q.GetUserTOTP(ctx, userID)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return false, nil
+		}
+		return false, err
+	}
+
+	if !totp.ConfirmedAt.Valid {
+		return false, nil
+	}
+
+	secret, err := auth.OpenTOTPSecret(cfg.signingKeyEncryptionKey, userID, totp.Secret)
+	if err != nil {
+		return false, err
+	}
+
+	if step, ok := auth.ValidateTOTP(secret, strings.ReplaceAll(code, " ", ""), time.Now()); ok {
+		used, err := q.UseTOTPStep(ctx, database.UseTOTPStepParams{
+			UserID:       userID,
+			LastUsedStep: step,
+		})
+		return used == 1, err
+	}
+
+	used, err := q.UseRecoveryCode(ctx, database.UseRecoveryCodeParams{
+		CodeHash: auth.HashRecoveryCode(code),
+		UserID:   userID,
+	})
+	return used == 1, err
+}
+
+func parseTOTPCodeParams(r *http.Request) (TOTPCodeParams, int, string, bool) {
+	decoder := json.NewDecoder(r.Body)
+	params := TOTPCodeParams{}
+	err := decoder.Decode(&params)
+
+	if err != nil {
+		return TOTPCodeParams{}, http.StatusBadRequest, "Something went wrong while decoding the request body", false
+	}
+
+	if params.Code == "" {
+		return TOTPCodeParams{}, http.StatusBadRequest, "Code is required", false
+	}
+
+	return params, 0, "", true
+}
@@ -0,0 +1,116 @@
+package main
+
+import (
+	"database/sql/driver"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/dennisdijkstra/go/internal/auth"
+	"github.com/google/uuid"
+)
+
+func TestDisableTOTPLimitsAttempts(t *testing.T) {
+	f := newFakeDB(t)
+	throttles := stubLoginThrottles(f)
+	f.stub("GetUserTOTP", func([]driver.Value) (fakeResult, error) {
+		return fakeResult{}, nil
+	})
+	f.stub("UseRecoveryCode", func([]driver.Value) (fakeResult, error) {
+		return fakeResult{}, nil
+	})
+
+	cfg := &apiConfig{}
+	cfg.dbConn, cfg.db = f.open()
+	userID := uuid.New()
+	token := newTestAccessToken(t, cfg, f, userID, auth.RoleUser)
+
+	disable := func() *httptest.ResponseRecorder {
+		req := httptest.NewRequest(http.MethodDelete, "/api/users/2fa", strings.NewReader(`{"code":"000000"}`))
+		req.Header.Set("Authorization", "Bearer "+token)
+		rec := httptest.NewRecorder()
+		cfg.handlerDisableTOTP(rec, req)
+		return rec
+	}
+
+	for i := range accountLoginPolicy.Threshold {
+		if rec := disable(); rec.Code != http.StatusBadRequest {
+			t.Fatalf("attempt %d: expected 400, got %d", i+1, rec.Code)
+		}
+	}
+
+	rec := disable()
+	if rec.Code != http.StatusTooManyRequests {
+		t.Fatalf("expected 429 once the attempts are used up, got %d", rec.Code)
+	}
+	if rec.Header().Get("Retry-After") == "" {
+		t.Error("expected a Retry-After header")
+	}
+
+	if f.called("GetUserTOTP") != accountLoginPolicy.Threshold {
+		t.Errorf("expected the code not to be checked while locked out, got %d checks", f.called("GetUserTOTP"))
+	}
+
+	if _, ok := throttles["2fa-disable:"+userID.String()]; !ok {
+		t.Error("expected the attempts to be counted per user")
+	}
+}
+
+func TestTOTPSecretIsSealedAtRest(t *testing.T) {
+	f := newFakeDB(t)
+	userID := uuid.New()
+	now := time.Now()
+	f.stub("GetUserByID", func([]driver.Value) (fakeResult, error) {
+		return fakeResult{rows: [][]driver.Value{{
+			userID.String(), now, now, "user@example.com", "hash", false, time.Unix(0, 0), auth.RoleUser, nil,
+		}}}, nil
+	})
+	var stored string
+	f.stub("UpsertUserTOTP", func(args []driver.Value) (fakeResult, error) {
+		stored = args[1].(string)
+		return fakeResult{rows: [][]driver.Value{{userID.String(), now, stored, nil, int64(0)}}}, nil
+	})
+	f.stub("GetUserTOTP", func([]driver.Value) (fakeResult, error) {
+		return fakeResult{rows: [][]driver.Value{{userID.String(), now, stored, nil, int64(0)}}}, nil
+	})
+	for _, name := range []string{"ConfirmUserTOTP", "DeleteRecoveryCodes", "CreateRecoveryCode"} {
+		f.stub(name, func([]driver.Value) (fakeResult, error) {
+			return fakeResult{rowsAffected: 1}, nil
+		})
+	}
+
+	cfg := &apiConfig{signingKeyEncryptionKey: make([]byte, 32)}
+	cfg.dbConn, cfg.db = f.open()
+	token := newTestAccessToken(t, cfg, f, userID, auth.RoleUser)
+
+	req := httptest.NewRequest(http.MethodPost, "/api/users/2fa", nil)
+	req.Header.Set("Authorization", "Bearer "+token)
+	rec := httptest.NewRecorder()
+	cfg.handlerEnrollTOTP(rec, req)
+	if rec.Code != http.StatusCreated {
+		t.Fatalf("expected status %d, got %d: %s", http.StatusCreated, rec.Code, rec.Body)
+	}
+
+	var enrollment TOTPEnrollment
+	if err := json.Unmarshal(rec.Body.Bytes(), &enrollment); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if stored == "" || strings.Contains(stored, enrollment.Secret) {
+		t.Fatalf("expected the secret to be stored encrypted, got %q", stored)
+	}
+
+	code, err := auth.TOTPCode(enrollment.Secret, time.Now())
+	if err != nil {
+		t.Fatalf("failed to compute code: %v", err)
+	}
+	req = httptest.NewRequest(http.MethodPost, "/api/users/2fa/confirm", strings.NewReader(`{"code":"`+code+`"}`))
+	req.Header.Set("Authorization", "Bearer "+token)
+	rec = httptest.NewRecorder()
+	cfg.handlerConfirmTOTP(rec, req)
+	if rec.Code != http.StatusOK {
+		t.Fatalf("expected the code to be checked against the decrypted secret, got %d: %s", rec.Code, rec.Body)
+	}
+}
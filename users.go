@@ -4,6 +4,7 @@ import (
 	"database/sql"
 	"encoding/json"
 	"net/http"
+	"net/mail"
 	"time"
 
 	"github.com/dennisdijkstra/go/internal/auth"
@@ -17,13 +18,15 @@ type UserParams struct {
 }
 
 type User struct {
-	ID           uuid.UUID `json:"id"`
-	CreatedAt    time.Time `json:"created_at"`
-	UpdatedAt    time.Time `json:"updated_at"`
-	Email        string    `json:"email"`
-	Token        string    `json:"token"`
-	RefreshToken string    `json:"refresh_token"`
-	IsChirpyRed  bool      `json:"is_chirpy_red"`
+	ID            uuid.UUID `json:"id"`
+	CreatedAt     time.Time `json:"created_at"`
+	UpdatedAt     time.Time `json:"updated_at"`
+	Email         string    `json:"email"`
+	Token         string    `json:"token"`
+	RefreshToken  string    `json:"refresh_token"`
+	IsChirpyRed   bool      `json:"is_chirpy_red"`
+	Role          string    `json:"role"`
+	EmailVerified bool      `json:"email_verified"`
 }
 
 func (cfg *apiConfig) handlerCreateUser(w http.ResponseWriter, r *http.Request) {
@@ -36,6 +39,11 @@ func (cfg *apiConfig) handlerCreateUser(w http.ResponseWriter, r *http.Request)
 		return
 	}
 
+	if !validEmail(params.Email) {
+		respondWithError(w, http.StatusBadRequest, "Invalid email address")
+		return
+	}
+
 	hashedPassword, err := auth.HashPassword(params.Password)
 	if err != nil {
 		respondWithError(w, http.StatusInternalServerError, "Something went wrong while hashing the password")
@@ -51,15 +59,9 @@ func (cfg *apiConfig) handlerCreateUser(w http.ResponseWriter, r *http.Request)
 		return
 	}
 
-	body := User{
-		ID:          user.ID,
-		CreatedAt:   user.CreatedAt,
-		UpdatedAt:   user.UpdatedAt,
-		Email:       user.Email,
-		IsChirpyRed: user.IsChirpyRed,
-	}
+	cfg.sendEmailVerificationAsync(user)
 
-	respondWithJSON(w, http.StatusCreated, body)
+	respondWithJSON(w, http.StatusCreated, userFromDB(user))
 }
 
 func (cfg *apiConfig) handlerLoginUser(w http.ResponseWriter, r *http.Request) {
@@ -72,9 +74,24 @@ func (cfg *apiConfig) handlerLoginUser(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	// The attempt is reserved before anything else, and in particular
+	// before the expensive password hash comparison.
+	throttleKeys := cfg.loginThrottleKeys(r, params.Email)
+	wait, err := cfg.reserveLoginAttempt(r.Context(), throttleKeys)
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Something went wrong while checking login attempts")
+		return
+	}
+
+	if wait > 0 {
+		respondWithLoginLockout(w, wait)
+		return
+	}
+
 	user, err := cfg.db.GetUserByEmail(r.Context(), params.Email)
 	if err != nil {
 		if err == sql.ErrNoRows {
+			cfg.metrics.loginFailures.Inc()
 			respondWithError(w, http.StatusUnauthorized, "Incorrect email or password")
 			return
 		}
@@ -82,49 +99,93 @@ func (cfg *apiConfig) handlerLoginUser(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	release, ok := cfg.acquirePasswordCheck(r.Context())
+	if !ok {
+		w.Header().Set("Retry-After", "1")
+		respondWithError(w, http.StatusServiceUnavailable, "Server is busy, try again later")
+		return
+	}
 	isValid, err := auth.CheckPasswordHash(params.Password, user.HashedPassword)
+	release()
 	if err != nil {
 		respondWithError(w, http.StatusInternalServerError, "Something went wrong while checking the password")
 		return
 	}
 
 	if !isValid {
+		cfg.metrics.loginFailures.Inc()
 		respondWithError(w, http.StatusUnauthorized, "Incorrect email or password")
 		return
 	}
 
-	token, err := auth.MakeJWT(user.ID, cfg.jwtSecret, time.Hour)
+	if cfg.requireEmailVerification && !user.EmailVerifiedAt.Valid {
+		respondWithError(w, http.StatusForbidden, "Email address has not been verified")
+		return
+	}
+
+	challenge, required, err := cfg.startMFAChallenge(r.Context(), user.ID)
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Something went wrong while checking two-factor authentication")
+		return
+	}
+
+	// With two-factor authentication the account's failures are only
+	// cleared once the second step succeeds, which reserves its own
+	// attempt. The password was right, so the IP gets this one back.
+	if required {
+		cfg.refundLoginAttempt(r.Context(), throttleKeys.IP)
+		respondWithJSON(w, http.StatusOK, challenge)
+		return
+	}
+
+	cfg.clearLoginFailures(r.Context(), throttleKeys)
+	cfg.completeLogin(w, r, user)
+}
+
+// completeLogin starts a session for a user who has passed every factor and
+// responds with their access and refresh tokens.
+func (cfg *apiConfig) completeLogin(w http.ResponseWriter, r *http.Request, user database.User) {
+	token, err := auth.MakeJWT(user.ID, user.Role, cfg.signingKeys, accessTokenLifetime)
 	if err != nil {
 		respondWithError(w, http.StatusInternalServerError, "Something went wrong while creating the JWT")
 		return
 	}
 
-	refreshToken, err := auth.MakeRefreshToken()
+	tx, err := cfg.dbConn.BeginTx(r.Context(), nil)
 	if err != nil {
-		respondWithError(w, http.StatusInternalServerError, "Something went wrong while making the refresh token")
+		respondWithError(w, http.StatusInternalServerError, "Something went wrong while creating the session")
 		return
 	}
+	defer tx.Rollback()
+	qtx := cfg.db.WithTx(tx)
 
-	_, err = cfg.db.CreateRefreshToken(r.Context(), database.CreateRefreshTokenParams{
-		Token:     refreshToken,
+	session, err := qtx.CreateSession(r.Context(), database.CreateSessionParams{
 		UserID:    user.ID,
-		ExpiresAt: time.Now().Add(60 * 24 * time.Hour),
+		UserAgent: userAgent(r),
+		IpAddress: cfg.clientIP(r),
 	})
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Something went wrong while creating the session")
+		return
+	}
+
+	refreshToken, err := issueRefreshToken(r.Context(), qtx, user.ID, session.ID)
 	if err != nil {
 		respondWithError(w, http.StatusInternalServerError, "Something went wrong while creating the refresh token")
 		return
 	}
 
-	body := User{
-		ID:           user.ID,
-		CreatedAt:    user.CreatedAt,
-		UpdatedAt:    user.UpdatedAt,
-		Email:        user.Email,
-		Token:        token,
-		RefreshToken: refreshToken,
-		IsChirpyRed:  user.IsChirpyRed,
+	if err := tx.Commit(); err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Something went wrong while creating the session")
+		return
 	}
 
+	cfg.metrics.logins.Inc()
+
+	body := userFromDB(user)
+	body.Token = token
+	body.RefreshToken = refreshToken
+
 	respondWithJSON(w, http.StatusOK, body)
 }
 
@@ -144,13 +205,51 @@ func (cfg *apiConfig) handlerUpdateUser(w http.ResponseWriter, r *http.Request)
 		return
 	}
 
+	if !validEmail(params.Email) {
+		respondWithError(w, http.StatusBadRequest, "Invalid email address")
+		return
+	}
+
+	previous, err := cfg.db.GetUserByID(r.Context(), userID)
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Something went wrong while fetching the user")
+		return
+	}
+
+	// A new address is sent a verification email, which counts against the
+	// same limits as asking for one again.
+	emailChanged := params.Email != previous.Email
+	if emailChanged {
+		wait, err := cfg.reserveVerificationEmail(r.Context(), userID, params.Email)
+		if err != nil {
+			respondWithError(w, http.StatusInternalServerError, "Something went wrong while checking verification email limits")
+			return
+		}
+
+		if wait > 0 {
+			respondWithVerificationEmailLockout(w, wait)
+			return
+		}
+	}
+
 	hashedPassword, err := auth.HashPassword(params.Password)
 	if err != nil {
 		respondWithError(w, http.StatusInternalServerError, "Something went wrong while hashing the password")
 		return
 	}
 
-	user, err := cfg.db.UpdateUser(r.Context(), database.UpdateUserParams{
+	// Changing the password signs the user out everywhere: outstanding
+	// access and refresh tokens are invalidated and the caller gets a fresh
+	// pair for a new session.
+	tx, err := cfg.dbConn.BeginTx(r.Context(), nil)
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Something went wrong while updating the user")
+		return
+	}
+	defer tx.Rollback()
+	qtx := cfg.db.WithTx(tx)
+
+	user, err := qtx.UpdateUser(r.Context(), database.UpdateUserParams{
 		ID:             userID,
 		Email:          params.Email,
 		HashedPassword: hashedPassword,
@@ -160,13 +259,73 @@ func (cfg *apiConfig) handlerUpdateUser(w http.ResponseWriter, r *http.Request)
 		return
 	}
 
-	body := User{
-		ID:          user.ID,
-		CreatedAt:   user.CreatedAt,
-		UpdatedAt:   user.UpdatedAt,
-		Email:       user.Email,
-		IsChirpyRed: user.IsChirpyRed,
+	err = invalidateAccessTokens(r.Context(), qtx, user.ID)
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Something went wrong while revoking access tokens")
+		return
+	}
+
+	err = qtx.RevokeUserRefreshTokens(r.Context(), user.ID)
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Something went wrong while revoking sessions")
+		return
 	}
 
+	session, err := qtx.CreateSession(r.Context(), database.CreateSessionParams{
+		UserID:    user.ID,
+		UserAgent: userAgent(r),
+		IpAddress: cfg.clientIP(r),
+	})
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Something went wrong while creating the session")
+		return
+	}
+
+	refreshToken, err := issueRefreshToken(r.Context(), qtx, user.ID, session.ID)
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Something went wrong while creating the refresh token")
+		return
+	}
+
+	if err := tx.Commit(); err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Something went wrong while updating the user")
+		return
+	}
+	cfg.tokenRevocations.Invalidate(user.ID)
+
+	// A new address has to be verified again.
+	if emailChanged {
+		cfg.sendEmailVerificationAsync(user)
+	}
+
+	token, err := auth.MakeJWT(user.ID, user.Role, cfg.signingKeys, accessTokenLifetime)
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Something went wrong while creating the JWT")
+		return
+	}
+
+	body := userFromDB(user)
+	body.Token = token
+	body.RefreshToken = refreshToken
+
 	respondWithJSON(w, http.StatusOK, body)
 }
+
+func userFromDB(user database.User) User {
+	return User{
+		ID:            user.ID,
+		CreatedAt:     user.CreatedAt,
+		UpdatedAt:     user.UpdatedAt,
+		Email:         user.Email,
+		IsChirpyRed:   user.IsChirpyRed,
+		Role:          user.Role,
+		EmailVerified: user.EmailVerifiedAt.Valid,
+	}
+}
+
+// validEmail accepts a bare address such as user@example.com, without a
+// display name.
+func validEmail(email string) bool {
+	addr, err := mail.ParseAddress(email)
+	return err == nil && addr.Address == email
+}
@@ -0,0 +1,62 @@
+package main
+
+import (
+	"database/sql/driver"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/dennisdijkstra/go/internal/auth"
+	"github.com/google/uuid"
+)
+
+func TestLoginReservesAttemptsBeforeCheckingPassword(t *testing.T) {
+	hash, err := auth.HashPassword("correct horse")
+	if err != nil {
+		t.Fatalf("failed to hash password: %v", err)
+	}
+
+	f := newFakeDB(t)
+	stubLoginThrottles(f)
+	now := time.Now()
+	f.stub("GetUserByEmail", func(args []driver.Value) (fakeResult, error) {
+		return fakeResult{rows: [][]driver.Value{{
+			uuid.NewString(), now, now, args[0], hash, false, time.Unix(0, 0), auth.RoleUser, nil,
+		}}}, nil
+	})
+
+	cfg := &apiConfig{passwordChecks: make(chan struct{}, 1)}
+	cfg.dbConn, cfg.db = f.open()
+	cfg.metrics = newAppMetrics(cfg.dbConn)
+
+	// A burst of concurrent guesses gets no more password checks than the
+	// account's threshold; the rest are turned away before hashing.
+	var wg sync.WaitGroup
+	var mu sync.Mutex
+	codes := map[int]int{}
+	for range 2 * accountLoginPolicy.Threshold {
+		wg.Go(func() {
+			req := httptest.NewRequest(http.MethodPost, "/api/login", strings.NewReader(`{"email":"user@example.com","password":"wrong"}`))
+			rec := httptest.NewRecorder()
+			cfg.handlerLoginUser(rec, req)
+
+			mu.Lock()
+			codes[rec.Code]++
+			mu.Unlock()
+		})
+	}
+	wg.Wait()
+
+	if codes[http.StatusUnauthorized] != accountLoginPolicy.Threshold {
+		t.Errorf("expected %d rejected passwords, got %v", accountLoginPolicy.Threshold, codes)
+	}
+	if codes[http.StatusTooManyRequests] != accountLoginPolicy.Threshold {
+		t.Errorf("expected %d lockouts, got %v", accountLoginPolicy.Threshold, codes)
+	}
+	if n := f.called("GetUserByEmail"); n != accountLoginPolicy.Threshold {
+		t.Errorf("expected %d user lookups, got %d", accountLoginPolicy.Threshold, n)
+	}
+}
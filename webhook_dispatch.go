@@ -0,0 +1,317 @@
+package main
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"io"
+	"log"
+	"net"
+	"net/http"
+	"net/netip"
+	"strconv"
+	"strings"
+	"sync"
+	"syscall"
+	"time"
+
+	"github.com/dennisdijkstra/go/internal/auth"
+	"github.com/dennisdijkstra/go/internal/database"
+	"github.com/dennisdijkstra/go/internal/throttle"
+)
+
+const (
+	webhookDeliveryPending = "pending"
+
+	webhookDispatchInterval  = 5 * time.Second
+	webhookDispatchBatchSize = 20
+	webhookExpandBatchSize   = 100
+	// webhookDeliveryLease must outlast an attempt. A delivery claimed by an
+	// instance that dies mid-attempt is picked up again once it runs out.
+	webhookDeliveryLease       = time.Minute
+	webhookDeliveryTimeout     = 10 * time.Second
+	maxWebhookDeliveryAttempts = 12
+	// webhookDeliveryRetention is how long finished deliveries, and their
+	// attempts, stay queryable.
+	webhookDeliveryRetention = 30 * 24 * time.Hour
+	maxWebhookErrorLength    = 512
+)
+
+// webhookRetryPolicy spaces retries out: 30 seconds after the first failed
+// attempt, doubling after each one up to six hours. With
+// maxWebhookDeliveryAttempts, an endpoint can be down for over half a day
+// without missing an event.
+var webhookRetryPolicy = throttle.Policy{
+	Threshold:   1,
+	BaseLockout: 30 * time.Second,
+	MaxLockout:  6 * time.Hour,
+}
+
+// runWebhookDispatcher expands new events in the outbox into deliveries and
+// sends the ones that are due. Every instance runs one; claiming an event or
+// a delivery hands it to a single instance.
+func (cfg *apiConfig) runWebhookDispatcher(ctx context.Context) {
+	ticker := time.NewTicker(webhookDispatchInterval)
+	defer ticker.Stop()
+
+	var lastPruned time.Time
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			cfg.expandWebhookEvents(ctx)
+			cfg.dispatchWebhooks(ctx)
+
+			if time.Since(lastPruned) >= time.Hour {
+				cutoff := time.Now().Add(-webhookDeliveryRetention)
+				err := cfg.db.DeleteFinishedWebhookDeliveries(ctx, cutoff)
+				if err != nil {
+					log.Printf("Error pruning webhook deliveries: %s", err)
+				}
+				err = cfg.db.DeleteExpandedWebhookEvents(ctx, cutoff)
+				if err != nil {
+					log.Printf("Error pruning webhook events: %s", err)
+				}
+				lastPruned = time.Now()
+			}
+		}
+	}
+}
+
+// expandWebhookEvents writes a delivery of every new event to each endpoint
+// subscribed to it, a batch of events at a time. An event and its
+// deliveries commit together, so none is lost or expanded twice.
+func (cfg *apiConfig) expandWebhookEvents(ctx context.Context) {
+	for {
+		expanded, err := cfg.expandWebhookEventBatch(ctx)
+		if err != nil {
+			log.Printf("Error expanding webhook events: %s", err)
+			return
+		}
+
+		if expanded < webhookExpandBatchSize {
+			return
+		}
+	}
+}
+
+func (cfg *apiConfig) expandWebhookEventBatch(ctx context.Context) (int, error) {
+	tx, err := cfg.dbConn.BeginTx(ctx, nil)
+	if err != nil {
+		return 0, err
+	}
+	defer tx.Rollback()
+	qtx := cfg.db.WithTx(tx)
+
+	events, err := qtx.ClaimUnexpandedWebhookEvents(ctx, webhookExpandBatchSize)
+	if err != nil {
+		return 0, err
+	}
+
+	for _, event := range events {
+		err = qtx.EnqueueWebhookDeliveries(ctx, database.EnqueueWebhookDeliveriesParams{
+			EventID:        event.ID,
+			EventType:      event.EventType,
+			EventCreatedAt: event.CreatedAt,
+			OwnerID:        event.OwnerID,
+		})
+		if err != nil {
+			return 0, err
+		}
+	}
+
+	return len(events), tx.Commit()
+}
+
+// dispatchWebhooks attempts every delivery that is due, a batch at a time.
+func (cfg *apiConfig) dispatchWebhooks(ctx context.Context) {
+	for {
+		deliveries, err := cfg.db.ClaimDueWebhookDeliveries(ctx, database.ClaimDueWebhookDeliveriesParams{
+			LeaseUntil: time.Now().Add(webhookDeliveryLease),
+			BatchSize:  webhookDispatchBatchSize,
+		})
+		if err != nil {
+			log.Printf("Error claiming webhook deliveries: %s", err)
+			return
+		}
+
+		var wg sync.WaitGroup
+		for _, delivery := range deliveries {
+			wg.Go(func() {
+				cfg.attemptWebhookDelivery(ctx, delivery)
+			})
+		}
+		wg.Wait()
+
+		if len(deliveries) < webhookDispatchBatchSize {
+			return
+		}
+	}
+}
+
+// attemptWebhookDelivery sends a claimed delivery once and records the
+// outcome, scheduling a retry after a failure until the attempts run out.
+// Claiming counts the attempt, so a delivery whose attempts keep dying
+// before an outcome is recorded still runs out.
+func (cfg *apiConfig) attemptWebhookDelivery(ctx context.Context, delivery database.ClaimDueWebhookDeliveriesRow) {
+	if delivery.Attempts > maxWebhookDeliveryAttempts {
+		err := cfg.db.FailWebhookDelivery(context.WithoutCancel(ctx), database.FailWebhookDeliveryParams{
+			ID:        delivery.ID,
+			LastError: sql.NullString{String: "attempts ran out without an outcome", Valid: true},
+		})
+		if err != nil {
+			log.Printf("Error recording webhook delivery %s: %s", delivery.ID, err)
+		}
+		return
+	}
+
+	start := time.Now()
+	statusCode, sendErr := cfg.sendWebhook(ctx, delivery)
+
+	attempt := database.RecordWebhookDeliveryAttemptParams{
+		DeliveryID: delivery.ID,
+		DurationMs: int32(time.Since(start).Milliseconds()), // #nosec G115 -- bounded by webhookDeliveryTimeout
+	}
+	if statusCode != 0 {
+		attempt.StatusCode = sql.NullInt32{Int32: int32(statusCode), Valid: true} // #nosec G115 -- HTTP status codes have three digits
+	}
+	var lastError sql.NullString
+	if sendErr != nil {
+		lastError = sql.NullString{String: truncateWebhookError(sendErr.Error()), Valid: true}
+		attempt.Error = lastError
+	}
+
+	// The outcome is recorded even during shutdown, so a delivered webhook
+	// is not sent again.
+	ctx = context.WithoutCancel(ctx)
+	err := cfg.recordWebhookAttempt(ctx, delivery, attempt, lastError)
+	if err != nil {
+		log.Printf("Error recording webhook delivery %s: %s", delivery.ID, err)
+	}
+}
+
+func (cfg *apiConfig) recordWebhookAttempt(ctx context.Context, delivery database.ClaimDueWebhookDeliveriesRow, attempt database.RecordWebhookDeliveryAttemptParams, lastError sql.NullString) error {
+	tx, err := cfg.dbConn.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+	qtx := cfg.db.WithTx(tx)
+
+	err = qtx.RecordWebhookDeliveryAttempt(ctx, attempt)
+	if err != nil {
+		return err
+	}
+
+	attempts := int(delivery.Attempts)
+	switch {
+	case !lastError.Valid:
+		err = qtx.MarkWebhookDeliveryDelivered(ctx, delivery.ID)
+	case attempts >= maxWebhookDeliveryAttempts:
+		err = qtx.FailWebhookDelivery(ctx, database.FailWebhookDeliveryParams{
+			ID:        delivery.ID,
+			LastError: lastError,
+		})
+	default:
+		err = qtx.RetryWebhookDelivery(ctx, database.RetryWebhookDeliveryParams{
+			ID:            delivery.ID,
+			NextAttemptAt: time.Now().Add(webhookRetryPolicy.Lockout(attempts)),
+			LastError:     lastError,
+		})
+	}
+	if err != nil {
+		return err
+	}
+
+	return tx.Commit()
+}
+
+// sendWebhook posts the delivery's payload to its endpoint and returns the
+// response status, or zero if there was no response. Anything but a 2xx
+// response is an error.
+func (cfg *apiConfig) sendWebhook(ctx context.Context, delivery database.ClaimDueWebhookDeliveriesRow) (int, error) {
+	ctx, cancel := context.WithTimeout(ctx, webhookDeliveryTimeout)
+	defer cancel()
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, delivery.Url, strings.NewReader(delivery.Payload))
+	if err != nil {
+		return 0, err
+	}
+
+	now := time.Now()
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("User-Agent", "Chirpy-Webhooks")
+	req.Header.Set("Webhook-ID", delivery.EventID.String())
+	req.Header.Set("Webhook-Event", delivery.EventType)
+	req.Header.Set(auth.WebhookTimestampHeader, strconv.FormatInt(now.Unix(), 10))
+	req.Header.Set(auth.WebhookSignatureHeader, auth.SignWebhook(delivery.Secret, now, []byte(delivery.Payload)))
+
+	resp, err := cfg.webhookClient.Do(req)
+	if err != nil {
+		return 0, err
+	}
+	defer resp.Body.Close()
+	io.Copy(io.Discard, io.LimitReader(resp.Body, 64<<10))
+
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		return resp.StatusCode, fmt.Errorf("endpoint responded with %s", resp.Status)
+	}
+
+	return resp.StatusCode, nil
+}
+
+// newWebhookClient returns the client deliveries are sent with. Endpoints
+// are user-supplied, so unless allowPrivateAddresses is set it refuses to
+// connect to loopback, private and link-local addresses, checked after DNS
+// resolution, and it does not follow redirects.
+func newWebhookClient(allowPrivateAddresses bool) *http.Client {
+	dialer := &net.Dialer{
+		Timeout: 5 * time.Second,
+		Control: func(network, address string, _ syscall.RawConn) error {
+			if allowPrivateAddresses {
+				return nil
+			}
+			return checkWebhookAddress(address)
+		},
+	}
+
+	return &http.Client{
+		Transport: &http.Transport{
+			DialContext:         dialer.DialContext,
+			TLSHandshakeTimeout: 5 * time.Second,
+			MaxIdleConnsPerHost: 2,
+		},
+		CheckRedirect: func(*http.Request, []*http.Request) error {
+			return http.ErrUseLastResponse
+		},
+	}
+}
+
+// checkWebhookAddress rejects a resolved host:port unless the host is a
+// public unicast address.
+func checkWebhookAddress(address string) error {
+	host, _, err := net.SplitHostPort(address)
+	if err != nil {
+		return err
+	}
+
+	ip, err := netip.ParseAddr(host)
+	if err != nil {
+		return err
+	}
+
+	ip = ip.Unmap()
+	if !ip.IsGlobalUnicast() || ip.IsPrivate() {
+		return fmt.Errorf("webhook address %s is not public", ip)
+	}
+
+	return nil
+}
+
+func truncateWebhookError(msg string) string {
+	if len(msg) <= maxWebhookErrorLength {
+		return msg
+	}
+	return msg[:maxWebhookErrorLength]
+}
@@ -0,0 +1,210 @@
+package main
+
+import (
+	"context"
+	"database/sql"
+	"database/sql/driver"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/dennisdijkstra/go/internal/database"
+	"github.com/google/uuid"
+)
+
+func TestCheckWebhookAddress(t *testing.T) {
+	tests := []struct {
+		address string
+		public  bool
+	}{
+		{"93.184.216.34:443", true},
+		{"[2606:2800:220:1::1]:443", true},
+		{"127.0.0.1:80", false},
+		{"[::1]:80", false},
+		{"10.1.2.3:443", false},
+		{"172.16.0.1:443", false},
+		{"192.168.1.1:443", false},
+		{"169.254.169.254:80", false},
+		{"[fe80::1]:80", false},
+		{"[fc00::1]:80", false},
+		{"0.0.0.0:80", false},
+		{"[::ffff:127.0.0.1]:80", false},
+		{"[::ffff:10.0.0.1]:80", false},
+		{"224.0.0.1:80", false},
+		{"example.com:443", false},
+	}
+
+	for _, tt := range tests {
+		err := checkWebhookAddress(tt.address)
+		if tt.public && err != nil {
+			t.Errorf("checkWebhookAddress(%q) = %v, want nil", tt.address, err)
+		}
+		if !tt.public && err == nil {
+			t.Errorf("checkWebhookAddress(%q) = nil, want an error", tt.address)
+		}
+	}
+}
+
+func TestWebhookClientRefusesPrivateAddresses(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusNoContent)
+	}))
+	defer server.Close()
+
+	_, err := newWebhookClient(false).Get(server.URL)
+	if err == nil || !strings.Contains(err.Error(), "is not public") {
+		t.Errorf("expected the loopback address to be refused, got %v", err)
+	}
+
+	resp, err := newWebhookClient(true).Get(server.URL)
+	if err != nil {
+		t.Fatalf("expected private addresses to be allowed, got %v", err)
+	}
+	resp.Body.Close()
+}
+
+func TestWebhookClientDoesNotFollowRedirects(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		http.Redirect(w, r, "/elsewhere", http.StatusFound)
+	}))
+	defer server.Close()
+
+	resp, err := newWebhookClient(true).Get(server.URL)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	resp.Body.Close()
+
+	if resp.StatusCode != http.StatusFound {
+		t.Errorf("expected the redirect itself, got status %d", resp.StatusCode)
+	}
+}
+
+// stubWebhookDeliveryOutcomes stubs the queries that record a delivery
+// attempt and its outcome.
+func stubWebhookDeliveryOutcomes(f *fakeDB) *[]driver.Value {
+	var retryAt []driver.Value
+	for _, name := range []string{"RecordWebhookDeliveryAttempt", "MarkWebhookDeliveryDelivered", "FailWebhookDelivery"} {
+		f.stub(name, func([]driver.Value) (fakeResult, error) {
+			return fakeResult{rowsAffected: 1}, nil
+		})
+	}
+	f.stub("RetryWebhookDelivery", func(args []driver.Value) (fakeResult, error) {
+		retryAt = append(retryAt, args[1])
+		return fakeResult{rowsAffected: 1}, nil
+	})
+	return &retryAt
+}
+
+func TestRecordWebhookAttempt(t *testing.T) {
+	failure := sql.NullString{String: "endpoint responded with 500", Valid: true}
+	tests := []struct {
+		name      string
+		attempts  int32
+		lastError sql.NullString
+		want      string
+	}{
+		{"delivered", 1, sql.NullString{}, "MarkWebhookDeliveryDelivered"},
+		{"delivered on last attempt", maxWebhookDeliveryAttempts, sql.NullString{}, "MarkWebhookDeliveryDelivered"},
+		{"first failure", 1, failure, "RetryWebhookDelivery"},
+		{"failure before last attempt", maxWebhookDeliveryAttempts - 1, failure, "RetryWebhookDelivery"},
+		{"failure on last attempt", maxWebhookDeliveryAttempts, failure, "FailWebhookDelivery"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			f := newFakeDB(t)
+			retryAt := stubWebhookDeliveryOutcomes(f)
+			cfg := &apiConfig{}
+			cfg.dbConn, cfg.db = f.open()
+
+			delivery := database.ClaimDueWebhookDeliveriesRow{ID: uuid.New(), Attempts: tt.attempts}
+			attempt := database.RecordWebhookDeliveryAttemptParams{DeliveryID: delivery.ID, Error: tt.lastError}
+			if err := cfg.recordWebhookAttempt(context.Background(), delivery, attempt, tt.lastError); err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+
+			if n := f.called("RecordWebhookDeliveryAttempt"); n != 1 {
+				t.Errorf("expected the attempt to be recorded, got %d", n)
+			}
+			for _, name := range []string{"MarkWebhookDeliveryDelivered", "RetryWebhookDelivery", "FailWebhookDelivery"} {
+				want := 0
+				if name == tt.want {
+					want = 1
+				}
+				if n := f.called(name); n != want {
+					t.Errorf("expected %s to run %d times, got %d", name, want, n)
+				}
+			}
+
+			if tt.want == "RetryWebhookDelivery" {
+				wait := time.Until((*retryAt)[0].(time.Time))
+				want := webhookRetryPolicy.Lockout(int(tt.attempts))
+				if wait > want || wait < want-time.Minute {
+					t.Errorf("expected a retry in %s, got %s", want, wait)
+				}
+			}
+		})
+	}
+}
+
+func TestAttemptWebhookDeliveryFailsAfterInterruptedAttempts(t *testing.T) {
+	f := newFakeDB(t)
+	stubWebhookDeliveryOutcomes(f)
+	cfg := &apiConfig{webhookClient: newWebhookClient(false)}
+	cfg.dbConn, cfg.db = f.open()
+
+	// Each claim counted an attempt, but none recorded an outcome.
+	delivery := database.ClaimDueWebhookDeliveriesRow{
+		ID:       uuid.New(),
+		Attempts: maxWebhookDeliveryAttempts + 1,
+		Url:      "https://example.com/hooks",
+	}
+	cfg.attemptWebhookDelivery(context.Background(), delivery)
+
+	if n := f.called("FailWebhookDelivery"); n != 1 {
+		t.Errorf("expected the delivery to be failed, got %d", n)
+	}
+	if n := f.called("RecordWebhookDeliveryAttempt"); n != 0 {
+		t.Errorf("expected no further attempt, got %d", n)
+	}
+}
+
+func TestExpandWebhookEventBatch(t *testing.T) {
+	f := newFakeDB(t)
+	ownerID := uuid.New()
+	createdAt := time.Now().Add(-time.Minute)
+	f.stub("ClaimUnexpandedWebhookEvents", func(args []driver.Value) (fakeResult, error) {
+		return fakeResult{rows: [][]driver.Value{
+			{uuid.NewString(), createdAt, eventChirpCreated, nil},
+			{uuid.NewString(), createdAt, eventUserUpgraded, ownerID.String()},
+		}}, nil
+	})
+	var enqueued [][]driver.Value
+	f.stub("EnqueueWebhookDeliveries", func(args []driver.Value) (fakeResult, error) {
+		enqueued = append(enqueued, args)
+		return fakeResult{rowsAffected: 3}, nil
+	})
+	cfg := &apiConfig{}
+	cfg.dbConn, cfg.db = f.open()
+
+	expanded, err := cfg.expandWebhookEventBatch(context.Background())
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	if expanded != 2 || len(enqueued) != 2 {
+		t.Fatalf("expected both events to be expanded, got %d and %d", expanded, len(enqueued))
+	}
+	if enqueued[0][3] != nil {
+		t.Errorf("expected a global event to go to every subscriber, got owner %v", enqueued[0][3])
+	}
+	if enqueued[1][3] != ownerID.String() {
+		t.Errorf("expected a user event to go to its owner %s, got %v", ownerID, enqueued[1][3])
+	}
+	if got := enqueued[1][2]; got != createdAt {
+		t.Errorf("expected only endpoints that existed at %s, got %v", createdAt, got)
+	}
+}
@@ -0,0 +1,189 @@
+package main
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/dennisdijkstra/go/internal/database"
+	"github.com/google/uuid"
+)
+
+const (
+	webhookEventProcessed = "processed"
+	webhookEventFailed    = "failed"
+)
+
+type WebhookEvent struct {
+	ID          uuid.UUID       `json:"id"`
+	ReceivedAt  time.Time       `json:"received_at"`
+	UpdatedAt   time.Time       `json:"updated_at"`
+	EventID     string          `json:"event_id"`
+	EventType   string          `json:"event_type"`
+	Payload     json.RawMessage `json:"payload"`
+	Status      string          `json:"status"`
+	Attempts    int32           `json:"attempts"`
+	LastError   string          `json:"last_error,omitempty"`
+	ProcessedAt *time.Time      `json:"processed_at"`
+}
+
+func (cfg *apiConfig) handlerGetFailedWebhookEvents(w http.ResponseWriter, r *http.Request) {
+	events, err := cfg.db.GetWebhookEventsByStatus(r.Context(), webhookEventFailed)
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Something went wrong while fetching webhook events")
+		return
+	}
+
+	response := make([]WebhookEvent, 0, len(events))
+	for _, event := range events {
+		response = append(response, webhookEventFromDB(event))
+	}
+
+	respondWithJSON(w, http.StatusOK, response)
+}
+
+// handlerReplayWebhookEvent runs a stored event through the same processing
+// as a live delivery and responds with its outcome.
+func (cfg *apiConfig) handlerReplayWebhookEvent(w http.ResponseWriter, r *http.Request) {
+	eventID, err := uuid.Parse(r.PathValue("eventID"))
+	if err != nil {
+		respondWithError(w, http.StatusBadRequest, "Invalid event ID")
+		return
+	}
+
+	event, err := cfg.db.GetWebhookEvent(r.Context(), eventID)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			respondWithError(w, http.StatusNotFound, "Webhook event not found")
+			return
+		}
+		respondWithError(w, http.StatusInternalServerError, "Something went wrong while fetching the webhook event")
+		return
+	}
+
+	if event.Status == webhookEventProcessed {
+		respondWithError(w, http.StatusConflict, "Webhook event has already been processed")
+		return
+	}
+
+	// A failure is recorded on the event itself, which is what the
+	// response reports.
+	if err := cfg.processWebhookEvent(r.Context(), event.ID); err != nil {
+		log.Printf("Error replaying webhook event %s: %s", event.ID, err)
+	}
+
+	event, err = cfg.db.GetWebhookEvent(r.Context(), event.ID)
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Something went wrong while fetching the webhook event")
+		return
+	}
+
+	respondWithJSON(w, http.StatusOK, webhookEventFromDB(event))
+}
+
+// recordWebhookEvent stores an incoming event, or returns the stored one if
+// the event has been delivered before. An event identified by its payload
+// only matches one received within webhookPayloadDedupeWindow; an older
+// match is renamed out of the way so the new delivery is stored afresh.
+func (cfg *apiConfig) recordWebhookEvent(ctx context.Context, eventID, eventType string, payload []byte) (database.WebhookEvent, error) {
+	if strings.HasPrefix(eventID, payloadEventIDPrefix) {
+		err := cfg.db.RetireWebhookEventID(ctx, database.RetireWebhookEventIDParams{
+			EventID:    eventID,
+			ReceivedAt: time.Now().Add(-webhookPayloadDedupeWindow),
+		})
+		if err != nil {
+			return database.WebhookEvent{}, err
+		}
+	}
+
+	event, err := cfg.db.CreateWebhookEvent(ctx, database.CreateWebhookEventParams{
+		EventID:   eventID,
+		EventType: eventType,
+		Payload:   string(payload),
+	})
+	if errors.Is(err, sql.ErrNoRows) {
+		return cfg.db.GetWebhookEventByEventID(ctx, eventID)
+	}
+
+	return event, err
+}
+
+// processWebhookEvent applies a stored event unless it has already been
+// applied. The event row stays locked until the change commits, so
+// concurrent deliveries of one event cannot both apply it. The outcome is
+// recorded on the event either way.
+func (cfg *apiConfig) processWebhookEvent(ctx context.Context, id uuid.UUID) error {
+	err := cfg.applyWebhookEvent(ctx, id)
+	if err != nil {
+		failErr := cfg.db.MarkWebhookEventFailed(context.WithoutCancel(ctx), database.MarkWebhookEventFailedParams{
+			ID:        id,
+			LastError: sql.NullString{String: err.Error(), Valid: true},
+		})
+		if failErr != nil {
+			log.Printf("Error recording failed webhook event %s: %s", id, failErr)
+		}
+	}
+
+	return err
+}
+
+func (cfg *apiConfig) applyWebhookEvent(ctx context.Context, id uuid.UUID) error {
+	tx, err := cfg.dbConn.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+	qtx := cfg.db.WithTx(tx)
+
+	event, err := qtx.LockWebhookEvent(ctx, id)
+	if err != nil {
+		return err
+	}
+
+	if event.Status == webhookEventProcessed {
+		return nil
+	}
+
+	params := WebhookParams{}
+	if err := json.Unmarshal([]byte(event.Payload), &params); err != nil {
+		return fmt.Errorf("decoding payload: %w", err)
+	}
+
+	// Events without a time of their own are ordered by when they arrived,
+	// so replaying an old failed event cannot undo a newer one.
+	occurredAt := event.ReceivedAt
+	if params.CreatedAt != nil {
+		occurredAt = *params.CreatedAt
+	}
+
+	if err := applyPolkaEvent(ctx, qtx, params, occurredAt); err != nil {
+		return err
+	}
+
+	if err := qtx.MarkWebhookEventProcessed(ctx, event.ID); err != nil {
+		return err
+	}
+
+	return tx.Commit()
+}
+
+func webhookEventFromDB(event database.WebhookEvent) WebhookEvent {
+	return WebhookEvent{
+		ID:          event.ID,
+		ReceivedAt:  event.ReceivedAt,
+		UpdatedAt:   event.UpdatedAt,
+		EventID:     event.EventID,
+		EventType:   event.EventType,
+		Payload:     json.RawMessage(event.Payload),
+		Status:      event.Status,
+		Attempts:    event.Attempts,
+		LastError:   event.LastError.String,
+		ProcessedAt: nullTimePtr(event.ProcessedAt),
+	}
+}
@@ -1,56 +1,77 @@
 package main
 
 import (
-	"database/sql"
+	"crypto/sha256"
+	"encoding/hex"
 	"encoding/json"
 	"errors"
+	"io"
+	"log"
 	"net/http"
-	"os"
+	"time"
 
 	"github.com/dennisdijkstra/go/internal/auth"
-	"github.com/dennisdijkstra/go/internal/database"
 	"github.com/google/uuid"
 )
 
+const (
+	maxWebhookBytes = 1 << 20
+	// webhookPayloadDedupeWindow is how long a delivery without an ID is
+	// treated as a retry of an earlier identical one. Polka gives up
+	// retrying well within it; an identical payload after it is a new
+	// event.
+	webhookPayloadDedupeWindow = 24 * time.Hour
+)
+
+// payloadEventIDPrefix marks event IDs derived from the payload.
+const payloadEventIDPrefix = "sha256:"
+
 type WebhookParams struct {
+	// ID identifies the event across Polka's delivery retries.
+	ID    string `json:"id,omitempty"`
 	Event string `json:"event"`
-	Data  struct {
+	// CreatedAt is when the event happened at Polka. Events are applied
+	// in this order, whatever order they arrive in.
+	CreatedAt *time.Time `json:"created_at,omitempty"`
+	Data      struct {
 		UserID uuid.UUID `json:"user_id"`
+		// CurrentPeriodEnd is when the paid period ends. Polka leaves it
+		// out for open-ended subscriptions.
+		CurrentPeriodEnd *time.Time `json:"current_period_end,omitempty"`
 	} `json:"data"`
 }
 
+// handlerPolkaWebhook records the event before acting on it, so a delivery
+// Polka retries, or sends twice, is only ever applied once.
 func (cfg *apiConfig) handlerPolkaWebhook(w http.ResponseWriter, r *http.Request) {
-	apiKey, err := auth.GetAPIKey(r.Header)
+	payload, err := io.ReadAll(http.MaxBytesReader(w, r.Body, maxWebhookBytes))
 	if err != nil {
-		respondWithError(w, http.StatusUnauthorized, "Unauthorized")
+		respondWithError(w, http.StatusBadRequest, "Something went wrong while reading the request body")
 		return
 	}
 
-	if apiKey != os.Getenv("POLKA_KEY") {
+	if !cfg.authenticatePolkaWebhook(r, payload) {
 		respondWithError(w, http.StatusUnauthorized, "Unauthorized")
 		return
 	}
 
-	decoder := json.NewDecoder(r.Body)
 	params := WebhookParams{}
-	err = decoder.Decode(&params)
+	err = json.Unmarshal(payload, &params)
 
 	if err != nil {
 		respondWithError(w, http.StatusBadRequest, "Something went wrong")
 		return
 	}
 
-	if params.Event != "user.upgraded" {
-		w.WriteHeader(http.StatusNoContent)
+	event, err := cfg.recordWebhookEvent(r.Context(), webhookEventID(params, payload), params.Event, payload)
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Something went wrong while recording the event")
 		return
 	}
 
-	_, err = cfg.db.UpdateUserIsChirpyRed(r.Context(), database.UpdateUserIsChirpyRedParams{
-		ID:          params.Data.UserID,
-		IsChirpyRed: true,
-	})
+	err = cfg.processWebhookEvent(r.Context(), event.ID)
 	if err != nil {
-		if errors.Is(err, sql.ErrNoRows) {
+		if errors.Is(err, errSubscriptionUserNotFound) {
 			respondWithError(w, http.StatusNotFound, "User not found")
 			return
 		}
@@ -60,3 +81,32 @@ func (cfg *apiConfig) handlerPolkaWebhook(w http.ResponseWriter, r *http.Request
 
 	w.WriteHeader(http.StatusNoContent)
 }
+
+// authenticatePolkaWebhook accepts a delivery signed with one of the
+// configured secrets. Unsigned deliveries fall back to the older API key
+// check, which is turned off by leaving POLKA_KEY unset.
+func (cfg *apiConfig) authenticatePolkaWebhook(r *http.Request, payload []byte) bool {
+	err := auth.VerifyWebhookSignature(r.Header, payload, cfg.polkaWebhookSecrets, cfg.polkaWebhookTolerance, time.Now())
+	if err == nil {
+		return true
+	}
+
+	if !errors.Is(err, auth.ErrNoWebhookSignature) {
+		log.Printf("Rejected Polka webhook: %s", err)
+		return false
+	}
+
+	return auth.CheckAPIKey(r.Header, cfg.polkaKey)
+}
+
+// webhookEventID returns the event's own ID. Deliveries without one are
+// identified by their payload, so that identical retries still count as
+// one event for webhookPayloadDedupeWindow.
+func webhookEventID(params WebhookParams, payload []byte) string {
+	if params.ID != "" {
+		return params.ID
+	}
+
+	sum := sha256.Sum256(payload)
+	return payloadEventIDPrefix + hex.EncodeToString(sum[:])
+}
@@ -0,0 +1,336 @@
+package main
+
+import (
+	"database/sql/driver"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/dennisdijkstra/go/internal/auth"
+	"github.com/google/uuid"
+)
+
+// fakeWebhookEvents keeps webhook_events rows for the fake database.
+type fakeWebhookEvents struct {
+	mu     sync.Mutex
+	rows   map[string][]driver.Value // by event_id
+	failed int
+}
+
+func (e *fakeWebhookEvents) byID(id string) []driver.Value {
+	for _, row := range e.rows {
+		if row[0] == id {
+			return row
+		}
+	}
+	return nil
+}
+
+// stubWebhookEvents stubs the queries that record and process an incoming
+// webhook event.
+func stubWebhookEvents(f *fakeDB) *fakeWebhookEvents {
+	events := &fakeWebhookEvents{rows: map[string][]driver.Value{}}
+
+	f.stub("RetireWebhookEventID", func(args []driver.Value) (fakeResult, error) {
+		events.mu.Lock()
+		defer events.mu.Unlock()
+
+		eventID, cutoff := args[0].(string), args[1].(time.Time)
+		row, ok := events.rows[eventID]
+		if !ok || !row[1].(time.Time).Before(cutoff) {
+			return fakeResult{}, nil
+		}
+		delete(events.rows, eventID)
+		row[3] = eventID + ":" + row[0].(string)
+		events.rows[row[3].(string)] = row
+		return fakeResult{rowsAffected: 1}, nil
+	})
+	f.stub("CreateWebhookEvent", func(args []driver.Value) (fakeResult, error) {
+		events.mu.Lock()
+		defer events.mu.Unlock()
+
+		eventID := args[0].(string)
+		if _, ok := events.rows[eventID]; ok {
+			return fakeResult{}, nil
+		}
+		now := time.Now()
+		row := []driver.Value{uuid.NewString(), now, now, eventID, args[1], args[2], "pending", int64(0), nil, nil}
+		events.rows[eventID] = row
+		return fakeResult{rows: [][]driver.Value{row}}, nil
+	})
+	f.stub("GetWebhookEventByEventID", func(args []driver.Value) (fakeResult, error) {
+		events.mu.Lock()
+		defer events.mu.Unlock()
+
+		row, ok := events.rows[args[0].(string)]
+		if !ok {
+			return fakeResult{}, nil
+		}
+		return fakeResult{rows: [][]driver.Value{row}}, nil
+	})
+	f.stub("LockWebhookEvent", func(args []driver.Value) (fakeResult, error) {
+		events.mu.Lock()
+		defer events.mu.Unlock()
+
+		row := events.byID(args[0].(string))
+		if row == nil {
+			return fakeResult{}, nil
+		}
+		return fakeResult{rows: [][]driver.Value{row}}, nil
+	})
+	f.stub("MarkWebhookEventProcessed", func(args []driver.Value) (fakeResult, error) {
+		events.mu.Lock()
+		defer events.mu.Unlock()
+
+		events.byID(args[0].(string))[6] = webhookEventProcessed
+		return fakeResult{rowsAffected: 1}, nil
+	})
+	f.stub("MarkWebhookEventFailed", func(args []driver.Value) (fakeResult, error) {
+		events.mu.Lock()
+		defer events.mu.Unlock()
+
+		events.byID(args[0].(string))[6] = webhookEventFailed
+		events.failed++
+		return fakeResult{rowsAffected: 1}, nil
+	})
+
+	return events
+}
+
+// subscriptionCall is the event time and grace period a subscription query
+// was run with.
+type subscriptionCall struct {
+	Query          string
+	EventAt        time.Time
+	GracePeriodEnd time.Time
+}
+
+// stubSubscriptions stubs the queries applyPolkaEvent runs for a known user
+// and records the subscription changes it makes.
+func stubSubscriptions(f *fakeDB, userID uuid.UUID) *[]subscriptionCall {
+	var mu sync.Mutex
+	calls := &[]subscriptionCall{}
+	now := time.Now()
+
+	userRow := func(red bool) []driver.Value {
+		return []driver.Value{userID.String(), now, now, "user@example.com", "hash", red, time.Unix(0, 0), auth.RoleUser, nil}
+	}
+	subscriptionRow := func(status string, eventAt time.Time) []driver.Value {
+		return []driver.Value{userID.String(), now, now, status, nil, nil, nil, eventAt}
+	}
+
+	f.stub("GetUserByID", func(args []driver.Value) (fakeResult, error) {
+		if args[0] != userID.String() {
+			return fakeResult{}, nil
+		}
+		return fakeResult{rows: [][]driver.Value{userRow(false)}}, nil
+	})
+	f.stub("ActivateSubscription", func(args []driver.Value) (fakeResult, error) {
+		mu.Lock()
+		defer mu.Unlock()
+		*calls = append(*calls, subscriptionCall{Query: "ActivateSubscription", EventAt: args[2].(time.Time)})
+		return fakeResult{rows: [][]driver.Value{subscriptionRow("active", args[2].(time.Time))}}, nil
+	})
+	f.stub("MarkSubscriptionPastDue", func(args []driver.Value) (fakeResult, error) {
+		mu.Lock()
+		defer mu.Unlock()
+		*calls = append(*calls, subscriptionCall{
+			Query:          "MarkSubscriptionPastDue",
+			GracePeriodEnd: args[0].(time.Time),
+			EventAt:        args[1].(time.Time),
+		})
+		return fakeResult{rows: [][]driver.Value{subscriptionRow("past_due", args[1].(time.Time))}}, nil
+	})
+	f.stub("SyncUserIsChirpyRed", func(args []driver.Value) (fakeResult, error) {
+		return fakeResult{rows: [][]driver.Value{userRow(true)}}, nil
+	})
+	f.stub("CreateOutboundWebhookEvent", func(args []driver.Value) (fakeResult, error) {
+		return fakeResult{}, nil
+	})
+
+	return calls
+}
+
+func newWebhookTestConfig(t *testing.T, f *fakeDB) *apiConfig {
+	t.Helper()
+
+	cfg := &apiConfig{polkaKey: "polka-key"}
+	cfg.dbConn, cfg.db = f.open()
+	return cfg
+}
+
+func postPolkaWebhook(cfg *apiConfig, body string) *httptest.ResponseRecorder {
+	req := httptest.NewRequest(http.MethodPost, "/api/polka/webhooks", strings.NewReader(body))
+	req.Header.Set("Authorization", "ApiKey "+cfg.polkaKey)
+	rec := httptest.NewRecorder()
+	cfg.handlerPolkaWebhook(rec, req)
+	return rec
+}
+
+func TestPolkaWebhookAppliesDuplicateDeliveryOnce(t *testing.T) {
+	userID := uuid.New()
+	f := newFakeDB(t)
+	stubWebhookEvents(f)
+	calls := stubSubscriptions(f, userID)
+	cfg := newWebhookTestConfig(t, f)
+
+	body := `{"id":"evt_1","event":"user.upgraded","data":{"user_id":"` + userID.String() + `"}}`
+	for range 2 {
+		if rec := postPolkaWebhook(cfg, body); rec.Code != http.StatusNoContent {
+			t.Fatalf("expected status %d, got %d: %s", http.StatusNoContent, rec.Code, rec.Body)
+		}
+	}
+
+	if len(*calls) != 1 {
+		t.Errorf("expected the event to be applied once, got %v", *calls)
+	}
+	if n := f.called("RetireWebhookEventID"); n != 0 {
+		t.Errorf("expected an event with an ID to be deduplicated for good, got %d retirements", n)
+	}
+}
+
+func TestPolkaWebhookPayloadDedupeIsBounded(t *testing.T) {
+	userID := uuid.New()
+	f := newFakeDB(t)
+	events := stubWebhookEvents(f)
+	calls := stubSubscriptions(f, userID)
+	cfg := newWebhookTestConfig(t, f)
+
+	body := `{"event":"user.upgraded","data":{"user_id":"` + userID.String() + `"}}`
+	if rec := postPolkaWebhook(cfg, body); rec.Code != http.StatusNoContent {
+		t.Fatalf("expected status %d, got %d: %s", http.StatusNoContent, rec.Code, rec.Body)
+	}
+	if rec := postPolkaWebhook(cfg, body); rec.Code != http.StatusNoContent {
+		t.Fatalf("expected status %d, got %d: %s", http.StatusNoContent, rec.Code, rec.Body)
+	}
+	if len(*calls) != 1 {
+		t.Fatalf("expected a retry within the window to be ignored, got %v", *calls)
+	}
+
+	// Once the first delivery is older than the window, the same payload
+	// is a new event.
+	events.mu.Lock()
+	for _, row := range events.rows {
+		row[1] = time.Now().Add(-webhookPayloadDedupeWindow - time.Minute)
+	}
+	events.mu.Unlock()
+
+	if rec := postPolkaWebhook(cfg, body); rec.Code != http.StatusNoContent {
+		t.Fatalf("expected status %d, got %d: %s", http.StatusNoContent, rec.Code, rec.Body)
+	}
+	if len(*calls) != 2 {
+		t.Errorf("expected an identical payload after the window to be applied, got %v", *calls)
+	}
+	if len(events.rows) != 2 {
+		t.Errorf("expected the old event to be kept alongside the new one, got %d events", len(events.rows))
+	}
+}
+
+func TestPolkaWebhookOrdersEventsByOccurrence(t *testing.T) {
+	userID := uuid.New()
+	f := newFakeDB(t)
+	stubWebhookEvents(f)
+	calls := stubSubscriptions(f, userID)
+	cfg := newWebhookTestConfig(t, f)
+
+	createdAt := time.Date(2026, 1, 2, 3, 4, 5, 0, time.UTC)
+	body := `{"id":"evt_1","event":"user.upgraded","created_at":"` + createdAt.Format(time.RFC3339) + `","data":{"user_id":"` + userID.String() + `"}}`
+	if rec := postPolkaWebhook(cfg, body); rec.Code != http.StatusNoContent {
+		t.Fatalf("expected status %d, got %d: %s", http.StatusNoContent, rec.Code, rec.Body)
+	}
+
+	// Without a created_at, the event is ordered by when it arrived.
+	before := time.Now()
+	body = `{"id":"evt_2","event":"user.upgraded","data":{"user_id":"` + userID.String() + `"}}`
+	if rec := postPolkaWebhook(cfg, body); rec.Code != http.StatusNoContent {
+		t.Fatalf("expected status %d, got %d: %s", http.StatusNoContent, rec.Code, rec.Body)
+	}
+
+	if len(*calls) != 2 {
+		t.Fatalf("expected two subscription changes, got %v", *calls)
+	}
+	if got := (*calls)[0].EventAt; !got.Equal(createdAt) {
+		t.Errorf("expected the event's created_at %s, got %s", createdAt, got)
+	}
+	if got := (*calls)[1].EventAt; got.Before(before) || got.After(time.Now()) {
+		t.Errorf("expected the time the event was received, got %s", got)
+	}
+}
+
+func TestPolkaWebhookPaymentFailedStartsGracePeriod(t *testing.T) {
+	userID := uuid.New()
+	f := newFakeDB(t)
+	stubWebhookEvents(f)
+	calls := stubSubscriptions(f, userID)
+	cfg := newWebhookTestConfig(t, f)
+
+	body := `{"id":"evt_1","event":"payment.failed","data":{"user_id":"` + userID.String() + `"}}`
+	if rec := postPolkaWebhook(cfg, body); rec.Code != http.StatusNoContent {
+		t.Fatalf("expected status %d, got %d: %s", http.StatusNoContent, rec.Code, rec.Body)
+	}
+
+	if len(*calls) != 1 || (*calls)[0].Query != "MarkSubscriptionPastDue" {
+		t.Fatalf("expected the subscription to be marked past due, got %v", *calls)
+	}
+	if got := time.Until((*calls)[0].GracePeriodEnd); got < paymentGracePeriod-time.Minute || got > paymentGracePeriod {
+		t.Errorf("expected a grace period of %s, got %s", paymentGracePeriod, got)
+	}
+}
+
+func TestPolkaWebhookUnknownUser(t *testing.T) {
+	f := newFakeDB(t)
+	events := stubWebhookEvents(f)
+	calls := stubSubscriptions(f, uuid.New())
+	cfg := newWebhookTestConfig(t, f)
+
+	body := `{"id":"evt_1","event":"user.upgraded","data":{"user_id":"` + uuid.NewString() + `"}}`
+	if rec := postPolkaWebhook(cfg, body); rec.Code != http.StatusNotFound {
+		t.Fatalf("expected status %d, got %d: %s", http.StatusNotFound, rec.Code, rec.Body)
+	}
+
+	if events.failed != 1 {
+		t.Errorf("expected the event to be recorded as failed, got %d failures", events.failed)
+	}
+	if len(*calls) != 0 {
+		t.Errorf("expected no subscription changes, got %v", *calls)
+	}
+}
+
+func TestPolkaWebhookRejectsWrongKey(t *testing.T) {
+	f := newFakeDB(t)
+	cfg := newWebhookTestConfig(t, f)
+
+	req := httptest.NewRequest(http.MethodPost, "/api/polka/webhooks", strings.NewReader(`{"event":"user.upgraded"}`))
+	req.Header.Set("Authorization", "ApiKey wrong")
+	rec := httptest.NewRecorder()
+	cfg.handlerPolkaWebhook(rec, req)
+
+	if rec.Code != http.StatusUnauthorized {
+		t.Errorf("expected status %d, got %d", http.StatusUnauthorized, rec.Code)
+	}
+	if n := f.called("CreateWebhookEvent"); n != 0 {
+		t.Errorf("expected nothing to be recorded, got %d events", n)
+	}
+}
+
+func TestWebhookEventID(t *testing.T) {
+	payload := []byte(`{"event":"user.upgraded"}`)
+
+	if got := webhookEventID(WebhookParams{ID: "evt_1"}, payload); got != "evt_1" {
+		t.Errorf("expected the event's own ID, got %q", got)
+	}
+
+	got := webhookEventID(WebhookParams{}, payload)
+	if !strings.HasPrefix(got, payloadEventIDPrefix) {
+		t.Errorf("expected a payload-derived ID, got %q", got)
+	}
+	if webhookEventID(WebhookParams{}, payload) != got {
+		t.Error("expected identical payloads to get the same ID")
+	}
+	if webhookEventID(WebhookParams{}, []byte(`{"event":"user.downgraded"}`)) == got {
+		t.Error("expected different payloads to get different IDs")
+	}
+}